@@ -0,0 +1,64 @@
+"use client";
+
+import { FormEvent, useState } from "react";
+import { useRouter } from "next/navigation";
+
+import { ApiError } from "@/lib/api/client";
+import { useLogin, useRegister } from "@/lib/hooks/queries/auth";
+
+/**
+ * The page to go to once signed in: the one the user was sent here from, if
+ * it's on this site.
+ */
+const nextPath = () => {
+  const next = new URLSearchParams(window.location.search).get("next");
+  return next?.startsWith("/") && !next.startsWith("//") ? next : "/snippets";
+};
+
+export default function LoginPage() {
+  const router = useRouter();
+  const [username, setUsername] = useState("");
+  const [password, setPassword] = useState("");
+  const login = useLogin();
+  const register = useRegister();
+  const error = login.error ?? register.error;
+
+  const handleSubmit = (event: FormEvent<HTMLFormElement>) => {
+    event.preventDefault();
+
+    // The button that submitted the form decides whether to sign in or sign up
+    const submitter = (event.nativeEvent as SubmitEvent).submitter as HTMLButtonElement | null;
+    const mutation = submitter?.value === "register" ? register : login;
+
+    mutation.mutate({ username, password }, { onSuccess: () => router.replace(nextPath()) });
+  };
+
+  return (
+    <form className="flex flex-col gap-2 max-w-sm" onSubmit={handleSubmit}>
+      {error && <p className="font-bold text-red-400">{error instanceof ApiError ? error.message : "An error occurred"}</p>}
+      <input
+        className="border px-2 py-1"
+        placeholder="Username"
+        autoComplete="username"
+        value={username}
+        onChange={(e) => setUsername(e.target.value)}
+      />
+      <input
+        className="border px-2 py-1"
+        type="password"
+        placeholder="Password"
+        autoComplete="current-password"
+        value={password}
+        onChange={(e) => setPassword(e.target.value)}
+      />
+      <div className="flex gap-2">
+        <button type="submit" value="login" disabled={login.isPending || register.isPending}>
+          Sign in
+        </button>
+        <button type="submit" value="register" disabled={login.isPending || register.isPending}>
+          Create account
+        </button>
+      </div>
+    </form>
+  );
+}
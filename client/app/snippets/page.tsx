@@ -1,7 +1,6 @@
 "use client";
 
-import { isAxiosError } from "axios";
-
+import { ApiError } from "@/lib/api/client";
 import { useSnippets, useUpdateSnippet } from "@/lib/hooks/queries/snippets";
 import { Snippet } from "@/lib/types";
 
@@ -17,10 +16,10 @@ export default function SnippetsPage() {
 
   return (
     <div>
-      {snippetsError && isAxiosError(snippetsError) && (
+      {snippetsError && (
         <>
           <p className="font-bold text-red-400 text-xl">An error occurred</p>
-          <p className="font-bold text-red-400">{snippetsError.status}</p>
+          {snippetsError instanceof ApiError && <p className="font-bold text-red-400">{snippetsError.status}</p>}
           <p className="font-bold text-red-400">{snippetsError.message}</p>
         </>
       )}
@@ -0,0 +1,62 @@
+import client, { setToken } from "./client";
+import { Session, User } from "../types";
+
+/**
+ * Sign in with a username and password. The session token is kept, so that
+ * every request made after is made as the user.
+ *
+ * An error will be thrown if the request fails, with a 401 if the username or
+ * password is wrong.
+ * @throws
+ * @param username The username of the user.
+ * @param password The password of the user.
+ * @returns The new session.
+ */
+export const login = async (username: string, password: string) => {
+  const { data: session } = await client.post<Session>("/auth/login", { username, password });
+  setToken(session.token);
+  return session;
+};
+
+/**
+ * Create a new account and sign in with it.
+ *
+ * An error will be thrown if the request fails, with a 409 if the username is
+ * already taken.
+ * @throws
+ * @param username The username of the new user.
+ * @param password The password of the new user.
+ * @returns The new session.
+ */
+export const register = async (username: string, password: string) => {
+  await client.post<User>("/auth/register", { username, password });
+  return login(username, password);
+};
+
+/**
+ * Sign out, revoking the session token. The token is forgotten even if the
+ * request fails.
+ *
+ * An error will be thrown if the request fails.
+ * @throws
+ */
+export const logout = async () => {
+  try {
+    await client.post<void>("/auth/logout");
+  } finally {
+    setToken(null);
+  }
+};
+
+/**
+ * Fetch the signed-in user.
+ *
+ * An error will be thrown if the request fails, with a 401 if no one is signed
+ * in.
+ * @throws
+ * @returns The signed-in user.
+ */
+export const getMe = async () => {
+  const { data: user } = await client.get<User>("/auth/me");
+  return user;
+};
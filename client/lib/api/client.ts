@@ -1,4 +1,67 @@
-import axios from "axios";
+import axios, { isAxiosError } from "axios";
+
+import { FieldError, Problem } from "../types";
+
+/**
+ * An error response from the API, described by its RFC 7807 problem details.
+ */
+export class ApiError extends Error {
+  readonly status: number;
+  readonly code: string;
+  readonly title: string;
+  /** Problems with the fields of the request, if any */
+  readonly fieldErrors: FieldError[];
+
+  constructor(problem: Problem, options?: ErrorOptions) {
+    super(problem.detail, options);
+    this.name = "ApiError";
+    this.status = problem.status;
+    this.code = problem.code;
+    this.title = problem.title;
+    this.fieldErrors = problem.errors ?? [];
+  }
+
+  /**
+   * The problems with a field of the request, given the JSON Pointer to it.
+   */
+  errorsFor(field: string) {
+    return this.fieldErrors.filter((e) => e.field === field);
+  }
+}
+
+/**
+ * Whether data is a problem details object.
+ */
+const isProblem = (data: unknown): data is Problem =>
+  typeof data === "object" && data !== null && "status" in data && "code" in data && "detail" in data;
+
+/** The page users are sent to when they need to sign in */
+export const loginPath = "/login";
+
+/** The local storage key the session token is kept under */
+const tokenKey = "cstash.token";
+
+/**
+ * The session token of the signed-in user, or null if no one is signed in.
+ */
+export const getToken = () => (typeof window === "undefined" ? null : window.localStorage.getItem(tokenKey));
+
+/**
+ * Keep the session token to make requests with, or forget it if null.
+ */
+export const setToken = (token: string | null) => {
+  if (token === null) {
+    window.localStorage.removeItem(tokenKey);
+  } else {
+    window.localStorage.setItem(tokenKey, token);
+  }
+};
+
+/**
+ * Whether a request was made to sign in, in which case a 401 means the
+ * credentials were wrong rather than the session expired.
+ */
+const isLogin = (url?: string) => url !== undefined && /(^|\/)auth\/(login|register)$/.test(url);
 
 const client = axios.create({
   baseURL: "http://localhost:3000/api/v1/",
@@ -7,4 +70,35 @@ const client = axios.create({
   },
 });
 
+// Requests are made as the signed-in user, if any
+client.interceptors.request.use((config) => {
+  const token = getToken();
+
+  if (token) {
+    config.headers.Authorization = `Bearer ${token}`;
+  }
+
+  return config;
+});
+
+// Error responses are turned into an ApiError, so that callers don't have to
+// dig the problem out of the response. A 401 means the session is missing or
+// has expired, so the user is sent back to sign in.
+client.interceptors.response.use(undefined, (error) => {
+  if (isAxiosError(error) && error.response?.status === 401 && !isLogin(error.config?.url)) {
+    setToken(null);
+
+    if (window.location.pathname !== loginPath) {
+      const next = encodeURIComponent(window.location.pathname + window.location.search);
+      window.location.assign(`${loginPath}?next=${next}`);
+    }
+  }
+
+  if (isAxiosError(error) && isProblem(error.response?.data)) {
+    return Promise.reject(new ApiError(error.response.data, { cause: error }));
+  }
+
+  return Promise.reject(error);
+});
+
 export default client;
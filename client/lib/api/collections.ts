@@ -0,0 +1,126 @@
+import client from "./client";
+import { Collection, Snippet } from "../types";
+
+/**
+ * Get every collection, oldest first.
+ *
+ * An error will be thrown if the request fails.
+ * @throws
+ * @returns The collections.
+ */
+export const getCollections = async () => {
+  const { data: collections } = await client.get<Collection[]>("/collections");
+  return collections;
+};
+
+/**
+ * Fetch a single collection, provided a collection ID.
+ *
+ * An error will be thrown if the request fails.
+ * @throws
+ * @param id The ID of the collection to fetch.
+ * @returns The collection.
+ */
+export const getCollection = async (id: string) => {
+  const { data: collection } = await client.get<Collection>(`/collections/${id}`);
+  return collection;
+};
+
+/**
+ * Create a new empty collection.
+ *
+ * An error will be thrown if the request fails.
+ * @throws
+ * @param name The name of the collection.
+ * @param description What the collection is for.
+ * @returns The freshly created collection.
+ */
+export const createCollection = async (name: string, description: string = "") => {
+  const { data: collection } = await client.post<Collection>("/collections", { name, description });
+  return collection;
+};
+
+/**
+ * Replace the name and description of a collection.
+ *
+ * An error will be thrown if the request fails.
+ * @throws
+ * @param id The ID of the collection to update.
+ * @param name The new name of the collection.
+ * @param description The new description of the collection.
+ * @returns The updated collection.
+ */
+export const updateCollection = async (id: string, name: string, description: string = "") => {
+  const { data: collection } = await client.put<Collection>(`/collections/${id}`, { name, description });
+  return collection;
+};
+
+/**
+ * Delete a collection. The snippets in it are kept.
+ *
+ * An error will be thrown if the request fails.
+ * @throws
+ * @param id The ID of the collection to delete.
+ */
+export const deleteCollection = async (id: string) => {
+  await client.delete<void>(`/collections/${id}`);
+};
+
+/**
+ * Fetch the snippets in a collection, in order. Snippets that are no longer
+ * visible are left out.
+ *
+ * An error will be thrown if the request fails.
+ * @throws
+ * @param id The ID of the collection.
+ * @returns The snippets in the collection.
+ */
+export const getCollectionSnippets = async (id: string) => {
+  const { data: snippets } = await client.get<Snippet[]>(`/collections/${id}/snippets`);
+  return snippets;
+};
+
+/**
+ * Add a snippet to a collection.
+ *
+ * An error will be thrown if the request fails, with a 409 if the snippet is
+ * already in the collection.
+ * @throws
+ * @param id The ID of the collection.
+ * @param snippetId The ID of the snippet to add.
+ * @param position Where to add the snippet, from 0; it's added at the end if omitted.
+ * @returns The updated collection.
+ */
+export const addToCollection = async (id: string, snippetId: string, position?: number) => {
+  const { data: collection } = await client.post<Collection>(`/collections/${id}/snippets`, { snippetId, position });
+  return collection;
+};
+
+/**
+ * Put the snippets of a collection in a new order.
+ *
+ * An error will be thrown if the request fails, with a 422 if `snippetIds`
+ * doesn't list every snippet in the collection exactly once.
+ * @throws
+ * @param id The ID of the collection.
+ * @param snippetIds The IDs of the snippets in the collection, in their new order.
+ * @returns The updated collection.
+ */
+export const reorderCollection = async (id: string, snippetIds: string[]) => {
+  const { data: collection } = await client.put<Collection>(`/collections/${id}/snippets`, { snippetIds });
+  return collection;
+};
+
+/**
+ * Remove a snippet from a collection. The snippet itself is kept.
+ *
+ * An error will be thrown if the request fails.
+ * @throws
+ * @param id The ID of the collection.
+ * @param snippetId The ID of the snippet to remove.
+ * @returns The updated collection.
+ */
+export const removeFromCollection = async (id: string, snippetId: string) => {
+  const { data: collection } = await client.delete<Collection>(`/collections/${id}/snippets/${snippetId}`);
+  return collection;
+};
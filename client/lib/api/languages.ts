@@ -0,0 +1,15 @@
+import client from "./client";
+import { LanguageUsage } from "../types";
+
+/**
+ * Get every known language along with the number of snippets written in it,
+ * most used first.
+ *
+ * An error will be thrown if the request fails.
+ * @throws
+ * @returns The known languages.
+ */
+export const getLanguages = async () => {
+  const { data: languages } = await client.get<LanguageUsage[]>("/languages");
+  return languages;
+};
@@ -1,5 +1,8 @@
 import client from "./client";
-import { Snippet } from "../types";
+import { PageOptions, Snippet, SnippetPage, TagFilter } from "../types";
+
+/** The most snippets a page may hold */
+const maxPageLimit = 200;
 
 /**
  * Write a new snippet to the database, provided a snippet.
@@ -15,22 +18,82 @@ export const createSnippet = async (snippet: Partial<Snippet>) => {
 };
 
 /**
- * Fetch all the snippets in the database, optionally filtered by a query.
+ * Fetch a page of the snippets in the database, optionally filtered by a query
+ * and by tags.
+ *
+ * A query is a list of terms that must all match, like
+ * `lang:go tag:http -tag:deprecated "context.Context" created:>2025-01-01`.
+ * A term is a word, a "quoted phrase" or a qualifier: `tag:`, `lang:`,
+ * `is:favorite` (or private, team, public), `created:` and `updated:` with a
+ * date range like `2025-01-01..2025-02-01`, or `title:`, `desc:` and
+ * `content:` to look for a word or phrase in that field only. Terms can be
+ * joined with `OR`, negated with `-` or `NOT`, and grouped with parentheses.
+ * An invalid query is rejected with a 400.
  *
- * If a query is provided, each snippet must contain `query` as a subtring in at least
- * one field. An error will be thrown if the request fails.
+ * Each snippet must also have any of `filter.tags`, or all of them if
+ * `filter.tagMode` is `"all"`, and none of `filter.notTags`. A snippet has a tag
+ * if it has the tag or one below it, like `infra/k8s` for `infra`.
+ *
+ * Pages hold 50 snippets unless `page.limit` says otherwise, up to 200. The
+ * next page is fetched by passing the `nextCursor` of this one as
+ * `page.cursor`, along with the same query, filter and sort; it's absent on the
+ * last page. An error will be thrown if the request fails.
  * @throws
- * @param query The query to filter snippets.
- * @returns An array of Snippets.
+ * @param query The search query to filter snippets by.
+ * @param filter The tags to filter snippets by.
+ * @param page Which page to fetch, and how to sort the snippets.
+ * @returns A page of snippets, along with the number of snippets found across every page.
  */
-export const getSnippets = async (query: string = "") => {
-  let queryValues = "?";
+export const getSnippets = async (query: string = "", filter: TagFilter = {}, page: PageOptions = {}) => {
+  const params = new URLSearchParams();
 
   if (query) {
-    queryValues += `q=${query}`;
+    params.append("q", query);
+  }
+
+  filter.tags?.forEach((tag) => params.append("tags", tag));
+  filter.notTags?.forEach((tag) => params.append("notTags", tag));
+
+  if (filter.tagMode) {
+    params.append("tagMode", filter.tagMode);
   }
 
-  const { data: snippets } = await client.get<Snippet[]>(`/snippets${queryValues}`);
+  Object.entries(page).forEach(([name, value]) => {
+    if (value !== undefined) {
+      params.append(name, String(value));
+    }
+  });
+
+  const { data: snippetPage } = await client.get<SnippetPage>(`/snippets?${params}`);
+  return snippetPage;
+};
+
+/**
+ * Fetch every snippet in the database matching a query and tags, following
+ * each page to the next.
+ *
+ * The query and filter are those of `getSnippets`. An error will be thrown if
+ * any request fails.
+ * @throws
+ * @param query The search query to filter snippets by.
+ * @param filter The tags to filter snippets by.
+ * @param sort How to sort the snippets.
+ * @returns An array of Snippets.
+ */
+export const getAllSnippets = async (
+  query: string = "",
+  filter: TagFilter = {},
+  sort: Pick<PageOptions, "sort" | "order"> = {}
+) => {
+  const snippets: Snippet[] = [];
+  let cursor: string | undefined;
+
+  do {
+    const page = await getSnippets(query, filter, { ...sort, limit: maxPageLimit, cursor });
+    snippets.push(...page.snippets);
+    cursor = page.nextCursor;
+  } while (cursor);
+
   return snippets;
 };
 
@@ -48,16 +111,24 @@ export const getSnippet = async (id: string) => {
 };
 
 /**
- * Update a single snippet from the database, provided a snippet ID.
+ * Update a single snippet from the database, provided a snippet ID. The
+ * updates are sent as a JSON merge patch, so fields left out are unchanged.
  *
- * An error will be thrown if the request fails.
+ * If the version the updates were made to is provided, the update fails with a
+ * 412 when someone else changed the snippet since. An error will be thrown if
+ * the request fails.
  * @throws
  * @param id The ID of the snippet to update.
  * @param updates The updates to apply to the snippet.
+ * @param version The version of the snippet the updates were made to.
  * @returns The freshly updated snippet.
  */
-export const updateSnippet = async (id: string, updates: Partial<Snippet>) => {
-  const { data: updatedSnippet } = await client.put<Snippet>(`/snippets/${id}`, updates);
+export const updateSnippet = async (id: string, updates: Partial<Snippet>, version?: number) => {
+  const headers = {
+    "Content-Type": "application/merge-patch+json",
+    ...(version === undefined ? {} : { "If-Match": `"${version}"` }),
+  };
+  const { data: updatedSnippet } = await client.patch<Snippet>(`/snippets/${id}`, updates, { headers });
   return updatedSnippet;
 };
 
@@ -71,3 +142,20 @@ export const updateSnippet = async (id: string, updates: Partial<Snippet>) => {
 export const deleteSnippet = async (id: string) => {
   await client.delete<void>(`/snippets/${id}`);
 };
+
+/**
+ * Render the content of a snippet with its syntax highlighted, provided a
+ * snippet ID.
+ *
+ * An error will be thrown if the request fails.
+ * @throws
+ * @param id The ID of the snippet to render.
+ * @param format The format to render in: html, ansi or svg.
+ * @param theme The name of the theme to color the snippet with.
+ * @returns The rendered snippet.
+ */
+export const renderSnippet = async (id: string, format: "html" | "ansi" | "svg" = "html", theme?: string) => {
+  const params = { format, ...(theme === undefined ? {} : { theme }) };
+  const { data: rendered } = await client.get<string>(`/snippets/${id}/render`, { params, responseType: "text" });
+  return rendered;
+};
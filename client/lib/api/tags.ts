@@ -0,0 +1,70 @@
+import client from "./client";
+import { Tag, TagNode } from "../types";
+
+/**
+ * Get every tag along with its description, color and the number of snippets
+ * that have it, ordered by name.
+ *
+ * An error will be thrown if the request fails.
+ * @throws
+ * @returns The tags.
+ */
+export const getTags = async () => {
+  const { data: tags } = await client.get<Tag[]>("/tags");
+  return tags;
+};
+
+/**
+ * Get every tag arranged under the tags above it, like infra/k8s under infra,
+ * each level ordered by name.
+ *
+ * An error will be thrown if the request fails.
+ * @throws
+ * @returns The top-level tags.
+ */
+export const getTagTree = async () => {
+  const { data: tree } = await client.get<TagNode[]>("/tags", { params: { view: "tree" } });
+  return tree;
+};
+
+/**
+ * Rename a tag on every snippet that has it and replace its description and
+ * color. The tags below it are renamed along with it. The tag keeps its name
+ * if none is provided.
+ *
+ * An error will be thrown if the request fails, with a 409 if another tag
+ * already has the new name.
+ * @throws
+ * @param name The name of the tag to update.
+ * @param tag The new name, description and color of the tag.
+ * @returns The updated tag.
+ */
+export const updateTag = async (name: string, tag: Partial<Omit<Tag, "count">>) => {
+  const { data: updatedTag } = await client.put<Tag>(`/tags/${encodeURIComponent(name)}`, tag);
+  return updatedTag;
+};
+
+/**
+ * Replace several tags with a single one on every snippet that has them.
+ *
+ * An error will be thrown if the request fails.
+ * @throws
+ * @param sources The names of the tags to replace.
+ * @param target The name of the tag to replace them with.
+ * @returns The tag they were merged into.
+ */
+export const mergeTags = async (sources: string[], target: string) => {
+  const { data: mergedTag } = await client.post<Tag>("/tags/merge", { sources, target });
+  return mergedTag;
+};
+
+/**
+ * Remove a tag from every snippet that has it.
+ *
+ * An error will be thrown if the request fails.
+ * @throws
+ * @param name The name of the tag to delete.
+ */
+export const deleteTag = async (name: string) => {
+  await client.delete<void>(`/tags/${encodeURIComponent(name)}`);
+};
@@ -0,0 +1,43 @@
+import { useMutation, useQuery, useQueryClient } from "@tanstack/react-query";
+import { AxiosError } from "axios";
+import { Session, User } from "../../types";
+import { getMe, login, logout, register } from "../../api/auth";
+
+interface Credentials {
+  username: string;
+  password: string;
+}
+
+export const useMe = () =>
+  useQuery<User, AxiosError>({
+    queryKey: ["me"],
+    queryFn: () => getMe(),
+  });
+
+export const useLogin = () => {
+  const queryClient = useQueryClient();
+
+  return useMutation<Session, AxiosError, Credentials>({
+    mutationFn: ({ username, password }) => login(username, password),
+    // Everything fetched before was fetched as someone else, or no one
+    onSuccess: () => queryClient.invalidateQueries(),
+  });
+};
+
+export const useRegister = () => {
+  const queryClient = useQueryClient();
+
+  return useMutation<Session, AxiosError, Credentials>({
+    mutationFn: ({ username, password }) => register(username, password),
+    onSuccess: () => queryClient.invalidateQueries(),
+  });
+};
+
+export const useLogout = () => {
+  const queryClient = useQueryClient();
+
+  return useMutation<void, AxiosError>({
+    mutationFn: () => logout(),
+    onSettled: () => queryClient.clear(),
+  });
+};
@@ -1,7 +1,7 @@
 import { useMutation, UseMutationResult, useQuery, useQueryClient, UseQueryResult } from "@tanstack/react-query";
 import { Snippet } from "../../types";
 import { AxiosError } from "axios";
-import { createSnippet, deleteSnippet, getSnippet, getSnippets, updateSnippet } from "../../api/snippets";
+import { createSnippet, deleteSnippet, getAllSnippets, getSnippet, updateSnippet } from "../../api/snippets";
 
 type SortByKey =
   // keys of Snippet, capitalized and excluding the keys "updatedAt", "isFavorite", "createdAt", "tags", "id", and "content".
@@ -33,7 +33,9 @@ interface UseSnippetsProps {
 export const useSnippets = ({ query = "", filter = "Last Modified", order = "Descending" }: UseSnippetsProps) =>
   useQuery<Snippet[], AxiosError>({
     queryKey: ["snippets", query],
-    queryFn: () => getSnippets(query),
+    // Snippets are sorted here by fields the API can't sort by, so every page
+    // is fetched
+    queryFn: () => getAllSnippets(query),
     select: (snippets) => sortSnippets(snippets, filter, order),
   });
 
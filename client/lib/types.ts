@@ -1,5 +1,24 @@
+export type Visibility = "private" | "team" | "public";
+
+/** A cstash account */
+export interface User {
+    id: string;
+    username: string;
+    createdAt: Date;
+}
+
+/** A signed-in user's session, returned when they sign in */
+export interface Session {
+    /** Sent as "Authorization: Bearer <token>" with every request */
+    token: string;
+    expiresAt: Date;
+}
+
 export interface Snippet {
     id: string;
+    ownerId: string;
+    workspaceId: string;
+    visibility: Visibility;
     title: string;
     description: string;
     content: string;
@@ -8,4 +27,153 @@ export interface Snippet {
     createdAt: Date;
     updatedAt: Date;
     isFavorite: boolean;
+    /** Incremented by every change; sent back as If-Match to avoid overwriting someone else's change */
+    version: number;
+    /** Where a regex or fuzzy search matched the content, if it did */
+    matches?: SnippetMatch[];
+}
+
+/** A part of a snippet's content matched by a regex or fuzzy search */
+export interface SnippetMatch {
+    /** Byte offset of the start of the match */
+    start: number;
+    /** Byte offset of the end of the match */
+    end: number;
+    /** 1-based line the match starts on */
+    line: number;
+    text: string;
+    /** Similarity of a fuzzy match, from 0.4 to 1 */
+    score?: number;
+}
+
+/** A page of snippets returned by a snippet search */
+export interface SnippetPage {
+    snippets: Snippet[];
+    /** Fetches the next page; absent on the last page */
+    nextCursor?: string;
+    /** Number of snippets found across every page */
+    total: number;
+}
+
+/** Which page of a snippet search to fetch, and how to sort the snippets */
+export interface PageOptions {
+    /** Most snippets in the page, from 1 to 200; defaults to 50 */
+    limit?: number;
+    /** The nextCursor of the previous page, or absent for the first */
+    cursor?: string;
+    /** Defaults to "score" for searches with a query and "createdAt" otherwise */
+    sort?: "createdAt" | "updatedAt" | "title" | "score";
+    /** Defaults to "desc" for scores and "asc" otherwise */
+    order?: "asc" | "desc";
+}
+
+/** An immutable record of a snippet's fields after it was created or changed */
+export interface Revision {
+    snippetId: string;
+    number: number;
+    authorId: string;
+    createdAt: Date;
+    visibility: Visibility;
+    title: string;
+    description: string;
+    content: string;
+    language: string;
+    tags: string[];
+    isFavorite: boolean;
+}
+
+/** A line of a diff; `op` is " " for unchanged lines, "-" for removed and "+" for added ones */
+export interface DiffLine {
+    op: " " | "-" | "+";
+    text: string;
+    noNewline?: boolean;
+}
+
+/** A run of changed lines along with the unchanged lines around them */
+export interface DiffHunk {
+    fromLine: number;
+    fromCount: number;
+    toLine: number;
+    toCount: number;
+    lines: DiffLine[];
+}
+
+/** The difference between two snippets, or a snippet and a candidate version of it */
+export interface SnippetDiff {
+    from: string;
+    to: string;
+    /** The content diff in unified diff format */
+    unified: string;
+    hunks: DiffHunk[];
+    /** The metadata fields that differ */
+    fields: { field: string; from: unknown; to: unknown }[];
+}
+
+/** A problem with a field of a request */
+export interface FieldError {
+    /** JSON Pointer to the field, or empty for the whole request body */
+    field: string;
+    /** The kind of problem, like "required" or "too_long" */
+    code: string;
+    message: string;
+}
+
+/** An RFC 7807 problem details object, the body of every error response */
+export interface Problem {
+    type: string;
+    title: string;
+    status: number;
+    detail: string;
+    /** The snake-cased title, like "not_found" */
+    code: string;
+    errors?: FieldError[];
+}
+
+/** A language snippets can be written in, along with how many are */
+export interface LanguageUsage {
+    /** Canonical ID stored in Snippet.language */
+    id: string;
+    name: string;
+    aliases: string[];
+    extensions: string[];
+    /** Number of snippets written in the language */
+    count: number;
+}
+
+/** A tag of snippets, along with how many have it */
+export interface Tag {
+    name: string;
+    description: string;
+    /** Hex color like #d73a49, or empty */
+    color: string;
+    /** Number of snippets with the tag */
+    count: number;
+}
+
+export interface TagFilter {
+    tags?: string[];
+    /** Whether snippets need any of tags, the default, or all of them */
+    tagMode?: "any" | "all";
+    notTags?: string[];
+}
+
+export interface TagNode extends Tag {
+    /** Last level of the name, like helm for infra/k8s/helm */
+    label: string;
+    /** Number of snippets with the tag or any tag below it */
+    total: number;
+    children: TagNode[];
+}
+
+/** A named, ordered list of snippets; a snippet may be in any number of collections */
+export interface Collection {
+    id: string;
+    ownerId: string;
+    workspaceId: string;
+    name: string;
+    description: string;
+    /** IDs of the snippets in the collection, in order */
+    snippetIds: string[];
+    createdAt: Date;
+    updatedAt: Date;
 }
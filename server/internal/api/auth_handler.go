@@ -0,0 +1,269 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/villaleo/cstash/internal/auth"
+	"github.com/villaleo/cstash/internal/models"
+	"github.com/villaleo/cstash/internal/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	minPasswordLength = 8
+	// bcrypt ignores everything past 72 bytes
+	maxPasswordLength = 72
+
+	// dummyPasswordHash is compared against when logging in as a user who
+	// doesn't exist or has no password, so that those attempts take as long as
+	// a wrong password and don't reveal which usernames are taken. It has the
+	// same cost as the hashes of real passwords.
+	dummyPasswordHash = "$2a$10$Rj4TAIEFXit52oFhNPwecOApcnmld0kHfrVAPv75WJRGw.DrMouuO"
+)
+
+var (
+	errInvalidCredentials = errors.New("invalid username or password")
+	errUnauthorized       = errors.New("unauthorized")
+	errInvalidUsername    = errors.New("username must be 3-32 characters of a-z, 0-9, '.', '_' or '-'")
+	errInvalidPassword    = fmt.Errorf("password must be %d-%d characters", minPasswordLength, maxPasswordLength)
+
+	usernamePattern = regexp.MustCompile(`^[a-z0-9._-]{3,32}$`)
+)
+
+// credentials is the request body of the register and login routes
+type credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// AuthHandler handles account registration and session requests
+type AuthHandler struct {
+	store      storage.UserStore
+	sessionTTL time.Duration
+	logger     *zap.Logger
+}
+
+// NewAuthHandler creates a new auth handler. Sessions created by the handler
+// expire after sessionTTL.
+func NewAuthHandler(store storage.UserStore, sessionTTL time.Duration, logger *zap.Logger) *AuthHandler {
+	return &AuthHandler{
+		store:      store,
+		sessionTTL: sessionTTL,
+		logger:     logger.Named("auth"),
+	}
+}
+
+// Logger simply returns this handler's logger. This method is implemented to
+// satisfy logHandler.
+func (h *AuthHandler) Logger() *zap.Logger {
+	return h.logger
+}
+
+// RegisterRoutes registers the auth API routes
+func (h *AuthHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/v1/auth/register", h.Register)
+	mux.HandleFunc("POST /api/v1/auth/login", h.Login)
+	mux.HandleFunc("POST /api/v1/auth/logout", h.Logout)
+	mux.HandleFunc("GET /api/v1/auth/me", h.Me)
+}
+
+// PublicRoutes returns the paths of the auth routes that don't require the
+// caller to be signed in
+func (h *AuthHandler) PublicRoutes() []string {
+	return []string{"/api/v1/auth/register", "/api/v1/auth/login"}
+}
+
+// Register handles creating a new user account
+func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var (
+		creds credentials
+		sugar = h.logger.Sugar()
+	)
+
+	if err := decodeInto(r.Body, &creds); err != nil {
+		sugar.Debug(err)
+		writeBodyError(w, err)
+
+		return
+	}
+
+	creds.Username = strings.ToLower(strings.TrimSpace(creds.Username))
+
+	if !usernamePattern.MatchString(creds.Username) {
+		WriteError(w, errInvalidUsername, http.StatusBadRequest)
+		return
+	}
+
+	if len(creds.Password) < minPasswordLength || len(creds.Password) > maxPasswordLength {
+		WriteError(w, errInvalidPassword, http.StatusBadRequest)
+		return
+	}
+
+	hash, err := auth.HashPassword(creds.Password)
+	if err != nil {
+		sugar.Error(err)
+		WriteError(w, errInternal, http.StatusInternalServerError)
+
+		return
+	}
+
+	user := models.NewUser(creds.Username, hash)
+
+	if err := h.store.CreateUser(r.Context(), user); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrUserExists):
+			sugar.Debug(err)
+			WriteError(w, err, http.StatusConflict)
+
+			return
+		default:
+			sugar.Error(err)
+			WriteError(w, errInternal, http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	sugar.Debugw("user registered", "user.id", user.ID)
+	w.WriteHeader(http.StatusCreated)
+
+	encodeJSON(h, w, user)
+}
+
+// Login handles exchanging a username and password for a session token
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var (
+		creds credentials
+		sugar = h.logger.Sugar()
+	)
+
+	if err := decodeInto(r.Body, &creds); err != nil {
+		sugar.Debug(err)
+		writeBodyError(w, err)
+
+		return
+	}
+
+	user, err := h.store.GetUserByUsername(r.Context(), strings.ToLower(strings.TrimSpace(creds.Username)))
+	if err != nil && !errors.Is(err, storage.ErrUserNotFound) {
+		sugar.Error(err)
+		WriteError(w, errInternal, http.StatusInternalServerError)
+
+		return
+	}
+
+	hash := dummyPasswordHash
+	if user != nil && user.PasswordHash != "" {
+		hash = user.PasswordHash
+	}
+
+	if !auth.CheckPassword(hash, creds.Password) || hash == dummyPasswordHash {
+		sugar.Debugw("failed login attempt", "user.username", creds.Username)
+		WriteError(w, errInvalidCredentials, http.StatusUnauthorized)
+
+		return
+	}
+
+	token, session, err := createSession(r.Context(), h.store, user.ID, h.sessionTTL)
+	if err != nil {
+		sugar.Error(err)
+		WriteError(w, errInternal, http.StatusInternalServerError)
+
+		return
+	}
+
+	sugar.Debugw("user logged in", "user.id", user.ID)
+
+	encodeJSON(h, w, map[string]any{
+		"token":     token,
+		"expiresAt": session.ExpiresAt,
+	})
+}
+
+// Logout handles revoking the session token the request was made with
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	sugar := h.logger.Sugar()
+
+	token, ok := auth.BearerToken(r.Header.Get("Authorization"))
+	if !ok {
+		WriteError(w, errUnauthorized, http.StatusUnauthorized)
+		return
+	}
+
+	err := h.store.DeleteSession(r.Context(), auth.HashToken(token))
+	if err != nil && !errors.Is(err, storage.ErrSessionNotFound) {
+		sugar.Error(err)
+		WriteError(w, errInternal, http.StatusInternalServerError)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Me handles retrieving the signed-in user
+func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	sugar := h.logger.Sugar()
+
+	id, ok := auth.IdentityFromContext(r.Context())
+	if !ok {
+		WriteError(w, errUnauthorized, http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.store.GetUser(r.Context(), id.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrUserNotFound):
+			sugar.Debug(err)
+			WriteError(w, err, http.StatusNotFound)
+
+			return
+		default:
+			sugar.Error(err)
+			WriteError(w, errInternal, http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	encodeJSON(h, w, user)
+}
+
+// createSession signs the user with userID in for ttl, returning the session
+// token to hand to the user
+func createSession(
+	ctx context.Context,
+	store storage.UserStore,
+	userID string,
+	ttl time.Duration,
+) (string, *models.Session, error) {
+	var (
+		token   = auth.NewToken()
+		now     = time.Now()
+		session = &models.Session{
+			TokenHash: auth.HashToken(token),
+			UserID:    userID,
+			CreatedAt: now,
+			ExpiresAt: now.Add(ttl),
+		}
+	)
+
+	if err := store.CreateSession(ctx, session); err != nil {
+		return "", nil, err
+	}
+
+	return token, session, nil
+}
@@ -0,0 +1,344 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/villaleo/cstash/internal/auth"
+	"github.com/villaleo/cstash/internal/models"
+	"github.com/villaleo/cstash/internal/storage"
+	"go.uber.org/zap"
+)
+
+var (
+	errSnippetInCollection    = errors.New("snippet is already in the collection")
+	errSnippetNotInCollection = errors.New("snippet is not in the collection")
+)
+
+// collectionRequest is the request body of the create and update collection
+// routes
+type collectionRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// addSnippetRequest is the request body of the add snippet to collection
+// route. The snippet is added at the end if Position is nil.
+type addSnippetRequest struct {
+	SnippetID string `json:"snippetId"`
+	Position  *int   `json:"position"`
+}
+
+// reorderSnippetsRequest is the request body of the reorder collection route
+type reorderSnippetsRequest struct {
+	SnippetIDs []string `json:"snippetIds"`
+}
+
+// CollectionHandler handles collection-related API requests
+type CollectionHandler struct {
+	store  storage.CollectionStore
+	logger *zap.Logger
+}
+
+// NewCollectionHandler creates a new collection handler
+func NewCollectionHandler(store storage.CollectionStore, logger *zap.Logger) *CollectionHandler {
+	return &CollectionHandler{
+		store:  store,
+		logger: logger.Named("collections"),
+	}
+}
+
+// Logger simply returns this handler's logger. This method is implemented to
+// satisfy logHandler.
+func (h *CollectionHandler) Logger() *zap.Logger {
+	return h.logger
+}
+
+// RegisterRoutes registers the collection API routes
+func (h *CollectionHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/v1/collections", h.CreateCollection)
+	mux.HandleFunc("GET /api/v1/collections", h.ListCollections)
+	mux.HandleFunc("GET /api/v1/collections/{id}", h.GetCollection)
+	mux.HandleFunc("PUT /api/v1/collections/{id}", h.UpdateCollection)
+	mux.HandleFunc("DELETE /api/v1/collections/{id}", h.DeleteCollection)
+
+	mux.HandleFunc("GET /api/v1/collections/{id}/snippets", h.ListSnippets)
+	mux.HandleFunc("POST /api/v1/collections/{id}/snippets", h.AddSnippet)
+	mux.HandleFunc("PUT /api/v1/collections/{id}/snippets", h.ReorderSnippets)
+	mux.HandleFunc("DELETE /api/v1/collections/{id}/snippets/{snippetId}", h.RemoveSnippet)
+}
+
+// CreateCollection handles creating a new empty collection owned by the
+// caller
+func (h *CollectionHandler) CreateCollection(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var (
+		req   collectionRequest
+		sugar = h.logger.Sugar()
+	)
+
+	if err := decodeInto(r.Body, &req); err != nil {
+		sugar.Debug(err)
+		writeBodyError(w, err)
+
+		return
+	}
+
+	collection := models.NewCollection(strings.TrimSpace(req.Name), req.Description)
+
+	if err := collection.Validate(); err != nil {
+		sugar.Debug(err)
+		WriteError(w, err, http.StatusUnprocessableEntity)
+
+		return
+	}
+
+	id, _ := auth.IdentityFromContext(r.Context())
+	collection.OwnerID = id.UserID
+
+	if err := h.store.CreateCollection(r.Context(), collection); err != nil {
+		writeCollectionError(h, w, err)
+		return
+	}
+
+	sugar.Debugw("collection created", "collection.id", collection.ID)
+	w.WriteHeader(http.StatusCreated)
+
+	encodeJSON(h, w, collection)
+}
+
+// ListCollections handles listing the collections the caller may view
+func (h *CollectionHandler) ListCollections(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	sugar := h.logger.Sugar()
+
+	collections, err := h.store.ListCollections(r.Context())
+	if err != nil {
+		sugar.Error(err)
+		WriteError(w, errInternal, http.StatusInternalServerError)
+
+		return
+	}
+
+	sugar.Debugw("fetched collections", "count", len(collections))
+
+	encodeJSON(h, w, collections)
+}
+
+// GetCollection handles retrieving a collection by ID
+func (h *CollectionHandler) GetCollection(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	collection, err := h.store.GetCollection(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeCollectionError(h, w, err)
+		return
+	}
+
+	encodeJSON(h, w, collection)
+}
+
+// UpdateCollection handles replacing the name and description of a collection
+func (h *CollectionHandler) UpdateCollection(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var (
+		req   collectionRequest
+		sugar = h.logger.Sugar()
+	)
+
+	if err := decodeInto(r.Body, &req); err != nil {
+		sugar.Debug(err)
+		writeBodyError(w, err)
+
+		return
+	}
+
+	h.updateCollection(w, r, func(collection *models.Collection) error {
+		collection.Name = strings.TrimSpace(req.Name)
+		collection.Description = req.Description
+
+		return collection.Validate()
+	})
+}
+
+// DeleteCollection handles deleting a collection. Its snippets are kept.
+func (h *CollectionHandler) DeleteCollection(w http.ResponseWriter, r *http.Request) {
+	var (
+		id    = r.PathValue("id")
+		sugar = h.logger.Sugar()
+	)
+
+	if err := h.store.DeleteCollection(r.Context(), id); err != nil {
+		writeCollectionError(h, w, err)
+		return
+	}
+
+	sugar.Debugw("collection deleted", "collection.id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListSnippets handles listing the snippets in a collection that the caller
+// may view, in the collection's order
+func (h *CollectionHandler) ListSnippets(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var (
+		id    = r.PathValue("id")
+		sugar = h.logger.Sugar()
+	)
+
+	snippets, err := h.store.ListCollectionSnippets(r.Context(), id)
+	if err != nil {
+		writeCollectionError(h, w, err)
+		return
+	}
+
+	sugar.Debugw("fetched collection snippets", "collection.id", id, "count", len(snippets))
+
+	encodeJSON(h, w, snippets)
+}
+
+// AddSnippet handles adding a snippet to a collection, at the position given
+// or at the end
+func (h *CollectionHandler) AddSnippet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var (
+		req   addSnippetRequest
+		sugar = h.logger.Sugar()
+	)
+
+	if err := decodeInto(r.Body, &req); err != nil {
+		sugar.Debug(err)
+		writeBodyError(w, err)
+
+		return
+	}
+
+	if req.SnippetID == "" {
+		err := models.ValidationError{{Field: "/snippetId", Code: models.CodeRequired, Message: "is required"}}
+		sugar.Debug(err)
+		WriteError(w, err, http.StatusUnprocessableEntity)
+
+		return
+	}
+
+	position := -1
+	if req.Position != nil {
+		position = *req.Position
+	}
+
+	h.updateCollection(w, r, func(collection *models.Collection) error {
+		if collection.Contains(req.SnippetID) {
+			return errSnippetInCollection
+		}
+
+		collection.Insert(req.SnippetID, position)
+
+		return collection.Validate()
+	})
+}
+
+// ReorderSnippets handles putting the snippets of a collection in a new order.
+// The request must list every snippet in the collection exactly once.
+func (h *CollectionHandler) ReorderSnippets(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var (
+		req   reorderSnippetsRequest
+		sugar = h.logger.Sugar()
+	)
+
+	if err := decodeInto(r.Body, &req); err != nil {
+		sugar.Debug(err)
+		writeBodyError(w, err)
+
+		return
+	}
+
+	h.updateCollection(w, r, func(collection *models.Collection) error {
+		if !collection.Reorder(req.SnippetIDs) {
+			return models.ValidationError{{
+				Field:   "/snippetIds",
+				Code:    models.CodeInvalidValue,
+				Message: "must list every snippet in the collection exactly once",
+			}}
+		}
+
+		return nil
+	})
+}
+
+// RemoveSnippet handles removing a snippet from a collection. The snippet
+// itself is kept.
+func (h *CollectionHandler) RemoveSnippet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	snippetID := r.PathValue("snippetId")
+
+	h.updateCollection(w, r, func(collection *models.Collection) error {
+		if !collection.Remove(snippetID) {
+			return errSnippetNotInCollection
+		}
+
+		return nil
+	})
+}
+
+// updateCollection applies change to the collection whose ID is in the path
+// of r and writes the result
+func (h *CollectionHandler) updateCollection(w http.ResponseWriter, r *http.Request, change func(collection *models.Collection) error) {
+	var (
+		id    = r.PathValue("id")
+		sugar = h.logger.Sugar()
+	)
+
+	collection, err := h.store.UpdateCollection(r.Context(), id, change)
+	if err != nil {
+		writeCollectionError(h, w, err)
+		return
+	}
+
+	sugar.Debugw("collection updated", "collection.id", id, "count", len(collection.SnippetIDs))
+
+	encodeJSON(h, w, collection)
+}
+
+// writeCollectionError replies to a request whose collection call failed with
+// err
+func writeCollectionError(h logHandler, w http.ResponseWriter, err error) {
+	var (
+		sugar   = h.Logger().Sugar()
+		invalid models.ValidationError
+	)
+
+	switch {
+	case errors.As(err, &invalid):
+		sugar.Debug(err)
+		WriteError(w, err, http.StatusUnprocessableEntity)
+	case errors.Is(err, storage.ErrCollectionNotFound), errors.Is(err, errSnippetNotInCollection):
+		sugar.Debug(err)
+		WriteError(w, err, http.StatusNotFound)
+	case errors.Is(err, storage.ErrSnippetNotFound):
+		// The snippet being added is part of the request body, not its path
+		sugar.Debug(err)
+		WriteError(w, models.ValidationError{{
+			Field:   "/snippetId",
+			Code:    models.CodeInvalidValue,
+			Message: "is not a snippet you may view",
+		}}, http.StatusUnprocessableEntity)
+	case errors.Is(err, storage.ErrForbidden):
+		sugar.Debug(err)
+		WriteError(w, err, http.StatusForbidden)
+	case errors.Is(err, errSnippetInCollection):
+		sugar.Debug(err)
+		WriteError(w, err, http.StatusConflict)
+	default:
+		sugar.Error(err)
+		WriteError(w, errInternal, http.StatusInternalServerError)
+	}
+}
@@ -23,7 +23,7 @@ func decodeInto(r io.ReadCloser, val any) error {
 	decoder.DisallowUnknownFields()
 
 	if err := decoder.Decode(&val); err != nil {
-		return err
+		return describeDecodeError(err)
 	}
 
 	return nil
@@ -35,7 +35,7 @@ func decodeInto(r io.ReadCloser, val any) error {
 func encodeJSON(h logHandler, w http.ResponseWriter, val any) {
 	if err := json.NewEncoder(w).Encode(val); err != nil {
 		h.Logger().Sugar().Error(err)
-		http.Error(w, errInternal.Error(), http.StatusInternalServerError)
+		WriteError(w, errInternal, http.StatusInternalServerError)
 
 		return
 	}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/villaleo/cstash/internal/diff"
+	"github.com/villaleo/cstash/internal/models"
+	"github.com/villaleo/cstash/internal/storage"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+var errMissingAgainst = errors.New("against must be the ID of the snippet to compare with")
+
+// snippetDiff is the difference between two versions of a snippet, or two
+// snippets. Unified and Hunks hold the same diff of their contents.
+type snippetDiff struct {
+	From    string        `json:"from"`
+	To      string        `json:"to"`
+	Unified string        `json:"unified"`
+	Hunks   []diff.Hunk   `json:"hunks"`
+	Fields  []fieldChange `json:"fields"`
+}
+
+// fieldChange is a metadata field that differs between two snippets
+type fieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// DiffSnippet handles comparing a snippet with the snippet given by the
+// against query parameter. The diff shows the changes turning the other
+// snippet into this one.
+func (h *SnippetHandler) DiffSnippet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var (
+		id      = r.PathValue("id")
+		against = r.URL.Query().Get("against")
+		sugar   = h.logger.Sugar()
+	)
+
+	if against == "" {
+		sugar.Debug(errMissingAgainst)
+		WriteError(w, errMissingAgainst, http.StatusBadRequest)
+
+		return
+	}
+
+	snippet, ok := h.getSnippet(w, r, id)
+	if !ok {
+		return
+	}
+
+	other, ok := h.getSnippet(w, r, against)
+	if !ok {
+		return
+	}
+
+	sugar.Debugw("diffed snippets", "snippet.id", id, "against", against)
+
+	encodeJSON(h, w, newSnippetDiff(other, snippet, "a/"+other.ID, "b/"+snippet.ID))
+}
+
+// DiffCandidate handles comparing a snippet with a candidate version of it
+// sent in the request body. Fields missing from the candidate are taken from
+// the snippet. The diff shows the changes turning the snippet into the
+// candidate; nothing is saved.
+func (h *SnippetHandler) DiffCandidate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var (
+		id    = r.PathValue("id")
+		sugar = h.logger.Sugar()
+	)
+
+	snippet, ok := h.getSnippet(w, r, id)
+	if !ok {
+		return
+	}
+
+	// Decoding into a copy of the stored tags would overwrite them in place
+	candidate := *snippet
+	candidate.Tags = slices.Clone(snippet.Tags)
+
+	if err := decodeInto(r.Body, &candidate); err != nil {
+		sugar.Debug(err)
+		writeBodyError(w, err)
+
+		return
+	}
+
+	candidate.ID = snippet.ID
+
+	sugar.Debugw("diffed snippet with candidate", "snippet.id", id)
+
+	encodeJSON(h, w, newSnippetDiff(snippet, &candidate, "a/"+snippet.ID, "b/"+snippet.ID))
+}
+
+// getSnippet fetches the snippet with id for a request, writing the error
+// response and returning false if it can't
+func (h *SnippetHandler) getSnippet(w http.ResponseWriter, r *http.Request, id string) (*models.Snippet, bool) {
+	sugar := h.logger.Sugar()
+
+	snippet, err := h.store.GetSnippet(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrSnippetNotFound):
+			sugar.Debugw(err.Error(), "snippet.id", id)
+			WriteError(w, err, http.StatusNotFound)
+
+			return nil, false
+		default:
+			sugar.Error(err)
+			WriteError(w, errInternal, http.StatusInternalServerError)
+
+			return nil, false
+		}
+	}
+
+	return snippet, true
+}
+
+// newSnippetDiff returns the diff turning from into to, naming their contents
+// fromName and toName in the unified diff
+func newSnippetDiff(from, to *models.Snippet, fromName, toName string) *snippetDiff {
+	hunks := diff.Hunks(diff.Lines(from.Content, to.Content), diffContext)
+	if hunks == nil {
+		hunks = []diff.Hunk{}
+	}
+
+	return &snippetDiff{
+		From:    from.ID,
+		To:      to.ID,
+		Unified: diff.Unified(fromName, toName, hunks),
+		Hunks:   hunks,
+		Fields:  metadataChanges(from, to),
+	}
+}
+
+// metadataChanges returns the metadata fields that differ between from and
+// to, in the order they're declared in models.Snippet
+func metadataChanges(from, to *models.Snippet) []fieldChange {
+	changes := []fieldChange{}
+
+	add := func(field string, from, to any, changed bool) {
+		if changed {
+			changes = append(changes, fieldChange{Field: field, From: from, To: to})
+		}
+	}
+
+	add("visibility", from.Visibility, to.Visibility, from.Visibility != to.Visibility)
+	add("title", from.Title, to.Title, from.Title != to.Title)
+	add("description", from.Description, to.Description, from.Description != to.Description)
+	add("language", from.Language, to.Language, from.Language != to.Language)
+	add("tags", from.Tags, to.Tags, !slices.Equal(from.Tags, to.Tags))
+	add("isFavorite", from.IsFavorite, to.IsFavorite, from.IsFavorite != to.IsFavorite)
+
+	return changes
+}
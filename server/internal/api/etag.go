@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/villaleo/cstash/internal/models"
+	"github.com/villaleo/cstash/internal/storage"
+)
+
+// snippetETag returns the entity tag of snippet, its quoted version
+func snippetETag(snippet *models.Snippet) string {
+	return strconv.Quote(strconv.FormatInt(snippet.Version, 10))
+}
+
+// setSnippetETag sets the ETag header of w to the entity tag of snippet
+func setSnippetETag(w http.ResponseWriter, snippet *models.Snippet) {
+	w.Header().Set("ETag", snippetETag(snippet))
+}
+
+// withIfMatch returns r with a context making its changes conditional on the
+// versions named by its If-Match header, if it has one. Weak entity tags
+// never match, and "*" matches any version.
+func withIfMatch(r *http.Request) *http.Request {
+	header := r.Header.Get("If-Match")
+	if header == "" || strings.TrimSpace(header) == "*" {
+		return r
+	}
+
+	versions := []int64{}
+
+	for _, tag := range strings.Split(header, ",") {
+		value, err := strconv.Unquote(strings.TrimSpace(tag))
+		if err != nil {
+			continue
+		}
+
+		if version, err := strconv.ParseInt(value, 10, 64); err == nil {
+			versions = append(versions, version)
+		}
+	}
+
+	return r.WithContext(storage.WithIfMatch(r.Context(), versions))
+}
+
+// noneMatch reports whether the If-None-Match header of r names none of the
+// entity tags of snippet, comparing tags weakly
+func noneMatch(r *http.Request, snippet *models.Snippet) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return true
+	}
+
+	etag := snippetETag(snippet)
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return false
+		}
+	}
+
+	return true
+}
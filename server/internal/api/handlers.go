@@ -1,29 +1,45 @@
 package api
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/villaleo/cstash/internal/auth"
 	"github.com/villaleo/cstash/internal/models"
+	"github.com/villaleo/cstash/internal/search"
 	"github.com/villaleo/cstash/internal/storage"
 	"go.uber.org/zap"
 )
 
 var (
-	errInternal = errors.New("an internal server error occurred")
+	errInternal       = errors.New("an internal server error occurred")
+	errInvalidMode    = errors.New("mode must be one of text, regex or fuzzy")
+	errInvalidTagMode = errors.New("tagMode must be all or any")
+	errSearchTimeout  = errors.New("search took too long; try a more specific pattern")
+	errInvalidLimit   = fmt.Errorf("limit must be a number from 1 to %d", storage.MaxPageLimit)
+	errInvalidSort    = errors.New("sort must be one of updatedAt, createdAt, title or score")
+	errInvalidOrder   = errors.New("order must be asc or desc")
 )
 
+// searchTimeout bounds how long a regex or fuzzy search may look through
+// snippets
+const searchTimeout = 2 * time.Second
+
 // SnippetHandler handles snippet-related API requests
 type SnippetHandler struct {
-	store  *storage.MemoryStore
+	store  storage.Store
 	logger *zap.Logger
 }
 
 // NewSnippetHandler creates a new snippet handler
-func NewSnippetHandler(store *storage.MemoryStore, logger *zap.Logger) *SnippetHandler {
+func NewSnippetHandler(store storage.Store, logger *zap.Logger) *SnippetHandler {
 	return &SnippetHandler{
 		store:  store,
 		logger: logger.Named("snippets"),
@@ -42,7 +58,17 @@ func (h *SnippetHandler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /api/v1/snippets", h.ListSnippets)
 	mux.HandleFunc("GET /api/v1/snippets/{id}", h.GetSnippet)
 	mux.HandleFunc("PUT /api/v1/snippets/{id}", h.UpdateSnippet)
+	mux.HandleFunc("PATCH /api/v1/snippets/{id}", h.PatchSnippet)
 	mux.HandleFunc("DELETE /api/v1/snippets/{id}", h.DeleteSnippet)
+
+	mux.HandleFunc("GET /api/v1/snippets/{id}/revisions", h.ListRevisions)
+	mux.HandleFunc("GET /api/v1/snippets/{id}/revisions/{rev}", h.GetRevision)
+	mux.HandleFunc("POST /api/v1/snippets/{id}/revisions/{rev}/restore", h.RestoreRevision)
+
+	mux.HandleFunc("GET /api/v1/snippets/{id}/diff", h.DiffSnippet)
+	mux.HandleFunc("POST /api/v1/snippets/{id}/diff", h.DiffCandidate)
+
+	mux.HandleFunc("GET /api/v1/snippets/{id}/render", h.RenderSnippet)
 }
 
 // CreateSnippet handles creating a new snippet
@@ -56,26 +82,55 @@ func (h *SnippetHandler) CreateSnippet(w http.ResponseWriter, r *http.Request) {
 	)
 
 	if err := decodeInto(r.Body, &newSnippet); err != nil {
-		e := fmt.Errorf("bad request: %w", err)
-		sugar.Debug(e)
-		http.Error(w, e.Error(), http.StatusBadRequest)
+		sugar.Debug(err)
+		writeBodyError(w, err)
 
 		return
 	}
 
-	// Ensure the new snippet is assigned an ID and the times are set
+	if newSnippet.Visibility == "" {
+		newSnippet.Visibility = models.VisibilityPrivate
+	}
+
+	newSnippet.NormalizeLanguage()
+	newSnippet.NormalizeTags()
+
+	if err := newSnippet.Validate(); err != nil {
+		sugar.Debug(err)
+		WriteError(w, err, http.StatusUnprocessableEntity)
+
+		return
+	}
+
+	// Ensure the new snippet is assigned an ID, owner and the times are set
+	id, _ := auth.IdentityFromContext(r.Context())
 	newSnippet.ID = auth.NewSecureID()
+	newSnippet.OwnerID = id.UserID
 	newSnippet.CreatedAt = time.Now()
 	newSnippet.UpdatedAt = time.Now()
 
-	if err := h.store.CreateSnippet(&newSnippet); err != nil {
-		sugar.Error(err)
-		http.Error(w, errInternal.Error(), http.StatusInternalServerError)
+	if err := h.store.CreateSnippet(r.Context(), &newSnippet); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrSnippetExists):
+			sugar.Debug(err)
+			WriteError(w, err, http.StatusConflict)
 
-		return
+			return
+		case errors.Is(err, storage.ErrForbidden):
+			sugar.Debug(err)
+			WriteError(w, err, http.StatusForbidden)
+
+			return
+		default:
+			sugar.Error(err)
+			WriteError(w, errInternal, http.StatusInternalServerError)
+
+			return
+		}
 	}
 
 	sugar.Debugw("snippet created", "snippet.id", newSnippet.ID)
+	setSnippetETag(w, &newSnippet)
 	w.WriteHeader(http.StatusCreated)
 
 	response = map[string]any{
@@ -86,29 +141,139 @@ func (h *SnippetHandler) CreateSnippet(w http.ResponseWriter, r *http.Request) {
 }
 
 // ListSnippets handles listing all snippets with optional tag filtering and
-// query filtering
+// query filtering. The query is written in the language of search.ParseQuery.
+// Snippets must have any of the tags, or all of them if tagMode is all, none
+// of notTags, and match the query.
 func (h *SnippetHandler) ListSnippets(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	var (
 		tagsQuery = r.URL.Query()["tags"]
 		query     = r.URL.Query().Get("q")
-		results   = []*models.Snippet{}
+		mode      = r.URL.Query().Get("mode")
 		sugar     = h.logger.Sugar()
 	)
 
-	results = h.store.ListSnippets(tagsQuery, query)
+	filter, err := parseSnippetFilter(r.URL.Query())
+	if err != nil {
+		sugar.Debug(err)
+		WriteError(w, err, http.StatusBadRequest)
+
+		return
+	}
+
+	page, err := parsePage(r.URL.Query())
+	if err != nil {
+		sugar.Debug(err)
+		WriteError(w, err, http.StatusBadRequest)
+
+		return
+	}
 
-	if len(results) == 0 && query != "" {
+	ctx, cancel := context.WithTimeout(r.Context(), searchTimeout)
+	defer cancel()
+
+	results, err := h.store.ListSnippets(ctx, filter, page)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrInvalidCursor):
+			sugar.Debug(err)
+			WriteError(w, err, http.StatusBadRequest)
+
+			return
+		case errors.Is(err, context.DeadlineExceeded):
+			sugar.Debugw("search timed out", "query", query, "mode", mode)
+			WriteError(w, errSearchTimeout, http.StatusServiceUnavailable)
+
+			return
+		default:
+			sugar.Error(err)
+			WriteError(w, errInternal, http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	if results.Total == 0 && query != "" {
 		sugar.Debugf("couldn't find snippets for query %q", query)
 		w.WriteHeader(http.StatusNotFound)
 	}
 
-	sugar.Debugw("fetched snippets", "count", len(results), "tags", tagsQuery, "query", query)
+	sugar.Debugw("fetched snippets", "count", len(results.Snippets), "total", results.Total, "tags", tagsQuery, "query", query)
 
 	encodeJSON(h, w, results)
 }
 
+// parseSnippetFilter builds the filter for a snippet search from the tags,
+// tagMode, notTags, q and mode of values. In text mode, the default, q is
+// parsed with search.ParseQuery. In regex and fuzzy mode, it's a pattern
+// looked for in the content of snippets.
+func parseSnippetFilter(values url.Values) (storage.SnippetFilter, error) {
+	var (
+		query  = values.Get("q")
+		filter = storage.SnippetFilter{
+			Tags:    values["tags"],
+			TagMode: storage.TagMode(values.Get("tagMode")),
+			NotTags: values["notTags"],
+		}
+		err error
+	)
+
+	if filter.TagMode == "" {
+		filter.TagMode = storage.TagModeAny
+	}
+
+	if !filter.TagMode.Valid() {
+		return filter, errInvalidTagMode
+	}
+
+	switch values.Get("mode") {
+	case "", "text":
+		filter.Query, err = search.ParseQuery(query)
+	case "regex":
+		if query != "" {
+			filter.Pattern, err = search.NewRegex(query)
+		}
+	case "fuzzy":
+		if strings.TrimSpace(query) != "" {
+			filter.Pattern, err = search.NewFuzzy(query)
+		}
+	default:
+		err = errInvalidMode
+	}
+
+	return filter, err
+}
+
+// parsePage reads the limit, cursor, sort and order of a page of snippets from
+// query
+func parsePage(query url.Values) (storage.Page, error) {
+	page := storage.Page{
+		Cursor: query.Get("cursor"),
+		Sort:   storage.SortField(query.Get("sort")),
+		Order:  storage.SortOrder(query.Get("order")),
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > storage.MaxPageLimit {
+			return page, errInvalidLimit
+		}
+
+		page.Limit = n
+	}
+
+	if page.Sort != "" && !page.Sort.Valid() {
+		return page, errInvalidSort
+	}
+
+	if page.Order != "" && !page.Order.Valid() {
+		return page, errInvalidOrder
+	}
+
+	return page, nil
+}
+
 // GetSnippet handles retrieving a snippet by ID
 func (h *SnippetHandler) GetSnippet(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -118,68 +283,71 @@ func (h *SnippetHandler) GetSnippet(w http.ResponseWriter, r *http.Request) {
 		sugar     = h.logger.Sugar()
 	)
 
-	snippet, err := h.store.GetSnippet(snippetId)
+	snippet, err := h.store.GetSnippet(r.Context(), snippetId)
 
 	if err != nil {
 		switch {
 		case errors.Is(err, storage.ErrSnippetNotFound):
 			sugar.Debug(err.Error())
-			http.Error(w, err.Error(), http.StatusNotFound)
+			WriteError(w, err, http.StatusNotFound)
 
 			return
 		default:
 			sugar.Error(err)
-			http.Error(w, errInternal.Error(), http.StatusInternalServerError)
+			WriteError(w, errInternal, http.StatusInternalServerError)
 
 			return
 		}
 	}
 
+	setSnippetETag(w, snippet)
+	w.Header().Set("Accept-Patch", mergePatchType+", "+jsonPatchType)
+
+	if !noneMatch(r, snippet) {
+		sugar.Debugw("snippet not modified", "snippet.id", snippetId)
+		w.WriteHeader(http.StatusNotModified)
+
+		return
+	}
+
 	sugar.Debugw("fetched snippet", "count", 1)
 
 	encodeJSON(h, w, snippet)
 }
 
-// UpdateSnippet handles updating an existing snippet
+// UpdateSnippet handles replacing the fields of an existing snippet with
+// those of the request body. Fields left out are reset to the values of a new
+// snippet, except read-only fields, which may be left out or sent unchanged.
 func (h *SnippetHandler) UpdateSnippet(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("Content-Type", "application/json")
 
 	var (
-		snippetId = r.PathValue("id")
-		updates   = make(map[string]any, 10)
-		err       error
-		sugar     = h.logger.Sugar()
+		replacement map[string]any
+		sugar       = h.logger.Sugar()
 	)
 
-	if err := decodeInto(r.Body, &updates); err != nil {
-		http.Error(w, errInternal.Error(), http.StatusInternalServerError)
-		sugar.Error(err)
+	if err := decodeInto(r.Body, &replacement); err != nil {
+		sugar.Debug(err)
+		writeBodyError(w, err)
 
 		return
 	}
 
-	snippet, err := h.store.UpdateSnippet(snippetId, updates)
-	if err != nil {
-		switch {
-		case errors.Is(err, storage.ErrSnippetNotFound):
-			sugar.Debug(err)
-			http.Error(w, err.Error(), http.StatusNotFound)
-
-			return
-		default:
-			sugar.Error(err)
-			http.Error(w, errInternal.Error(), http.StatusInternalServerError)
-
-			return
+	h.changeSnippet(w, r, func(doc any) (any, error) {
+		result := maps.Clone(replacement)
+		if result == nil {
+			// The body was null
+			return nil, nil
 		}
-	}
 
-	snippet.ID = snippetId
-	snippet.UpdatedAt = time.Now()
-
-	sugar.Debugw("finished updating", "snippet.id", snippetId)
+		for _, field := range readOnlyFields {
+			if _, ok := result[field]; !ok {
+				result[field] = doc.(map[string]any)[field]
+			}
+		}
 
-	encodeJSON(h, w, snippet)
+		return result, nil
+	})
 }
 
 // DeleteSnippet handles deleting a snippet
@@ -189,16 +357,26 @@ func (h *SnippetHandler) DeleteSnippet(w http.ResponseWriter, r *http.Request) {
 		sugar = h.logger.Sugar()
 	)
 
-	if err := h.store.DeleteSnippet(id); err != nil {
+	if err := h.store.DeleteSnippet(withIfMatch(r).Context(), id); err != nil {
 		switch {
 		case errors.Is(err, storage.ErrSnippetNotFound):
 			sugar.Debugw(err.Error())
-			http.Error(w, err.Error(), http.StatusNotFound)
+			WriteError(w, err, http.StatusNotFound)
+
+			return
+		case errors.Is(err, storage.ErrForbidden):
+			sugar.Debug(err)
+			WriteError(w, err, http.StatusForbidden)
+
+			return
+		case errors.Is(err, storage.ErrVersionMismatch):
+			sugar.Debug(err)
+			WriteError(w, err, http.StatusPreconditionFailed)
 
 			return
 		default:
 			sugar.Error(err)
-			http.Error(w, errInternal.Error(), http.StatusInternalServerError)
+			WriteError(w, errInternal, http.StatusInternalServerError)
 
 			return
 		}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+
+	"github.com/villaleo/cstash/internal/models"
+	"github.com/villaleo/cstash/internal/storage"
+	"go.uber.org/zap"
+)
+
+// LanguageHandler handles language-related API requests
+type LanguageHandler struct {
+	store  storage.Store
+	logger *zap.Logger
+}
+
+// languageUsage is a known language along with the number of snippets written
+// in it
+type languageUsage struct {
+	models.Language
+	Count int `json:"count"`
+}
+
+// NewLanguageHandler creates a new language handler
+func NewLanguageHandler(store storage.Store, logger *zap.Logger) *LanguageHandler {
+	return &LanguageHandler{
+		store:  store,
+		logger: logger.Named("languages"),
+	}
+}
+
+// Logger simply returns this handler's logger. This method is implemented to
+// satisfy logHandler.
+func (h *LanguageHandler) Logger() *zap.Logger {
+	return h.logger
+}
+
+// RegisterRoutes registers the language API routes
+func (h *LanguageHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/languages", h.ListLanguages)
+}
+
+// ListLanguages handles listing every known language with the number of
+// snippets written in it, most used first. Snippets saved with another name
+// of a language are counted as written in it.
+func (h *LanguageHandler) ListLanguages(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	sugar := h.logger.Sugar()
+
+	counts, err := h.store.CountLanguages(r.Context())
+	if err != nil {
+		sugar.Error(err)
+		WriteError(w, errInternal, http.StatusInternalServerError)
+
+		return
+	}
+
+	usage := make(map[string]int, len(counts))
+	for name, count := range counts {
+		if language, ok := models.LookupLanguage(name); ok {
+			usage[language.ID] += count
+		}
+	}
+
+	results := make([]languageUsage, len(models.Languages))
+	for i, language := range models.Languages {
+		results[i] = languageUsage{Language: language, Count: usage[language.ID]}
+	}
+
+	slices.SortStableFunc(results, func(a, b languageUsage) int {
+		return cmp.Compare(b.Count, a.Count)
+	})
+
+	sugar.Debugw("fetched languages", "count", len(results))
+
+	encodeJSON(h, w, results)
+}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/villaleo/cstash/internal/auth"
+	"github.com/villaleo/cstash/internal/models"
+	"github.com/villaleo/cstash/internal/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	oidcLoginCookie = "cstash_oidc_login"
+	oidcLoginTTL    = 10 * time.Minute
+	// maxUsernameAttempts bounds how many usernames are tried when a single
+	// sign-on user is first seen and their preferred username is taken
+	maxUsernameAttempts = 5
+)
+
+var errInvalidOIDCLogin = errors.New("login attempt expired or is invalid, please try again")
+
+// OIDCHandler handles single sign-on requests with an OpenID Connect provider
+type OIDCHandler struct {
+	provider   *auth.OIDCProvider
+	store      storage.UserStore
+	sessionTTL time.Duration
+	// postLoginRedirect is where users are sent after signing in, with the
+	// session token in the URL fragment. The token is returned as JSON if it's
+	// empty.
+	postLoginRedirect string
+	logger            *zap.Logger
+}
+
+// NewOIDCHandler creates a new single sign-on handler
+func NewOIDCHandler(
+	provider *auth.OIDCProvider,
+	store storage.UserStore,
+	sessionTTL time.Duration,
+	postLoginRedirect string,
+	logger *zap.Logger,
+) *OIDCHandler {
+	return &OIDCHandler{
+		provider:          provider,
+		store:             store,
+		sessionTTL:        sessionTTL,
+		postLoginRedirect: postLoginRedirect,
+		logger:            logger.Named("oidc"),
+	}
+}
+
+// Logger simply returns this handler's logger. This method is implemented to
+// satisfy logHandler.
+func (h *OIDCHandler) Logger() *zap.Logger {
+	return h.logger
+}
+
+// RegisterRoutes registers the single sign-on API routes
+func (h *OIDCHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/auth/oidc/login", h.Login)
+	mux.HandleFunc("GET /api/v1/auth/oidc/callback", h.Callback)
+}
+
+// PublicRoutes returns the paths of the single sign-on routes, none of which
+// require the caller to be signed in
+func (h *OIDCHandler) PublicRoutes() []string {
+	return []string{"/api/v1/auth/oidc/login", "/api/v1/auth/oidc/callback"}
+}
+
+// Login handles redirecting the user to the provider to sign in. The login's
+// state, nonce and PKCE verifier are kept in a short-lived cookie until the
+// provider redirects back to Callback.
+func (h *OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var (
+		login = h.provider.NewLogin()
+		sugar = h.logger.Sugar()
+	)
+
+	data, err := json.Marshal(login)
+	if err != nil {
+		sugar.Error(err)
+		WriteError(w, errInternal, http.StatusInternalServerError)
+
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcLoginCookie,
+		Value:    base64.RawURLEncoding.EncodeToString(data),
+		Path:     "/api/v1/auth/oidc",
+		MaxAge:   int(oidcLoginTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, h.provider.AuthCodeURL(login), http.StatusFound)
+}
+
+// Callback handles the provider redirecting the user back after signing in.
+// The user is matched to a cstash account by their provider identity, and an
+// account is created the first time they sign in.
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	var (
+		query = r.URL.Query()
+		sugar = h.logger.Sugar()
+	)
+
+	// The login cookie is single-use
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcLoginCookie,
+		Path:     "/api/v1/auth/oidc",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+
+	if providerErr := query.Get("error"); providerErr != "" {
+		sugar.Debugw("provider rejected login", "error", providerErr, "description", query.Get("error_description"))
+		WriteError(w, fmt.Errorf("login failed: %s", providerErr), http.StatusUnauthorized)
+
+		return
+	}
+
+	login, ok := h.pendingLogin(r)
+	if !ok || subtle.ConstantTimeCompare([]byte(login.State), []byte(query.Get("state"))) != 1 {
+		WriteError(w, errInvalidOIDCLogin, http.StatusBadRequest)
+		return
+	}
+
+	claims, err := h.provider.Exchange(r.Context(), query.Get("code"), login)
+	if err != nil {
+		sugar.Debug(err)
+		WriteError(w, errInvalidOIDCLogin, http.StatusUnauthorized)
+
+		return
+	}
+
+	user, err := h.findOrCreateUser(r, claims)
+	if err != nil {
+		sugar.Error(err)
+		WriteError(w, errInternal, http.StatusInternalServerError)
+
+		return
+	}
+
+	token, session, err := createSession(r.Context(), h.store, user.ID, h.sessionTTL)
+	if err != nil {
+		sugar.Error(err)
+		WriteError(w, errInternal, http.StatusInternalServerError)
+
+		return
+	}
+
+	sugar.Debugw("user logged in with single sign-on", "user.id", user.ID)
+
+	if h.postLoginRedirect != "" {
+		fragment := url.Values{
+			"token":     {token},
+			"expiresAt": {session.ExpiresAt.Format(time.RFC3339)},
+		}
+
+		http.Redirect(w, r, h.postLoginRedirect+"#"+fragment.Encode(), http.StatusSeeOther)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	encodeJSON(h, w, map[string]any{
+		"token":     token,
+		"expiresAt": session.ExpiresAt,
+	})
+}
+
+// pendingLogin decodes the login stored in the request's login cookie
+func (h *OIDCHandler) pendingLogin(r *http.Request) (auth.OIDCLogin, bool) {
+	var login auth.OIDCLogin
+
+	cookie, err := r.Cookie(oidcLoginCookie)
+	if err != nil {
+		return login, false
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil {
+		return login, false
+	}
+
+	if err := json.Unmarshal(data, &login); err != nil {
+		return login, false
+	}
+
+	return login, login.State != ""
+}
+
+// findOrCreateUser returns the cstash user linked to the provider account
+// described by claims, creating one if it's the first time they sign in
+func (h *OIDCHandler) findOrCreateUser(r *http.Request, claims *auth.OIDCClaims) (*models.User, error) {
+	user, err := h.store.GetUserByExternalID(r.Context(), claims.ExternalID())
+	if err == nil || !errors.Is(err, storage.ErrUserNotFound) {
+		return user, err
+	}
+
+	username := claims.Username()
+
+	for range maxUsernameAttempts {
+		user = models.NewUser(username, "")
+		user.ExternalID = claims.ExternalID()
+
+		err = h.store.CreateUser(r.Context(), user)
+		if !errors.Is(err, storage.ErrUserExists) {
+			break
+		}
+
+		// The user may have been created by a concurrent first sign-in, rather
+		// than the username being taken
+		if linked, err := h.store.GetUserByExternalID(r.Context(), claims.ExternalID()); err == nil {
+			return linked, nil
+		}
+
+		username = claims.Username() + "-" + strings.ToLower(auth.NewSecureID()[:4])
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	h.logger.Sugar().Debugw("user registered with single sign-on", "user.id", user.ID)
+
+	return user, nil
+}
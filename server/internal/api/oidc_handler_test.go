@@ -0,0 +1,347 @@
+package api_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/villaleo/cstash/internal/api"
+	"github.com/villaleo/cstash/internal/auth"
+	"github.com/villaleo/cstash/internal/auth/oidctest"
+	"github.com/villaleo/cstash/internal/models"
+	"github.com/villaleo/cstash/internal/storage"
+	"go.uber.org/zap"
+)
+
+const callbackURL = "http://cstash.test/api/v1/auth/oidc/callback"
+
+// oidcTest signs users in through an OIDCHandler backed by a mock issuer
+type oidcTest struct {
+	issuer *oidctest.Issuer
+	store  storage.UserStore
+	mux    *http.ServeMux
+}
+
+func newOIDCTest(t *testing.T) *oidcTest {
+	t.Helper()
+
+	return newOIDCTestWith(t, storage.NewMemoryStore(zap.NewNop()))
+}
+
+// newOIDCTestWith returns an oidcTest signing users in to store
+func newOIDCTestWith(t *testing.T, store storage.UserStore) *oidcTest {
+	t.Helper()
+
+	issuer := oidctest.NewIssuer(t)
+
+	provider, err := auth.NewOIDCProvider(context.Background(), auth.OIDCConfig{
+		IssuerURL:    issuer.URL,
+		ClientID:     oidctest.ClientID,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  callbackURL,
+	})
+	if err != nil {
+		t.Fatalf("NewOIDCProvider() = %v", err)
+	}
+
+	var (
+		handler = api.NewOIDCHandler(provider, store, time.Hour, "", zap.NewNop())
+		mux     = http.NewServeMux()
+	)
+
+	handler.RegisterRoutes(mux)
+
+	return &oidcTest{issuer: issuer, store: store, mux: mux}
+}
+
+// login starts a login, returning the login cookie set and the URL the user
+// is sent to at the provider
+func (o *oidcTest) login(t *testing.T) (*http.Cookie, *url.URL) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	o.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/login", nil))
+
+	if w.Code != http.StatusFound {
+		t.Fatalf("login: status %d, want %d", w.Code, http.StatusFound)
+	}
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("login: %d cookies, want 1", len(cookies))
+	}
+
+	location, err := w.Result().Location()
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	return cookies[0], location
+}
+
+// callback returns the response to the provider redirecting the user back
+// with query, carrying cookie if it's not nil
+func (o *oidcTest) callback(query url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/callback?"+query.Encode(), nil)
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+
+	w := httptest.NewRecorder()
+	o.mux.ServeHTTP(w, r)
+
+	return w
+}
+
+// signIn signs a user in and returns the session token they're given
+func (o *oidcTest) signIn(t *testing.T) string {
+	t.Helper()
+
+	cookie, authURL := o.login(t)
+	w := o.callback(o.issuer.SignIn(t, authURL.String()).Query(), cookie)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("callback: status %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	var body struct {
+		Token string `json:"token"`
+	}
+
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("callback: %v", err)
+	}
+
+	return body.Token
+}
+
+// decodeLogin decodes the login held by cookie
+func decodeLogin(t *testing.T, cookie *http.Cookie) auth.OIDCLogin {
+	t.Helper()
+
+	var login auth.OIDCLogin
+
+	data, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err == nil {
+		err = json.Unmarshal(data, &login)
+	}
+
+	if err != nil {
+		t.Fatalf("decode login cookie: %v", err)
+	}
+
+	return login
+}
+
+// encodeLogin returns a copy of cookie holding login
+func encodeLogin(t *testing.T, cookie *http.Cookie, login auth.OIDCLogin) *http.Cookie {
+	t.Helper()
+
+	data, err := json.Marshal(login)
+	if err != nil {
+		t.Fatalf("encode login cookie: %v", err)
+	}
+
+	encoded := *cookie
+	encoded.Value = base64.RawURLEncoding.EncodeToString(data)
+
+	return &encoded
+}
+
+func TestOIDCLoginUsesPKCE(t *testing.T) {
+	o := newOIDCTest(t)
+
+	cookie, authURL := o.login(t)
+	login := decodeLogin(t, cookie)
+	query := authURL.Query()
+
+	challenge := sha256.Sum256([]byte(login.Verifier))
+
+	if got, want := query.Get("code_challenge"), base64.RawURLEncoding.EncodeToString(challenge[:]); got != want {
+		t.Errorf("code_challenge = %q, want %q", got, want)
+	}
+
+	if got := query.Get("code_challenge_method"); got != "S256" {
+		t.Errorf("code_challenge_method = %q, want \"S256\"", got)
+	}
+
+	if query.Get("state") != login.State || query.Get("nonce") != login.Nonce {
+		t.Errorf("state and nonce = %q %q, want those of the login cookie", query.Get("state"), query.Get("nonce"))
+	}
+
+	if !cookie.HttpOnly || cookie.Path != "/api/v1/auth/oidc" {
+		t.Errorf("login cookie = %+v, want an HttpOnly cookie scoped to /api/v1/auth/oidc", cookie)
+	}
+}
+
+func TestOIDCCallbackSignsIn(t *testing.T) {
+	o := newOIDCTest(t)
+	ctx := context.Background()
+
+	first := o.signIn(t)
+
+	session, err := o.store.GetSession(ctx, auth.HashToken(first))
+	if err != nil {
+		t.Fatalf("GetSession() = %v", err)
+	}
+
+	user, err := o.store.GetUserByExternalID(ctx, o.issuer.URL+"|alice")
+	if err != nil {
+		t.Fatalf("GetUserByExternalID() = %v", err)
+	}
+
+	if session.UserID != user.ID {
+		t.Errorf("session user = %q, want %q", session.UserID, user.ID)
+	}
+
+	// Signing in again finds the same user rather than creating another
+	second, err := o.store.GetSession(ctx, auth.HashToken(o.signIn(t)))
+	if err != nil {
+		t.Fatalf("GetSession() = %v", err)
+	}
+
+	if second.UserID != user.ID {
+		t.Errorf("second session user = %q, want %q", second.UserID, user.ID)
+	}
+}
+
+// racingStore holds back the first lookups of users by external ID until
+// every one of them has been made, so that the callbacks making them all find
+// no user and race to create one
+type racingStore struct {
+	*storage.MemoryStore
+	// held is how many lookups are held back
+	held int32
+	// made counts the lookups made so far
+	made atomic.Int32
+	// waiting is done once every held back lookup has been made
+	waiting sync.WaitGroup
+}
+
+func newRacingStore(held int) *racingStore {
+	s := &racingStore{MemoryStore: storage.NewMemoryStore(zap.NewNop()), held: int32(held)}
+	s.waiting.Add(held)
+
+	return s
+}
+
+func (s *racingStore) GetUserByExternalID(ctx context.Context, externalID string) (*models.User, error) {
+	user, err := s.MemoryStore.GetUserByExternalID(ctx, externalID)
+
+	if s.made.Add(1) <= s.held {
+		s.waiting.Done()
+		s.waiting.Wait()
+	}
+
+	return user, err
+}
+
+func TestOIDCCallbackSignsInConcurrently(t *testing.T) {
+	const logins = 8
+
+	var (
+		o         = newOIDCTestWith(t, newRacingStore(logins))
+		cookies   = make([]*http.Cookie, logins)
+		queries   = make([]url.Values, logins)
+		responses = make([]*httptest.ResponseRecorder, logins)
+		wg        sync.WaitGroup
+	)
+
+	// The first sign-ins of the same user race to create them once their
+	// logins are all under way
+	for i := range logins {
+		cookie, authURL := o.login(t)
+		cookies[i], queries[i] = cookie, o.issuer.SignIn(t, authURL.String()).Query()
+	}
+
+	for i := range logins {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			responses[i] = o.callback(queries[i], cookies[i])
+		}()
+	}
+
+	wg.Wait()
+
+	user, err := o.store.GetUserByExternalID(context.Background(), o.issuer.URL+"|alice")
+	if err != nil {
+		t.Fatalf("GetUserByExternalID() = %v", err)
+	}
+
+	for _, w := range responses {
+		if w.Code != http.StatusOK {
+			t.Errorf("callback: status %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+			continue
+		}
+
+		var body struct {
+			Token string `json:"token"`
+		}
+
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("callback: %v", err)
+		}
+
+		session, err := o.store.GetSession(context.Background(), auth.HashToken(body.Token))
+		if err != nil || session.UserID != user.ID {
+			t.Errorf("GetSession() = %+v, %v, want a session of %q", session, err, user.ID)
+		}
+	}
+}
+
+func TestOIDCCallbackRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		// change alters what the callback receives after the user signed in
+		change func(t *testing.T, o *oidcTest, query url.Values, cookie *http.Cookie) (url.Values, *http.Cookie)
+		want   int
+	}{
+		{"a state mismatch", func(_ *testing.T, _ *oidcTest, query url.Values, cookie *http.Cookie) (url.Values, *http.Cookie) {
+			query.Set("state", "not-the-state")
+			return query, cookie
+		}, http.StatusBadRequest},
+		{"a missing login cookie", func(_ *testing.T, _ *oidcTest, query url.Values, _ *http.Cookie) (url.Values, *http.Cookie) {
+			return query, nil
+		}, http.StatusBadRequest},
+		{"another PKCE verifier", func(t *testing.T, _ *oidcTest, query url.Values, cookie *http.Cookie) (url.Values, *http.Cookie) {
+			login := decodeLogin(t, cookie)
+			login.Verifier = "not-the-verifier-the-challenge-was-made-from"
+
+			return query, encodeLogin(t, cookie, login)
+		}, http.StatusUnauthorized},
+		{"a bad ID token signature", func(_ *testing.T, o *oidcTest, query url.Values, cookie *http.Cookie) (url.Values, *http.Cookie) {
+			o.issuer.BadSignature = true
+			return query, cookie
+		}, http.StatusUnauthorized},
+		{"a provider error", func(_ *testing.T, _ *oidcTest, _ url.Values, cookie *http.Cookie) (url.Values, *http.Cookie) {
+			return url.Values{"error": {"access_denied"}}, cookie
+		}, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newOIDCTest(t)
+
+			cookie, authURL := o.login(t)
+			query, cookie := tt.change(t, o, o.issuer.SignIn(t, authURL.String()).Query(), cookie)
+
+			if w := o.callback(query, cookie); w.Code != tt.want {
+				t.Errorf("callback: status %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+
+			if _, err := o.store.GetUserByExternalID(context.Background(), o.issuer.URL+"|alice"); err == nil {
+				t.Error("GetUserByExternalID() found a user, want none signed up")
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/villaleo/cstash/internal/models"
+	"github.com/villaleo/cstash/internal/patch"
+	"github.com/villaleo/cstash/internal/storage"
+)
+
+// The media types of the patch documents accepted by PatchSnippet
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+var errUnsupportedPatch = fmt.Errorf("Content-Type must be %s or %s", mergePatchType, jsonPatchType)
+
+// readOnlyFields are the members of a snippet's JSON document that are set by
+// the server and can't be changed by a request
+var readOnlyFields = []string{"id", "ownerId", "workspaceId", "createdAt", "updatedAt", "version"}
+
+// pointerEscaper escapes a member name as a JSON Pointer reference token
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// PatchSnippet handles changing some of the fields of a snippet with a JSON
+// Merge Patch (RFC 7396) or JSON Patch (RFC 6902) document. The patch is
+// applied to the snippet as returned by GetSnippet.
+func (h *SnippetHandler) PatchSnippet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var (
+		apply func(doc any) (any, error)
+		sugar = h.logger.Sugar()
+	)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != mergePatchType && mediaType != jsonPatchType {
+		sugar.Debugw(errUnsupportedPatch.Error(), "contentType", mediaType)
+		w.Header().Set("Accept-Patch", mergePatchType+", "+jsonPatchType)
+		WriteError(w, errUnsupportedPatch, http.StatusUnsupportedMediaType)
+
+		return
+	}
+
+	defer r.Body.Close()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sugar.Error(err)
+		WriteError(w, errInternal, http.StatusInternalServerError)
+
+		return
+	}
+
+	if mediaType == mergePatchType {
+		var mergePatch any
+		if err = json.Unmarshal(body, &mergePatch); err != nil {
+			err = describeDecodeError(err)
+		}
+
+		apply = func(doc any) (any, error) {
+			return patch.Merge(doc, mergePatch), nil
+		}
+	} else {
+		var jsonPatch patch.JSONPatch
+		jsonPatch, err = patch.ParseJSONPatch(body)
+		apply = jsonPatch.Apply
+	}
+
+	if err != nil {
+		sugar.Debug(err)
+		WriteError(w, err, http.StatusBadRequest)
+
+		return
+	}
+
+	h.changeSnippet(w, r, apply)
+}
+
+// changeSnippet replaces the snippet a request is for with the document
+// returned by apply for its current document, writing the response
+func (h *SnippetHandler) changeSnippet(w http.ResponseWriter, r *http.Request, apply func(doc any) (any, error)) {
+	var (
+		id    = r.PathValue("id")
+		sugar = h.logger.Sugar()
+	)
+
+	snippet, err := h.store.UpdateSnippet(withIfMatch(r).Context(), id, func(snippet *models.Snippet) error {
+		doc, err := snippetDocument(snippet)
+		if err != nil {
+			return err
+		}
+
+		result, err := apply(doc)
+		if err != nil {
+			return err
+		}
+
+		if err := replaceSnippet(snippet, doc, result); err != nil {
+			return err
+		}
+
+		snippet.NormalizeLanguage()
+		snippet.NormalizeTags()
+
+		return snippet.Validate()
+	})
+	if err != nil {
+		var invalid models.ValidationError
+
+		switch {
+		case errors.Is(err, storage.ErrSnippetNotFound):
+			sugar.Debug(err)
+			WriteError(w, err, http.StatusNotFound)
+
+			return
+		case errors.Is(err, storage.ErrForbidden):
+			sugar.Debug(err)
+			WriteError(w, err, http.StatusForbidden)
+
+			return
+		case errors.Is(err, storage.ErrVersionMismatch):
+			sugar.Debug(err)
+			WriteError(w, err, http.StatusPreconditionFailed)
+
+			return
+		case errors.Is(err, patch.ErrPathNotFound), errors.Is(err, patch.ErrTestFailed):
+			sugar.Debug(err)
+			WriteError(w, err, http.StatusConflict)
+
+			return
+		case errors.As(err, &invalid):
+			sugar.Debug(err)
+			WriteError(w, err, http.StatusUnprocessableEntity)
+
+			return
+		default:
+			sugar.Error(err)
+			WriteError(w, errInternal, http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	sugar.Debugw("finished updating", "snippet.id", id)
+
+	setSnippetETag(w, snippet)
+	encodeJSON(h, w, snippet)
+}
+
+// snippetDocument returns the JSON document of snippet decoded into a map
+func snippetDocument(snippet *models.Snippet) (map[string]any, error) {
+	data, err := json.Marshal(snippet)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	// Snippets without tags may have them stored as null
+	if doc["tags"] == nil {
+		doc["tags"] = []any{}
+	}
+
+	return doc, nil
+}
+
+// replaceSnippet sets the fields of snippet, whose document is original, to
+// those of the document result. Fields missing from result are reset to the
+// values of a new snippet. A models.ValidationError listing every problem is
+// returned if result doesn't have the fields of a snippet, or changes a
+// read-only field.
+func replaceSnippet(snippet *models.Snippet, original map[string]any, result any) error {
+	doc, ok := result.(map[string]any)
+	if !ok {
+		return models.ValidationError{{Field: "", Code: models.CodeInvalidType, Message: "must be an object"}}
+	}
+
+	var (
+		errs     models.ValidationError
+		replaced = *snippet
+	)
+
+	replaced.Visibility = models.VisibilityPrivate
+	replaced.Title = ""
+	replaced.Description = ""
+	replaced.Content = ""
+	replaced.Language = ""
+	replaced.Tags = []string{}
+	replaced.IsFavorite = false
+
+	invalid := func(field, code, message string) {
+		errs = append(errs, models.FieldError{Field: field, Code: code, Message: message})
+	}
+
+	setString := func(field string, value any, dst *string) {
+		if s, ok := value.(string); ok {
+			*dst = s
+		} else {
+			invalid(field, models.CodeInvalidType, "must be a string")
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(doc)) {
+		var (
+			value = doc[name]
+			field = "/" + pointerEscaper.Replace(name)
+		)
+
+		switch name {
+		case "visibility":
+			visibility, ok := value.(string)
+			switch {
+			case !ok:
+				invalid(field, models.CodeInvalidType, "must be a string")
+			case !models.Visibility(visibility).Valid():
+				invalid(field, models.CodeInvalidValue, "must be one of private, team or public")
+			default:
+				replaced.Visibility = models.Visibility(visibility)
+			}
+		case "title":
+			setString(field, value, &replaced.Title)
+		case "description":
+			setString(field, value, &replaced.Description)
+		case "content":
+			setString(field, value, &replaced.Content)
+		case "language":
+			setString(field, value, &replaced.Language)
+		case "tags":
+			tags, ok := value.([]any)
+			if !ok {
+				invalid(field, models.CodeInvalidType, "must be an array of strings")
+				continue
+			}
+
+			for i, tag := range tags {
+				s, ok := tag.(string)
+				if !ok {
+					invalid(fmt.Sprintf("%s/%d", field, i), models.CodeInvalidType, "must be a string")
+					continue
+				}
+
+				replaced.Tags = append(replaced.Tags, s)
+			}
+		case "isFavorite":
+			isFavorite, ok := value.(bool)
+			if !ok {
+				invalid(field, models.CodeInvalidType, "must be a boolean")
+				continue
+			}
+
+			replaced.IsFavorite = isFavorite
+		default:
+			if !slices.Contains(readOnlyFields, name) {
+				invalid(field, models.CodeUnknownField, "is not a known field")
+			} else if !reflect.DeepEqual(value, original[name]) {
+				invalid(field, models.CodeReadOnly, "is read-only")
+			}
+		}
+	}
+
+	for _, name := range readOnlyFields {
+		if _, ok := doc[name]; !ok {
+			invalid("/"+name, models.CodeReadOnly, "is read-only")
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	*snippet = replaced
+
+	return nil
+}
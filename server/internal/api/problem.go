@@ -0,0 +1,120 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/villaleo/cstash/internal/models"
+)
+
+// problem is the body of every error response, an RFC 7807 problem details
+// object. Code is the snake-cased title, and Errors lists the problems with
+// the fields of the request, if any.
+type problem struct {
+	Type   string              `json:"type"`
+	Title  string              `json:"title"`
+	Status int                 `json:"status"`
+	Detail string              `json:"detail"`
+	Code   string              `json:"code"`
+	Errors []models.FieldError `json:"errors,omitempty"`
+}
+
+// WriteError replies to the request with an application/problem+json body
+// describing err and the status code. It takes the place of http.Error, so
+// the caller must not write to w afterwards.
+func WriteError(w http.ResponseWriter, err error, status int) {
+	title := http.StatusText(status)
+
+	p := problem{
+		Type:   "about:blank",
+		Title:  title,
+		Status: status,
+		Detail: err.Error(),
+		Code:   strings.ReplaceAll(strings.ToLower(title), " ", "_"),
+	}
+
+	var invalid models.ValidationError
+	if errors.As(err, &invalid) {
+		p.Errors = invalid
+	}
+
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/problem+json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+
+	// The status is already sent, so a failure can't be reported
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// writeBodyError replies to a request whose body decodeInto couldn't decode.
+// A body of the wrong shape is unprocessable, and anything else is a bad
+// request.
+func writeBodyError(w http.ResponseWriter, err error) {
+	var invalid models.ValidationError
+	if errors.As(err, &invalid) {
+		WriteError(w, err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	WriteError(w, err, http.StatusBadRequest)
+}
+
+// describeDecodeError turns an error returned by a json.Decoder into one
+// meant for clients. Fields of the wrong type and unknown fields are reported
+// as a models.ValidationError.
+func describeDecodeError(err error) error {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return errors.New("request body is empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return errors.New("request body is not valid JSON: it ends too soon")
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("request body is not valid JSON at offset %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		field := ""
+		if typeErr.Field != "" {
+			field = "/" + strings.ReplaceAll(typeErr.Field, ".", "/")
+		}
+
+		return models.ValidationError{{Field: field, Code: models.CodeInvalidType, Message: "must be " + jsonType(typeErr.Type.Kind())}}
+	}
+
+	// The decoder reports unknown fields with an unexported error
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return models.ValidationError{{
+			Field:   "/" + pointerEscaper.Replace(strings.Trim(name, `"`)),
+			Code:    models.CodeUnknownField,
+			Message: "is not a known field",
+		}}
+	}
+
+	return err
+}
+
+// jsonType names the JSON type of values decoded into a Go value of kind
+func jsonType(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	default:
+		return "a number"
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/villaleo/cstash/internal/highlight"
+	"github.com/villaleo/cstash/internal/models"
+)
+
+var errInvalidFormat = errors.New("format must be one of html, ansi or svg")
+
+// RenderSnippet handles rendering the content of a snippet with its syntax
+// highlighted. The format query parameter picks HTML, ANSI escape sequences
+// or SVG, and defaults to HTML; the theme query parameter picks the colors.
+// Content in a language without a known syntax is rendered unhighlighted.
+func (h *SnippetHandler) RenderSnippet(w http.ResponseWriter, r *http.Request) {
+	var (
+		id     = r.PathValue("id")
+		query  = r.URL.Query()
+		format = highlight.Format(strings.ToLower(query.Get("format")))
+		sugar  = h.logger.Sugar()
+	)
+
+	if format == "" {
+		format = highlight.HTML
+	}
+
+	if !format.Valid() {
+		sugar.Debugw(errInvalidFormat.Error(), "format", format)
+		WriteError(w, errInvalidFormat, http.StatusBadRequest)
+
+		return
+	}
+
+	theme, err := highlight.LookupTheme(query.Get("theme"))
+	if err != nil {
+		err = fmt.Errorf("%w %q, must be one of %s", err, query.Get("theme"), strings.Join(highlight.ThemeNames(), ", "))
+		sugar.Debug(err)
+		WriteError(w, err, http.StatusBadRequest)
+
+		return
+	}
+
+	snippet, ok := h.getSnippet(w, r, id)
+	if !ok {
+		return
+	}
+
+	// The entity tag of every rendering is the version of the snippet, since
+	// each format and theme has its own URL
+	setSnippetETag(w, snippet)
+
+	if !noneMatch(r, snippet) {
+		sugar.Debugw("snippet not modified", "snippet.id", id)
+		w.WriteHeader(http.StatusNotModified)
+
+		return
+	}
+
+	language := snippet.Language
+	if known, ok := models.LookupLanguage(language); ok {
+		language = known.ID
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", format.ContentType())
+	header.Set("X-Content-Type-Options", "nosniff")
+
+	// Renderings only style the escaped content, so nothing in them needs to
+	// run or load
+	if format != highlight.ANSI {
+		header.Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	}
+
+	tokens := highlight.Tokenize(language, snippet.Content)
+	if err := highlight.Write(w, format, theme, tokens); err != nil {
+		sugar.Error(err)
+		return
+	}
+
+	sugar.Debugw("rendered snippet", "snippet.id", id, "format", format, "theme", theme.Name)
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/villaleo/cstash/internal/storage"
+)
+
+var errInvalidRevision = errors.New("revision must be a positive number")
+
+// ListRevisions handles listing the revisions of a snippet, newest first
+func (h *SnippetHandler) ListRevisions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var (
+		id    = r.PathValue("id")
+		sugar = h.logger.Sugar()
+	)
+
+	revisions, err := h.store.ListRevisions(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrSnippetNotFound):
+			sugar.Debug(err)
+			WriteError(w, err, http.StatusNotFound)
+
+			return
+		default:
+			sugar.Error(err)
+			WriteError(w, errInternal, http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	sugar.Debugw("fetched revisions", "snippet.id", id, "count", len(revisions))
+
+	encodeJSON(h, w, revisions)
+}
+
+// GetRevision handles retrieving a single revision of a snippet
+func (h *SnippetHandler) GetRevision(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var (
+		id    = r.PathValue("id")
+		sugar = h.logger.Sugar()
+	)
+
+	number, err := strconv.Atoi(r.PathValue("rev"))
+	if err != nil || number < 1 {
+		sugar.Debug(errInvalidRevision)
+		WriteError(w, errInvalidRevision, http.StatusBadRequest)
+
+		return
+	}
+
+	revision, err := h.store.GetRevision(r.Context(), id, number)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrSnippetNotFound), errors.Is(err, storage.ErrRevisionNotFound):
+			sugar.Debug(err)
+			WriteError(w, err, http.StatusNotFound)
+
+			return
+		default:
+			sugar.Error(err)
+			WriteError(w, errInternal, http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	sugar.Debugw("fetched revision", "snippet.id", id, "revision", number)
+
+	encodeJSON(h, w, revision)
+}
+
+// RestoreRevision handles setting a snippet back to one of its revisions. The
+// restored snippet is returned, and the restore is recorded as a new revision.
+func (h *SnippetHandler) RestoreRevision(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var (
+		id    = r.PathValue("id")
+		sugar = h.logger.Sugar()
+	)
+
+	number, err := strconv.Atoi(r.PathValue("rev"))
+	if err != nil || number < 1 {
+		sugar.Debug(errInvalidRevision)
+		WriteError(w, errInvalidRevision, http.StatusBadRequest)
+
+		return
+	}
+
+	snippet, err := h.store.RestoreRevision(withIfMatch(r).Context(), id, number)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrSnippetNotFound), errors.Is(err, storage.ErrRevisionNotFound):
+			sugar.Debug(err)
+			WriteError(w, err, http.StatusNotFound)
+
+			return
+		case errors.Is(err, storage.ErrForbidden):
+			sugar.Debug(err)
+			WriteError(w, err, http.StatusForbidden)
+
+			return
+		case errors.Is(err, storage.ErrVersionMismatch):
+			sugar.Debug(err)
+			WriteError(w, err, http.StatusPreconditionFailed)
+
+			return
+		default:
+			sugar.Error(err)
+			WriteError(w, errInternal, http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	sugar.Debugw("restored snippet", "snippet.id", id, "revision", number)
+
+	setSnippetETag(w, snippet)
+	encodeJSON(h, w, snippet)
+}
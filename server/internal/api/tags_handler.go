@@ -1,20 +1,31 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 
+	"github.com/villaleo/cstash/internal/models"
 	"github.com/villaleo/cstash/internal/storage"
 	"go.uber.org/zap"
 )
 
+var errInvalidView = errors.New("view must be flat or tree")
+
 // SnippetHandler handles tag-related API requests
 type TagHandler struct {
-	store  *storage.MemoryStore
+	store  storage.Store
 	logger *zap.Logger
 }
 
+// mergeTagsRequest is the body of a request to merge tags
+type mergeTagsRequest struct {
+	Sources []string `json:"sources"`
+	Target  string   `json:"target"`
+}
+
 // NewTagHandler creates a new tag handler
-func NewTagHandler(store *storage.MemoryStore, logger *zap.Logger) *TagHandler {
+func NewTagHandler(store storage.Store, logger *zap.Logger) *TagHandler {
 	return &TagHandler{
 		store:  store,
 		logger: logger.Named("tags"),
@@ -30,18 +41,212 @@ func (h *TagHandler) Logger() *zap.Logger {
 // RegisterRoutes registers the tag API routes
 func (h *TagHandler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /api/v1/tags", h.ListTags)
+	mux.HandleFunc("POST /api/v1/tags/merge", h.MergeTags)
+	mux.HandleFunc("PUT /api/v1/tags/{tag...}", h.UpdateTag)
+	mux.HandleFunc("DELETE /api/v1/tags/{tag...}", h.DeleteTag)
 }
 
-// ListTags handles listing all tags
+// ListTags handles listing all tags along with their metadata and how many
+// snippets have them. The view query parameter picks a flat list, the
+// default, or a tree of the tags under the tags above them.
 func (h *TagHandler) ListTags(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("Content-Type", "application/json")
 
+	sugar := h.logger.Sugar()
+
+	switch view := r.URL.Query().Get("view"); view {
+	case "", "flat":
+	case "tree":
+		h.tagTree(w, r)
+		return
+	default:
+		sugar.Debugw(errInvalidView.Error(), "view", view)
+		WriteError(w, errInvalidView, http.StatusBadRequest)
+
+		return
+	}
+
+	tags, err := h.store.ListTags(r.Context())
+	if err != nil {
+		sugar.Error(err)
+		WriteError(w, errInternal, http.StatusInternalServerError)
+
+		return
+	}
+
+	sugar.Debugw("fetched tags", "count", len(tags))
+
+	encodeJSON(h, w, tags)
+}
+
+// tagTree writes the hierarchy of tags
+func (h *TagHandler) tagTree(w http.ResponseWriter, r *http.Request) {
+	sugar := h.logger.Sugar()
+
+	tree, err := h.store.TagTree(r.Context())
+	if err != nil {
+		sugar.Error(err)
+		WriteError(w, errInternal, http.StatusInternalServerError)
+
+		return
+	}
+
+	sugar.Debugw("fetched tag tree", "count", len(tree))
+
+	encodeJSON(h, w, tree)
+}
+
+// UpdateTag handles renaming a tag on every snippet that has it and replacing
+// its description and color. The tags below it are renamed along with it. The
+// tag keeps its name if the body has none.
+func (h *TagHandler) UpdateTag(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	sugar := h.logger.Sugar()
+
+	name, ok := h.tagPathValue(w, r)
+	if !ok {
+		return
+	}
+
+	tag := models.Tag{Name: name}
+
+	if err := decodeInto(r.Body, &tag); err != nil {
+		sugar.Debug(err)
+		writeBodyError(w, err)
+
+		return
+	}
+
+	tag.Name = models.NormalizeTag(tag.Name)
+
+	if err := tag.Validate(); err != nil {
+		sugar.Debug(err)
+		WriteError(w, err, http.StatusUnprocessableEntity)
+
+		return
+	}
+
+	updated, err := h.store.UpdateTag(r.Context(), name, tag)
+	if err != nil {
+		writeTagError(h, w, err)
+		return
+	}
+
+	sugar.Debugw("tag updated", "tag", name, "name", updated.Name)
+
+	encodeJSON(h, w, updated)
+}
+
+// MergeTags handles replacing several tags with a single one on every
+// snippet that has them
+func (h *TagHandler) MergeTags(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
 	var (
+		req   mergeTagsRequest
 		sugar = h.logger.Sugar()
-		tags  = h.store.ListTags()
 	)
 
-	sugar.Debugw("fetched tags", "count", len(tags))
+	if err := decodeInto(r.Body, &req); err != nil {
+		sugar.Debug(err)
+		writeBodyError(w, err)
 
-	encodeJSON(h, w, tags)
+		return
+	}
+
+	req.Target = models.NormalizeTag(req.Target)
+
+	var invalid, tagErr models.ValidationError
+
+	if len(req.Sources) == 0 {
+		invalid = append(invalid, models.FieldError{Field: "/sources", Code: models.CodeRequired, Message: "is required"})
+	}
+
+	for i, source := range req.Sources {
+		req.Sources[i] = models.NormalizeTag(source)
+
+		if errors.As(models.ValidateTagName(fmt.Sprintf("/sources/%d", i), req.Sources[i]), &tagErr) {
+			invalid = append(invalid, tagErr...)
+		}
+	}
+
+	if errors.As(models.ValidateTagName("/target", req.Target), &tagErr) {
+		invalid = append(invalid, tagErr...)
+	}
+
+	if len(invalid) > 0 {
+		sugar.Debug(invalid)
+		WriteError(w, invalid, http.StatusUnprocessableEntity)
+
+		return
+	}
+
+	merged, err := h.store.MergeTags(r.Context(), req.Sources, req.Target)
+	if err != nil {
+		writeTagError(h, w, err)
+		return
+	}
+
+	sugar.Debugw("tags merged", "sources", req.Sources, "target", req.Target)
+
+	encodeJSON(h, w, merged)
+}
+
+// DeleteTag handles removing a tag from every snippet that has it
+func (h *TagHandler) DeleteTag(w http.ResponseWriter, r *http.Request) {
+	sugar := h.logger.Sugar()
+
+	name, ok := h.tagPathValue(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.DeleteTag(r.Context(), name); err != nil {
+		writeTagError(h, w, err)
+		return
+	}
+
+	sugar.Debugw("tag deleted", "tag", name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// tagPathValue returns the tag named in the path of r, normalized with
+// models.NormalizeTag so that it matches the stored tag however it's cased.
+//
+// If ok is false, an error has already been written to w.
+func (h *TagHandler) tagPathValue(w http.ResponseWriter, r *http.Request) (name string, ok bool) {
+	name = models.NormalizeTag(r.PathValue("tag"))
+
+	if err := models.ValidateTagName("", name); err != nil {
+		h.logger.Sugar().Debug(err)
+		WriteError(w, err, http.StatusBadRequest)
+
+		return "", false
+	}
+
+	return name, true
+}
+
+// writeTagError replies to a request whose change to tags failed with err
+func writeTagError(h logHandler, w http.ResponseWriter, err error) {
+	sugar := h.Logger().Sugar()
+
+	switch {
+	case errors.Is(err, storage.ErrTagNotFound):
+		sugar.Debug(err)
+		WriteError(w, err, http.StatusNotFound)
+	case errors.Is(err, storage.ErrForbidden):
+		sugar.Debug(err)
+		WriteError(w, err, http.StatusForbidden)
+	case errors.Is(err, storage.ErrTagMergeBelow):
+		sugar.Debug(err)
+		WriteError(w, err, http.StatusUnprocessableEntity)
+	case errors.Is(err, storage.ErrTagExists), errors.Is(err, storage.ErrVersionMismatch):
+		sugar.Debug(err)
+		WriteError(w, err, http.StatusConflict)
+	default:
+		sugar.Error(err)
+		WriteError(w, errInternal, http.StatusInternalServerError)
+	}
 }
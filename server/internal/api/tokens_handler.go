@@ -0,0 +1,195 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/villaleo/cstash/internal/auth"
+	"github.com/villaleo/cstash/internal/models"
+	"github.com/villaleo/cstash/internal/storage"
+	"go.uber.org/zap"
+)
+
+const maxTokenNameLength = 64
+
+var (
+	errInvalidTokenName = fmt.Errorf("name must be 1-%d characters", maxTokenNameLength)
+	errInvalidScopes    = fmt.Errorf("scopes must be a non-empty list of %s", strings.Join(auth.Scopes, ", "))
+	errSessionRequired  = errors.New("access tokens can only be managed when signed in with a password")
+)
+
+// newTokenRequest is the request body of the create token route
+type newTokenRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// TokenHandler handles personal access token requests
+type TokenHandler struct {
+	store  storage.TokenStore
+	logger *zap.Logger
+}
+
+// NewTokenHandler creates a new access token handler
+func NewTokenHandler(store storage.TokenStore, logger *zap.Logger) *TokenHandler {
+	return &TokenHandler{
+		store:  store,
+		logger: logger.Named("tokens"),
+	}
+}
+
+// Logger simply returns this handler's logger. This method is implemented to
+// satisfy logHandler.
+func (h *TokenHandler) Logger() *zap.Logger {
+	return h.logger
+}
+
+// RegisterRoutes registers the access token API routes
+func (h *TokenHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/v1/tokens", h.CreateToken)
+	mux.HandleFunc("GET /api/v1/tokens", h.ListTokens)
+	mux.HandleFunc("DELETE /api/v1/tokens/{id}", h.RevokeToken)
+}
+
+// CreateToken handles creating a new personal access token. The token's secret
+// is only ever included in this response.
+func (h *TokenHandler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var (
+		req   newTokenRequest
+		sugar = h.logger.Sugar()
+	)
+
+	id, ok := sessionIdentity(w, r)
+	if !ok {
+		return
+	}
+
+	if err := decodeInto(r.Body, &req); err != nil {
+		sugar.Debug(err)
+		writeBodyError(w, err)
+
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+
+	if req.Name == "" || len(req.Name) > maxTokenNameLength {
+		WriteError(w, errInvalidTokenName, http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Scopes) == 0 || slices.ContainsFunc(req.Scopes, func(scope string) bool {
+		return !slices.Contains(auth.Scopes, scope)
+	}) {
+		WriteError(w, errInvalidScopes, http.StatusBadRequest)
+		return
+	}
+
+	var (
+		secret = auth.NewAccessToken()
+		token  = &models.AccessToken{
+			ID:        auth.NewSecureID(),
+			UserID:    id.UserID,
+			Name:      req.Name,
+			Scopes:    slices.Compact(slices.Sorted(slices.Values(req.Scopes))),
+			TokenHash: auth.HashToken(secret),
+			CreatedAt: time.Now(),
+		}
+	)
+
+	if err := h.store.CreateAccessToken(r.Context(), token); err != nil {
+		sugar.Error(err)
+		WriteError(w, errInternal, http.StatusInternalServerError)
+
+		return
+	}
+
+	sugar.Debugw("access token created", "token.id", token.ID, "user.id", id.UserID)
+	w.WriteHeader(http.StatusCreated)
+
+	encodeJSON(h, w, map[string]any{
+		"token":       secret,
+		"accessToken": token,
+	})
+}
+
+// ListTokens handles listing the caller's personal access tokens
+func (h *TokenHandler) ListTokens(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	sugar := h.logger.Sugar()
+
+	id, ok := sessionIdentity(w, r)
+	if !ok {
+		return
+	}
+
+	tokens, err := h.store.ListAccessTokens(r.Context(), id.UserID)
+	if err != nil {
+		sugar.Error(err)
+		WriteError(w, errInternal, http.StatusInternalServerError)
+
+		return
+	}
+
+	sugar.Debugw("fetched access tokens", "count", len(tokens))
+
+	encodeJSON(h, w, tokens)
+}
+
+// RevokeToken handles revoking one of the caller's personal access tokens
+func (h *TokenHandler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	var (
+		tokenID = r.PathValue("id")
+		sugar   = h.logger.Sugar()
+	)
+
+	id, ok := sessionIdentity(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.DeleteAccessToken(r.Context(), id.UserID, tokenID); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrAccessTokenNotFound):
+			sugar.Debug(err)
+			WriteError(w, err, http.StatusNotFound)
+
+			return
+		default:
+			sugar.Error(err)
+			WriteError(w, errInternal, http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	sugar.Debugw("access token revoked", "token.id", tokenID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sessionIdentity returns the identity of a caller signed in with a session.
+// Callers using an access token are rejected, so that a leaked token can't be
+// used to mint new ones.
+//
+// If ok is false, an error has already been written to w.
+func sessionIdentity(w http.ResponseWriter, r *http.Request) (id auth.Identity, ok bool) {
+	id, ok = auth.IdentityFromContext(r.Context())
+	if !ok {
+		WriteError(w, errUnauthorized, http.StatusUnauthorized)
+		return id, false
+	}
+
+	if id.Scopes != nil {
+		WriteError(w, errSessionRequired, http.StatusForbidden)
+		return id, false
+	}
+
+	return id, true
+}
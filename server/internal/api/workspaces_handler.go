@@ -0,0 +1,533 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/villaleo/cstash/internal/auth"
+	"github.com/villaleo/cstash/internal/models"
+	"github.com/villaleo/cstash/internal/storage"
+	"go.uber.org/zap"
+)
+
+const maxWorkspaceNameLength = 64
+
+var (
+	errInvalidWorkspaceName = fmt.Errorf("name must be 1-%d characters", maxWorkspaceNameLength)
+	errInvalidRole          = errors.New("role must be one of owner, editor or viewer")
+	errOwnerRequired        = errors.New("only workspace owners may do this")
+)
+
+// newWorkspaceRequest is the request body of the create workspace route
+type newWorkspaceRequest struct {
+	Name string `json:"name"`
+}
+
+// newMemberRequest is the request body of the add member route
+type newMemberRequest struct {
+	Username string      `json:"username"`
+	Role     models.Role `json:"role"`
+}
+
+// memberRoleRequest is the request body of the change member role route
+type memberRoleRequest struct {
+	Role models.Role `json:"role"`
+}
+
+// WorkspaceHandler handles workspace-related API requests. The snippet, tag,
+// language and collection routes of a workspace are served by a
+// SnippetHandler, a TagHandler, a LanguageHandler and a CollectionHandler,
+// with their store calls scoped to the workspace.
+type WorkspaceHandler struct {
+	store       storage.WorkspaceStore
+	users       storage.UserStore
+	snippets    *SnippetHandler
+	tags        *TagHandler
+	languages   *LanguageHandler
+	collections *CollectionHandler
+	logger      *zap.Logger
+}
+
+// NewWorkspaceHandler creates a new workspace handler
+func NewWorkspaceHandler(
+	store storage.WorkspaceStore,
+	users storage.UserStore,
+	snippets *SnippetHandler,
+	tags *TagHandler,
+	languages *LanguageHandler,
+	collections *CollectionHandler,
+	logger *zap.Logger,
+) *WorkspaceHandler {
+	return &WorkspaceHandler{
+		store:       store,
+		users:       users,
+		snippets:    snippets,
+		tags:        tags,
+		languages:   languages,
+		collections: collections,
+		logger:      logger.Named("workspaces"),
+	}
+}
+
+// Logger simply returns this handler's logger. This method is implemented to
+// satisfy logHandler.
+func (h *WorkspaceHandler) Logger() *zap.Logger {
+	return h.logger
+}
+
+// RegisterRoutes registers the workspace API routes
+func (h *WorkspaceHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/v1/workspaces", h.CreateWorkspace)
+	mux.HandleFunc("GET /api/v1/workspaces", h.ListWorkspaces)
+	mux.HandleFunc("GET /api/v1/workspaces/{ws}", h.GetWorkspace)
+	mux.HandleFunc("DELETE /api/v1/workspaces/{ws}", h.DeleteWorkspace)
+
+	mux.HandleFunc("GET /api/v1/workspaces/{ws}/members", h.ListMembers)
+	mux.HandleFunc("POST /api/v1/workspaces/{ws}/members", h.AddMember)
+	mux.HandleFunc("PUT /api/v1/workspaces/{ws}/members/{userId}", h.UpdateMember)
+	mux.HandleFunc("DELETE /api/v1/workspaces/{ws}/members/{userId}", h.RemoveMember)
+
+	mux.HandleFunc("POST /api/v1/workspaces/{ws}/snippets", h.scoped(h.snippets.CreateSnippet))
+	mux.HandleFunc("GET /api/v1/workspaces/{ws}/snippets", h.scoped(h.snippets.ListSnippets))
+	mux.HandleFunc("GET /api/v1/workspaces/{ws}/snippets/{id}", h.scoped(h.snippets.GetSnippet))
+	mux.HandleFunc("PUT /api/v1/workspaces/{ws}/snippets/{id}", h.scoped(h.snippets.UpdateSnippet))
+	mux.HandleFunc("PATCH /api/v1/workspaces/{ws}/snippets/{id}", h.scoped(h.snippets.PatchSnippet))
+	mux.HandleFunc("DELETE /api/v1/workspaces/{ws}/snippets/{id}", h.scoped(h.snippets.DeleteSnippet))
+
+	mux.HandleFunc("GET /api/v1/workspaces/{ws}/snippets/{id}/revisions", h.scoped(h.snippets.ListRevisions))
+	mux.HandleFunc("GET /api/v1/workspaces/{ws}/snippets/{id}/revisions/{rev}", h.scoped(h.snippets.GetRevision))
+	mux.HandleFunc("POST /api/v1/workspaces/{ws}/snippets/{id}/revisions/{rev}/restore", h.scoped(h.snippets.RestoreRevision))
+
+	mux.HandleFunc("GET /api/v1/workspaces/{ws}/snippets/{id}/diff", h.scoped(h.snippets.DiffSnippet))
+	mux.HandleFunc("POST /api/v1/workspaces/{ws}/snippets/{id}/diff", h.scoped(h.snippets.DiffCandidate))
+
+	mux.HandleFunc("GET /api/v1/workspaces/{ws}/snippets/{id}/render", h.scoped(h.snippets.RenderSnippet))
+
+	mux.HandleFunc("GET /api/v1/workspaces/{ws}/tags", h.scoped(h.tags.ListTags))
+	mux.HandleFunc("POST /api/v1/workspaces/{ws}/tags/merge", h.scoped(h.tags.MergeTags))
+	mux.HandleFunc("PUT /api/v1/workspaces/{ws}/tags/{tag...}", h.scoped(h.tags.UpdateTag))
+	mux.HandleFunc("DELETE /api/v1/workspaces/{ws}/tags/{tag...}", h.scoped(h.tags.DeleteTag))
+	mux.HandleFunc("GET /api/v1/workspaces/{ws}/languages", h.scoped(h.languages.ListLanguages))
+
+	mux.HandleFunc("POST /api/v1/workspaces/{ws}/collections", h.scoped(h.collections.CreateCollection))
+	mux.HandleFunc("GET /api/v1/workspaces/{ws}/collections", h.scoped(h.collections.ListCollections))
+	mux.HandleFunc("GET /api/v1/workspaces/{ws}/collections/{id}", h.scoped(h.collections.GetCollection))
+	mux.HandleFunc("PUT /api/v1/workspaces/{ws}/collections/{id}", h.scoped(h.collections.UpdateCollection))
+	mux.HandleFunc("DELETE /api/v1/workspaces/{ws}/collections/{id}", h.scoped(h.collections.DeleteCollection))
+
+	mux.HandleFunc("GET /api/v1/workspaces/{ws}/collections/{id}/snippets", h.scoped(h.collections.ListSnippets))
+	mux.HandleFunc("POST /api/v1/workspaces/{ws}/collections/{id}/snippets", h.scoped(h.collections.AddSnippet))
+	mux.HandleFunc("PUT /api/v1/workspaces/{ws}/collections/{id}/snippets", h.scoped(h.collections.ReorderSnippets))
+	mux.HandleFunc("DELETE /api/v1/workspaces/{ws}/collections/{id}/snippets/{snippetId}", h.scoped(h.collections.RemoveSnippet))
+}
+
+// CreateWorkspace handles creating a new workspace owned by the caller
+func (h *WorkspaceHandler) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var (
+		req   newWorkspaceRequest
+		sugar = h.logger.Sugar()
+	)
+
+	id, ok := auth.IdentityFromContext(r.Context())
+	if !ok {
+		WriteError(w, errUnauthorized, http.StatusUnauthorized)
+		return
+	}
+
+	if err := decodeInto(r.Body, &req); err != nil {
+		sugar.Debug(err)
+		writeBodyError(w, err)
+
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+
+	if req.Name == "" || len(req.Name) > maxWorkspaceNameLength {
+		WriteError(w, errInvalidWorkspaceName, http.StatusBadRequest)
+		return
+	}
+
+	workspace := models.NewWorkspace(req.Name)
+
+	if err := h.store.CreateWorkspace(r.Context(), workspace, id.UserID); err != nil {
+		sugar.Error(err)
+		WriteError(w, errInternal, http.StatusInternalServerError)
+
+		return
+	}
+
+	sugar.Debugw("workspace created", "workspace.id", workspace.ID, "user.id", id.UserID)
+	w.WriteHeader(http.StatusCreated)
+
+	encodeJSON(h, w, workspace)
+}
+
+// ListWorkspaces handles listing the workspaces the caller is a member of
+func (h *WorkspaceHandler) ListWorkspaces(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	sugar := h.logger.Sugar()
+
+	id, ok := auth.IdentityFromContext(r.Context())
+	if !ok {
+		WriteError(w, errUnauthorized, http.StatusUnauthorized)
+		return
+	}
+
+	workspaces, err := h.store.ListWorkspaces(r.Context(), id.UserID)
+	if err != nil {
+		sugar.Error(err)
+		WriteError(w, errInternal, http.StatusInternalServerError)
+
+		return
+	}
+
+	sugar.Debugw("fetched workspaces", "count", len(workspaces))
+
+	encodeJSON(h, w, workspaces)
+}
+
+// GetWorkspace handles retrieving a workspace the caller is a member of
+func (h *WorkspaceHandler) GetWorkspace(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	sugar := h.logger.Sugar()
+
+	membership, ok := h.membership(w, r)
+	if !ok {
+		return
+	}
+
+	workspace, err := h.store.GetWorkspace(r.Context(), membership.WorkspaceID)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrWorkspaceNotFound):
+			sugar.Debug(err)
+			WriteError(w, err, http.StatusNotFound)
+
+			return
+		default:
+			sugar.Error(err)
+			WriteError(w, errInternal, http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	encodeJSON(h, w, workspace)
+}
+
+// DeleteWorkspace handles deleting a workspace along with its snippets. Only
+// owners may delete a workspace.
+func (h *WorkspaceHandler) DeleteWorkspace(w http.ResponseWriter, r *http.Request) {
+	sugar := h.logger.Sugar()
+
+	membership, ok := h.membership(w, r)
+	if !ok {
+		return
+	}
+
+	if !membership.Role.CanManage() {
+		WriteError(w, errOwnerRequired, http.StatusForbidden)
+		return
+	}
+
+	if err := h.store.DeleteWorkspace(r.Context(), membership.WorkspaceID); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrWorkspaceNotFound):
+			sugar.Debug(err)
+			WriteError(w, err, http.StatusNotFound)
+
+			return
+		default:
+			sugar.Error(err)
+			WriteError(w, errInternal, http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	sugar.Debugw("workspace deleted", "workspace.id", membership.WorkspaceID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListMembers handles listing the members of a workspace
+func (h *WorkspaceHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	sugar := h.logger.Sugar()
+
+	membership, ok := h.membership(w, r)
+	if !ok {
+		return
+	}
+
+	members, err := h.store.ListMemberships(r.Context(), membership.WorkspaceID)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrWorkspaceNotFound):
+			sugar.Debug(err)
+			WriteError(w, err, http.StatusNotFound)
+
+			return
+		default:
+			sugar.Error(err)
+			WriteError(w, errInternal, http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	sugar.Debugw("fetched members", "count", len(members))
+
+	encodeJSON(h, w, members)
+}
+
+// AddMember handles adding a user to a workspace by username, or changing
+// their role if they are already a member. Only owners may add members.
+func (h *WorkspaceHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var (
+		req   newMemberRequest
+		sugar = h.logger.Sugar()
+	)
+
+	membership, ok := h.membership(w, r)
+	if !ok {
+		return
+	}
+
+	if !membership.Role.CanManage() {
+		WriteError(w, errOwnerRequired, http.StatusForbidden)
+		return
+	}
+
+	if err := decodeInto(r.Body, &req); err != nil {
+		sugar.Debug(err)
+		writeBodyError(w, err)
+
+		return
+	}
+
+	if !req.Role.Valid() {
+		WriteError(w, errInvalidRole, http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.users.GetUserByUsername(r.Context(), req.Username)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrUserNotFound):
+			sugar.Debug(err)
+			WriteError(w, err, http.StatusNotFound)
+
+			return
+		default:
+			sugar.Error(err)
+			WriteError(w, errInternal, http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	member := &models.Membership{
+		WorkspaceID: membership.WorkspaceID,
+		UserID:      user.ID,
+		Role:        req.Role,
+		CreatedAt:   time.Now(),
+	}
+
+	if !h.putMembership(w, r, member) {
+		return
+	}
+
+	sugar.Debugw("member added", "workspace.id", member.WorkspaceID, "user.id", member.UserID)
+	w.WriteHeader(http.StatusCreated)
+
+	encodeJSON(h, w, member)
+}
+
+// UpdateMember handles changing the role of a workspace member. Only owners
+// may change roles.
+func (h *WorkspaceHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var (
+		req    memberRoleRequest
+		userID = r.PathValue("userId")
+		sugar  = h.logger.Sugar()
+	)
+
+	membership, ok := h.membership(w, r)
+	if !ok {
+		return
+	}
+
+	if !membership.Role.CanManage() {
+		WriteError(w, errOwnerRequired, http.StatusForbidden)
+		return
+	}
+
+	if err := decodeInto(r.Body, &req); err != nil {
+		sugar.Debug(err)
+		writeBodyError(w, err)
+
+		return
+	}
+
+	if !req.Role.Valid() {
+		WriteError(w, errInvalidRole, http.StatusBadRequest)
+		return
+	}
+
+	member, err := h.store.GetMembership(r.Context(), membership.WorkspaceID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrMembershipNotFound):
+			sugar.Debug(err)
+			WriteError(w, err, http.StatusNotFound)
+
+			return
+		default:
+			sugar.Error(err)
+			WriteError(w, errInternal, http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	updated := *member
+	updated.Role = req.Role
+
+	if !h.putMembership(w, r, &updated) {
+		return
+	}
+
+	sugar.Debugw("member updated", "workspace.id", updated.WorkspaceID, "user.id", updated.UserID)
+
+	encodeJSON(h, w, updated)
+}
+
+// RemoveMember handles removing a member from a workspace. Owners may remove
+// anyone, and every member may remove themselves.
+func (h *WorkspaceHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	var (
+		userID = r.PathValue("userId")
+		sugar  = h.logger.Sugar()
+	)
+
+	membership, ok := h.membership(w, r)
+	if !ok {
+		return
+	}
+
+	if userID != membership.UserID && !membership.Role.CanManage() {
+		WriteError(w, errOwnerRequired, http.StatusForbidden)
+		return
+	}
+
+	if err := h.store.DeleteMembership(r.Context(), membership.WorkspaceID, userID); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrMembershipNotFound):
+			sugar.Debug(err)
+			WriteError(w, err, http.StatusNotFound)
+
+			return
+		case errors.Is(err, storage.ErrLastOwner):
+			sugar.Debug(err)
+			WriteError(w, err, http.StatusConflict)
+
+			return
+		default:
+			sugar.Error(err)
+			WriteError(w, errInternal, http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	sugar.Debugw("member removed", "workspace.id", membership.WorkspaceID, "user.id", userID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// scoped wraps next so that its store calls are scoped to the workspace in
+// the request path. Callers who aren't members of the workspace are rejected.
+func (h *WorkspaceHandler) scoped(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		membership, ok := h.membership(w, r)
+		if !ok {
+			return
+		}
+
+		ctx := storage.WithWorkspace(r.Context(), membership)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// membership returns the caller's membership in the workspace in the request
+// path. Workspaces the caller isn't a member of are reported as missing.
+//
+// If ok is false, an error has already been written to w.
+func (h *WorkspaceHandler) membership(w http.ResponseWriter, r *http.Request) (membership *models.Membership, ok bool) {
+	sugar := h.logger.Sugar()
+
+	id, ok := auth.IdentityFromContext(r.Context())
+	if !ok {
+		WriteError(w, errUnauthorized, http.StatusUnauthorized)
+		return nil, false
+	}
+
+	membership, err := h.store.GetMembership(r.Context(), r.PathValue("ws"), id.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrMembershipNotFound):
+			sugar.Debugw("caller isn't a workspace member", "workspace.id", r.PathValue("ws"), "user.id", id.UserID)
+			WriteError(w, storage.ErrWorkspaceNotFound, http.StatusNotFound)
+
+			return nil, false
+		default:
+			sugar.Error(err)
+			WriteError(w, errInternal, http.StatusInternalServerError)
+
+			return nil, false
+		}
+	}
+
+	return membership, true
+}
+
+// putMembership saves membership, reporting whether it succeeded.
+//
+// If it didn't, an error has already been written to w.
+func (h *WorkspaceHandler) putMembership(w http.ResponseWriter, r *http.Request, membership *models.Membership) bool {
+	sugar := h.logger.Sugar()
+
+	if err := h.store.PutMembership(r.Context(), membership); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrWorkspaceNotFound):
+			sugar.Debug(err)
+			WriteError(w, err, http.StatusNotFound)
+
+			return false
+		case errors.Is(err, storage.ErrLastOwner):
+			sugar.Debug(err)
+			WriteError(w, err, http.StatusConflict)
+
+			return false
+		default:
+			sugar.Error(err)
+			WriteError(w, errInternal, http.StatusInternalServerError)
+
+			return false
+		}
+	}
+
+	return true
+}
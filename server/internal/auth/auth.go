@@ -1 +1,124 @@
 package auth
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Scopes that can be granted to personal access tokens
+const (
+	ScopeSnippetsRead  = "snippets:read"
+	ScopeSnippetsWrite = "snippets:write"
+	ScopeTagsRead      = "tags:read"
+	ScopeTagsWrite     = "tags:write"
+	ScopeLanguagesRead = "languages:read"
+
+	ScopeCollectionsRead  = "collections:read"
+	ScopeCollectionsWrite = "collections:write"
+
+	ScopeWorkspacesRead  = "workspaces:read"
+	ScopeWorkspacesWrite = "workspaces:write"
+)
+
+// Scopes lists every scope that can be granted to a personal access token
+var Scopes = []string{
+	ScopeSnippetsRead,
+	ScopeSnippetsWrite,
+	ScopeTagsRead,
+	ScopeTagsWrite,
+	ScopeLanguagesRead,
+	ScopeCollectionsRead,
+	ScopeCollectionsWrite,
+	ScopeWorkspacesRead,
+	ScopeWorkspacesWrite,
+}
+
+// Identity describes the authenticated caller of a request
+type Identity struct {
+	UserID string
+	// Scopes limits what the caller may do. A nil Scopes grants every scope,
+	// as is the case for users signed in with a session.
+	Scopes []string
+}
+
+// HasScope reports whether id has been granted scope
+func (id Identity) HasScope(scope string) bool {
+	return id.Scopes == nil || slices.Contains(id.Scopes, scope)
+}
+
+// identityKey is the context key under which the caller's Identity is stored
+type identityKey struct{}
+
+// WithIdentity returns a copy of ctx carrying id
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFromContext returns the caller's Identity stored in ctx, if any
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+
+	return id, ok
+}
+
+// BearerToken extracts the token from an "Authorization: Bearer <token>"
+// header value
+func BearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+
+	return token, token != ""
+}
+
+// AllowsAnonymous reports whether a request with method to an API path may be
+// made without signing in. Only snippets and their listing may be read that
+// way, and only public snippets are visible to callers who aren't signed in.
+func AllowsAnonymous(method, path string) bool {
+	if method != http.MethodGet && method != http.MethodHead {
+		return false
+	}
+
+	rest, ok := strings.CutPrefix(path, "/api/v1/snippets")
+	if !ok {
+		return false
+	}
+
+	// The listing, or a single snippet but nothing nested below it
+	id, found := strings.CutPrefix(rest, "/")
+
+	return rest == "" || (found && id != "" && !strings.Contains(id, "/"))
+}
+
+// ScopeFor returns the scope needed to make a request with method to an API
+// path. The scope is named after the first path segment following /api/v1/,
+// e.g. "snippets:read" for GET /api/v1/snippets/{id} and "snippets:write"
+// for DELETE /api/v1/snippets/{id}.
+//
+// Snippets and tags in a workspace need the same scopes as personal ones, e.g.
+// "snippets:read" for GET /api/v1/workspaces/{ws}/snippets, while the
+// workspace and its members need the workspaces scopes.
+//
+// Diffs only read snippets, so posting a candidate to diff needs the read
+// scope too.
+func ScopeFor(method, path string) string {
+	resource, rest, _ := strings.Cut(strings.TrimPrefix(path, "/api/v1/"), "/")
+
+	if resource == "workspaces" {
+		_, rest, _ = strings.Cut(rest, "/")
+		if nested, _, _ := strings.Cut(rest, "/"); nested != "" && nested != "members" {
+			resource = nested
+		}
+	}
+
+	if method == http.MethodGet || method == http.MethodHead || strings.HasSuffix(path, "/diff") {
+		return resource + ":read"
+	}
+
+	return resource + ":write"
+}
@@ -0,0 +1,32 @@
+package auth_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/villaleo/cstash/internal/auth"
+)
+
+func TestAllowsAnonymous(t *testing.T) {
+	tests := []struct {
+		method, path string
+		want         bool
+	}{
+		{http.MethodGet, "/api/v1/snippets", true},
+		{http.MethodGet, "/api/v1/snippets/abc", true},
+		{http.MethodHead, "/api/v1/snippets/abc", true},
+		{http.MethodPost, "/api/v1/snippets", false},
+		{http.MethodDelete, "/api/v1/snippets/abc", false},
+		{http.MethodGet, "/api/v1/snippets/", false},
+		{http.MethodGet, "/api/v1/snippets/abc/revisions", false},
+		{http.MethodGet, "/api/v1/snippetsabc", false},
+		{http.MethodGet, "/api/v1/tags", false},
+		{http.MethodGet, "/api/v1/workspaces/ws/snippets", false},
+	}
+
+	for _, tt := range tests {
+		if got := auth.AllowsAnonymous(tt.method, tt.path); got != tt.want {
+			t.Errorf("AllowsAnonymous(%s, %q) = %t, want %t", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
@@ -2,9 +2,13 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"slices"
 	"strings"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 // NewSecureID creates a new random, cryptographically secure ID
@@ -30,3 +34,49 @@ func NewSecureID() string {
 
 	return result.String()
 }
+
+// NewToken creates a new random, cryptographically secure bearer token
+func NewToken() string {
+	var data [32]byte
+
+	_, _ = rand.Read(data[:])
+
+	return base64.RawURLEncoding.EncodeToString(data[:])
+}
+
+// accessTokenPrefix marks personal access tokens, telling them apart from
+// session tokens and making them easy to spot in leaked secrets
+const accessTokenPrefix = "cstash_pat_"
+
+// NewAccessToken creates a new random, cryptographically secure personal
+// access token
+func NewAccessToken() string {
+	return accessTokenPrefix + NewToken()
+}
+
+// IsAccessToken reports whether token is a personal access token
+func IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, accessTokenPrefix)
+}
+
+// HashToken returns the hash of token under which it is stored
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// HashPassword hashes password with bcrypt
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password matches hash
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
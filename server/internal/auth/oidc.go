@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrMissingIDToken = errors.New("token response did not include an id_token")
+	ErrNonceMismatch  = errors.New("id token nonce does not match")
+
+	usernameInvalidChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+)
+
+// OIDCConfig configures single sign-on with an OpenID Connect provider
+type OIDCConfig struct {
+	// IssuerURL is the provider's issuer, used to discover its endpoints
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is cstash's callback URL registered with the provider
+	RedirectURL string
+}
+
+// OIDCProvider signs users in with the authorization code flow of an OpenID
+// Connect provider, using PKCE and verifying ID tokens against the provider's
+// published keys
+type OIDCProvider struct {
+	oauth2   oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// OIDCClaims are the ID token claims cstash uses to map a provider account to
+// a cstash user
+type OIDCClaims struct {
+	Issuer            string `json:"iss"`
+	Subject           string `json:"sub"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+	Name              string `json:"name"`
+}
+
+// OIDCLogin holds the per-login secrets that must survive the round trip to
+// the provider
+type OIDCLogin struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// NewOIDCProvider discovers the provider's endpoints and signing keys from its
+// issuer URL
+func NewOIDCProvider(ctx context.Context, cfg OIDCConfig) (*OIDCProvider, error) {
+	provider, err := oidc.NewProvider(ctx, cfg.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("discover oidc provider: %w", err)
+	}
+
+	return &OIDCProvider{
+		oauth2: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+	}, nil
+}
+
+// NewLogin creates the secrets for a new login attempt
+func (p *OIDCProvider) NewLogin() OIDCLogin {
+	return OIDCLogin{
+		State:    NewToken(),
+		Nonce:    NewToken(),
+		Verifier: oauth2.GenerateVerifier(),
+	}
+}
+
+// AuthCodeURL returns the provider URL to send the user to for login
+func (p *OIDCProvider) AuthCodeURL(login OIDCLogin) string {
+	return p.oauth2.AuthCodeURL(login.State,
+		oidc.Nonce(login.Nonce),
+		oauth2.S256ChallengeOption(login.Verifier),
+	)
+}
+
+// Exchange trades the authorization code returned to the callback for tokens
+// and returns the claims of the verified ID token
+func (p *OIDCProvider) Exchange(ctx context.Context, code string, login OIDCLogin) (*OIDCClaims, error) {
+	token, err := p.oauth2.Exchange(ctx, code, oauth2.VerifierOption(login.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("exchange authorization code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, ErrMissingIDToken
+	}
+
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("verify id token: %w", err)
+	}
+
+	if idToken.Nonce != login.Nonce {
+		return nil, ErrNonceMismatch
+	}
+
+	var claims OIDCClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("decode id token claims: %w", err)
+	}
+
+	return &claims, nil
+}
+
+// ExternalID returns the identifier of the account at the provider, unique
+// across providers
+func (c *OIDCClaims) ExternalID() string {
+	return c.Issuer + "|" + c.Subject
+}
+
+// Username suggests a cstash username for the account, derived from the
+// preferred username or the email address. The result may already be taken.
+func (c *OIDCClaims) Username() string {
+	name := c.PreferredUsername
+	if name == "" {
+		name, _, _ = strings.Cut(c.Email, "@")
+	}
+
+	name = usernameInvalidChars.ReplaceAllString(strings.ToLower(name), "-")
+	name = strings.Trim(name, "-")
+
+	if len(name) > 24 {
+		name = name[:24]
+	}
+
+	switch {
+	case name == "":
+		name = "user"
+	case len(name) < 3:
+		name = "user-" + name
+	}
+
+	return name
+}
@@ -0,0 +1,114 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/villaleo/cstash/internal/auth"
+	"github.com/villaleo/cstash/internal/auth/oidctest"
+)
+
+const redirectURL = "http://cstash.test/api/v1/auth/oidc/callback"
+
+// newProvider returns a provider discovered from issuer
+func newProvider(t *testing.T, issuer *oidctest.Issuer) *auth.OIDCProvider {
+	t.Helper()
+
+	provider, err := auth.NewOIDCProvider(context.Background(), auth.OIDCConfig{
+		IssuerURL:    issuer.URL,
+		ClientID:     oidctest.ClientID,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  redirectURL,
+	})
+	if err != nil {
+		t.Fatalf("NewOIDCProvider() = %v", err)
+	}
+
+	return provider
+}
+
+func TestOIDCProviderExchange(t *testing.T) {
+	issuer := oidctest.NewIssuer(t)
+	issuer.Claims["email"] = "alice@example.com"
+	issuer.Claims["preferred_username"] = "Alice"
+
+	var (
+		provider = newProvider(t, issuer)
+		login    = provider.NewLogin()
+		callback = issuer.SignIn(t, provider.AuthCodeURL(login))
+	)
+
+	if got := callback.Query().Get("state"); got != login.State {
+		t.Errorf("state = %q, want %q", got, login.State)
+	}
+
+	claims, err := provider.Exchange(context.Background(), callback.Query().Get("code"), login)
+	if err != nil {
+		t.Fatalf("Exchange() = %v", err)
+	}
+
+	if want := issuer.URL + "|alice"; claims.ExternalID() != want {
+		t.Errorf("ExternalID() = %q, want %q", claims.ExternalID(), want)
+	}
+
+	if claims.Email != "alice@example.com" || claims.Username() != "alice" {
+		t.Errorf("claims = %q %q, want \"alice@example.com\" \"alice\"", claims.Email, claims.Username())
+	}
+}
+
+func TestOIDCProviderExchangeFails(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup changes the issuer or the login the code is exchanged with
+		setup func(issuer *oidctest.Issuer, login *auth.OIDCLogin)
+		// want is the error expected, or part of its message
+		want any
+	}{
+		{"with another PKCE verifier", func(_ *oidctest.Issuer, login *auth.OIDCLogin) {
+			login.Verifier = "not-the-verifier-the-challenge-was-made-from"
+		}, "invalid_grant"},
+		{"with another nonce", func(_ *oidctest.Issuer, login *auth.OIDCLogin) {
+			login.Nonce = "not-the-nonce"
+		}, auth.ErrNonceMismatch},
+		{"with a bad signature", func(issuer *oidctest.Issuer, _ *auth.OIDCLogin) {
+			issuer.BadSignature = true
+		}, "failed to verify signature"},
+		{"for another client", func(issuer *oidctest.Issuer, _ *auth.OIDCLogin) {
+			issuer.Claims["aud"] = "someone-else"
+		}, "expected audience"},
+		{"without an ID token", func(issuer *oidctest.Issuer, _ *auth.OIDCLogin) {
+			issuer.OmitIDToken = true
+		}, auth.ErrMissingIDToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				issuer   = oidctest.NewIssuer(t)
+				provider = newProvider(t, issuer)
+				login    = provider.NewLogin()
+				callback = issuer.SignIn(t, provider.AuthCodeURL(login))
+			)
+
+			tt.setup(issuer, &login)
+
+			claims, err := provider.Exchange(context.Background(), callback.Query().Get("code"), login)
+			if err == nil {
+				t.Fatalf("Exchange() = %+v, want an error", claims)
+			}
+
+			switch want := tt.want.(type) {
+			case error:
+				if !errors.Is(err, want) {
+					t.Errorf("Exchange() = %v, want %v", err, want)
+				}
+			case string:
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Exchange() = %v, want an error containing %q", err, want)
+				}
+			}
+		})
+	}
+}
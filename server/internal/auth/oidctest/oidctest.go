@@ -0,0 +1,268 @@
+// Package oidctest provides an OpenID Connect provider for tests, serving
+// discovery, signing keys, and the authorization code flow with PKCE
+package oidctest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Credentials of the only client the issuer knows
+const (
+	ClientID     = "cstash"
+	ClientSecret = "secret"
+)
+
+// keyID identifies the issuer's published signing key
+const keyID = "test-key"
+
+// Issuer is an OpenID Connect provider running on a local HTTP server. Every
+// user who's sent to it to sign in is signed in at once, as the subject of
+// its ID tokens.
+type Issuer struct {
+	*httptest.Server
+
+	// Claims are added to the ID tokens the issuer signs, and may override
+	// the standard ones. Subject is "alice" unless set here as "sub".
+	Claims map[string]any
+	// BadSignature makes the issuer sign ID tokens with a key it doesn't
+	// publish, as a forged token would be
+	BadSignature bool
+	// OmitIDToken makes the issuer leave the ID token out of its token
+	// responses
+	OmitIDToken bool
+
+	key, otherKey *rsa.PrivateKey
+
+	mu sync.Mutex
+	// requests holds the authorization requests the issuer has redirected
+	// back from, by the code it issued for them
+	requests map[string]url.Values
+}
+
+// NewIssuer starts an issuer, which is closed when t ends
+func NewIssuer(t *testing.T) *Issuer {
+	t.Helper()
+
+	issuer := &Issuer{
+		Claims:   map[string]any{},
+		key:      newKey(t),
+		otherKey: newKey(t),
+		requests: make(map[string]url.Values),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", issuer.discovery)
+	mux.HandleFunc("GET /keys", issuer.keys)
+	mux.HandleFunc("GET /authorize", issuer.authorize)
+	mux.HandleFunc("POST /token", issuer.token)
+
+	issuer.Server = httptest.NewServer(mux)
+	t.Cleanup(issuer.Close)
+
+	return issuer
+}
+
+// newKey generates an RSA signing key
+func newKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	return key
+}
+
+// SignIn follows authURL, an authorization URL of the issuer, as a browser
+// would, and returns the redirect back to the client that it answers with
+func (i *Issuer) SignIn(t *testing.T, authURL string) *url.URL {
+	t.Helper()
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("sign in: status %d, want %d", resp.StatusCode, http.StatusFound)
+	}
+
+	location, err := resp.Location()
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+
+	return location
+}
+
+// discovery serves the provider metadata clients discover endpoints from
+func (i *Issuer) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                i.URL,
+		"authorization_endpoint":                i.URL + "/authorize",
+		"token_endpoint":                        i.URL + "/token",
+		"jwks_uri":                              i.URL + "/keys",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+// keys serves the issuer's public signing key as a JSON Web Key Set
+func (i *Issuer) keys(w http.ResponseWriter, _ *http.Request) {
+	public := i.key.PublicKey
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]any{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": keyID,
+			"n":   encode(public.N.Bytes()),
+			"e":   encode(big.NewInt(int64(public.E)).Bytes()),
+		}},
+	})
+}
+
+// authorize signs the user in at once and redirects them back to the client
+// with a new code
+func (i *Issuer) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || query.Get("client_id") != ClientID || query.Get("response_type") != "code" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	code := randomString()
+
+	i.mu.Lock()
+	i.requests[code] = query
+	i.mu.Unlock()
+
+	values := redirect.Query()
+	values.Set("code", code)
+	values.Set("state", query.Get("state"))
+	redirect.RawQuery = values.Encode()
+
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// token trades a code for an ID token. The code's PKCE challenge must be met
+// by the request's verifier, and each code may only be used once.
+func (i *Issuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	if id != ClientID || secret != ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	code := r.PostForm.Get("code")
+
+	i.mu.Lock()
+	request, ok := i.requests[code]
+	delete(i.requests, code)
+	i.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+
+	if !ok ||
+		request.Get("code_challenge_method") != "S256" ||
+		request.Get("code_challenge") != encode(challenge[:]) ||
+		request.Get("redirect_uri") != r.PostForm.Get("redirect_uri") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	response := map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+	}
+
+	if !i.OmitIDToken {
+		response["id_token"] = i.idToken(request.Get("nonce"))
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// idToken returns a signed ID token carrying nonce
+func (i *Issuer) idToken(nonce string) string {
+	now := time.Now()
+	claims := map[string]any{
+		"iss":   i.URL,
+		"sub":   "alice",
+		"aud":   ClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"nonce": nonce,
+	}
+
+	for name, value := range i.Claims {
+		claims[name] = value
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": keyID})
+	payload, _ := json.Marshal(claims)
+	signed := encode(header) + "." + encode(payload)
+
+	key := i.key
+	if i.BadSignature {
+		key = i.otherKey
+	}
+
+	digest := sha256.Sum256([]byte(signed))
+	signature, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+
+	return signed + "." + encode(signature)
+}
+
+// encode encodes data as unpadded base64url, as JSON Web Tokens and Keys do
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// randomString returns a random string usable as a code or token
+func randomString() string {
+	data := make([]byte, 16)
+	_, _ = rand.Read(data)
+
+	return encode(data)
+}
+
+// writeJSON writes v as a JSON response with status
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
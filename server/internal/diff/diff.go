@@ -0,0 +1,342 @@
+// Package diff computes line-based differences between texts and formats them
+// as unified diffs.
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// maxEdits bounds the work done by Myers' algorithm, whose memory use grows
+// with the square of the number of edits. Texts that differ by more lines are
+// diffed as a replacement of everything between their common prefix and
+// suffix.
+const maxEdits = 2000
+
+// Op is the kind of a line in a diff
+type Op string
+
+const (
+	// OpEqual lines appear in both texts
+	OpEqual Op = " "
+	// OpDelete lines only appear in the old text
+	OpDelete Op = "-"
+	// OpInsert lines only appear in the new text
+	OpInsert Op = "+"
+)
+
+// Line is a line of a diff. Text doesn't include the line's newline.
+type Line struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+	// NoNewline is set for a last line that doesn't end with a newline
+	NoNewline bool `json:"noNewline,omitempty"`
+}
+
+// Hunk is a run of changed lines along with the unchanged lines around them.
+// Lines are numbered from 1; a hunk with no lines of a text starts at the
+// line before.
+type Hunk struct {
+	FromLine  int    `json:"fromLine"`
+	FromCount int    `json:"fromCount"`
+	ToLine    int    `json:"toLine"`
+	ToCount   int    `json:"toCount"`
+	Lines     []Line `json:"lines"`
+}
+
+// Lines returns the lines of the shortest diff turning from into to, found
+// with Myers' algorithm
+func Lines(from, to string) []Line {
+	var (
+		a = splitLines(from)
+		b = splitLines(to)
+	)
+
+	// Common prefixes and suffixes are cheap to find and keep the part Myers'
+	// algorithm works on small
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]Line, 0, len(a)+len(b))
+
+	for _, text := range a[:prefix] {
+		lines = append(lines, newLine(OpEqual, text))
+	}
+
+	lines = append(lines, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+
+	for _, text := range a[len(a)-suffix:] {
+		lines = append(lines, newLine(OpEqual, text))
+	}
+
+	return lines
+}
+
+// Hunks groups the changed lines of a diff into hunks, each with up to context
+// unchanged lines before and after. Changes separated by at most twice context
+// unchanged lines share a hunk.
+func Hunks(lines []Line, context int) []Hunk {
+	var (
+		hunks        []Hunk
+		current      *Hunk
+		fromN, toN   = 1, 1
+		lastChange   = -1
+		pendingEqual []Line
+	)
+
+	for i, line := range lines {
+		if line.Op == OpEqual {
+			if current != nil && i-lastChange > 2*context {
+				// Close the hunk with the context following its last change
+				trailing := pendingEqual[:context]
+				current.Lines = append(current.Lines, trailing...)
+				current.FromCount += len(trailing)
+				current.ToCount += len(trailing)
+				hunks = append(hunks, *current)
+				current = nil
+			}
+
+			pendingEqual = append(pendingEqual, line)
+			fromN++
+			toN++
+
+			continue
+		}
+
+		if current == nil {
+			leading := pendingEqual[max(0, len(pendingEqual)-context):]
+			current = &Hunk{
+				FromLine:  fromN - len(leading),
+				FromCount: len(leading),
+				ToLine:    toN - len(leading),
+				ToCount:   len(leading),
+				Lines:     slices.Clone(leading),
+			}
+		} else {
+			current.Lines = append(current.Lines, pendingEqual...)
+			current.FromCount += len(pendingEqual)
+			current.ToCount += len(pendingEqual)
+		}
+
+		pendingEqual = nil
+		current.Lines = append(current.Lines, line)
+		lastChange = i
+
+		if line.Op == OpDelete {
+			current.FromCount++
+			fromN++
+		} else {
+			current.ToCount++
+			toN++
+		}
+	}
+
+	if current != nil {
+		trailing := pendingEqual[:min(context, len(pendingEqual))]
+		current.Lines = append(current.Lines, trailing...)
+		current.FromCount += len(trailing)
+		current.ToCount += len(trailing)
+		hunks = append(hunks, *current)
+	}
+
+	// A hunk with no lines of a text starts at the line before
+	for i := range hunks {
+		if hunks[i].FromCount == 0 {
+			hunks[i].FromLine--
+		}
+
+		if hunks[i].ToCount == 0 {
+			hunks[i].ToLine--
+		}
+	}
+
+	return hunks
+}
+
+// Unified formats hunks as a unified diff from the file fromName to the file
+// toName. It returns an empty string if there are no hunks.
+func Unified(fromName, toName string, hunks []Hunk) string {
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+
+	for _, hunk := range hunks {
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n",
+			formatRange(hunk.FromLine, hunk.FromCount), formatRange(hunk.ToLine, hunk.ToCount),
+		)
+
+		for _, line := range hunk.Lines {
+			sb.WriteString(string(line.Op))
+			sb.WriteString(line.Text)
+			sb.WriteByte('\n')
+
+			if line.NoNewline {
+				sb.WriteString("\\ No newline at end of file\n")
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// formatRange formats the range of a hunk's lines in one text, leaving out a
+// count of 1 like diff does
+func formatRange(line, count int) string {
+	if count == 1 {
+		return fmt.Sprint(line)
+	}
+
+	return fmt.Sprintf("%d,%d", line, count)
+}
+
+// splitLines splits text into lines, keeping their newlines so that a last
+// line without one differs from the same line with one
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// newLine returns the diff line with op for text, a line split by splitLines
+func newLine(op Op, text string) Line {
+	trimmed, hasNewline := strings.CutSuffix(text, "\n")
+
+	return Line{Op: op, Text: trimmed, NoNewline: !hasNewline}
+}
+
+// myers returns the lines of the shortest diff turning a into b. It follows
+// the greedy algorithm of "An O(ND) Difference Algorithm and Its Variations",
+// keeping the furthest reaching path of every diagonal after each number of
+// edits so that the path can be traced back.
+func myers(a, b []string) []Line {
+	var (
+		n, m  = len(a), len(b)
+		limit = min(n+m, maxEdits)
+		// v holds the furthest x reached on each diagonal k = x - y, offset
+		// so that k can be negative
+		offset = limit + 1
+		v      = make([]int, 2*offset+1)
+		// trace[d] holds v[-d..d] after d edits
+		trace [][]int
+	)
+
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+
+			// Move down (an insertion) from diagonal k+1, or right (a
+			// deletion) from diagonal k-1, whichever reaches further
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+
+			// Follow the snake of equal lines
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				trace = append(trace, slices.Clone(v[offset-d:offset+d+1]))
+
+				return backtrack(a, b, trace)
+			}
+		}
+
+		trace = append(trace, slices.Clone(v[offset-d:offset+d+1]))
+	}
+
+	// Too many edits to trace; replace a with b
+	lines := make([]Line, 0, n+m)
+
+	for _, text := range a {
+		lines = append(lines, newLine(OpDelete, text))
+	}
+
+	for _, text := range b {
+		lines = append(lines, newLine(OpInsert, text))
+	}
+
+	return lines
+}
+
+// backtrack follows the path found by myers from the end of a and b back to
+// their start, and returns its lines in order
+func backtrack(a, b []string, trace [][]int) []Line {
+	var (
+		lines []Line
+		x, y  = len(a), len(b)
+	)
+
+	for d := len(trace) - 1; d > 0; d-- {
+		var (
+			prev  = trace[d-1]
+			at    = func(k int) int { return prev[k+d-1] }
+			k     = x - y
+			prevK int
+		)
+
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		// The edit leads from the previous path to the start of the snake
+		startX := prevX
+		if prevK == k-1 {
+			startX++
+		}
+
+		for x > startX {
+			lines = append(lines, newLine(OpEqual, a[x-1]))
+			x--
+			y--
+		}
+
+		if prevK == k+1 {
+			lines = append(lines, newLine(OpInsert, b[y-1]))
+		} else {
+			lines = append(lines, newLine(OpDelete, a[x-1]))
+		}
+
+		x, y = prevX, prevY
+	}
+
+	for x > 0 {
+		lines = append(lines, newLine(OpEqual, a[x-1]))
+		x--
+	}
+
+	slices.Reverse(lines)
+
+	return lines
+}
@@ -0,0 +1,100 @@
+package diff_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/villaleo/cstash/internal/diff"
+)
+
+// rebuild returns the texts a diff turns from and into
+func rebuild(lines []diff.Line) (from, to string) {
+	var a, b strings.Builder
+
+	for _, line := range lines {
+		text := line.Text
+		if !line.NoNewline {
+			text += "\n"
+		}
+
+		if line.Op != diff.OpInsert {
+			a.WriteString(text)
+		}
+
+		if line.Op != diff.OpDelete {
+			b.WriteString(text)
+		}
+	}
+
+	return a.String(), b.String()
+}
+
+// numbered returns n lines numbered from start, each prefixed with prefix
+func numbered(prefix string, start, n int) string {
+	var b strings.Builder
+	for i := start; i < start+n; i++ {
+		fmt.Fprintf(&b, "%s%d\n", prefix, i)
+	}
+
+	return b.String()
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		// edits is the number of lines deleted or inserted by the shortest
+		// diff
+		edits int
+	}{
+		{"both empty", "", "", 0},
+		{"equal", "a\nb\nc\n", "a\nb\nc\n", 0},
+		{"from empty", "", "a\nb\n", 2},
+		{"to empty", "a\nb\n", "", 2},
+		{"a line inserted", "a\nc\n", "a\nb\nc\n", 1},
+		{"a line deleted", "a\nb\nc\n", "a\nc\n", 1},
+		{"a line changed", "a\nb\nc\n", "a\nx\nc\n", 2},
+		{"lines moved", "a\nb\nc\nd\n", "c\nd\na\nb\n", 4},
+		{"the paper's example", "a\nb\nc\na\nb\nb\na\n", "c\nb\na\nb\na\nc\n", 5},
+		{"blank lines", "\n\n\n", "\n\n", 1},
+
+		// A last line without a newline differs from the same line with one
+		{"newline removed at the end", "a\nb\n", "a\nb", 2},
+		{"newline added at the end", "a\nb", "a\nb\n", 2},
+		{"no newline at the end of either", "a\nb", "a\nc", 2},
+		{"line appended after no newline", "a\nb", "a\nb\nc", 3},
+		{"only a missing newline", "a", "a\n", 2},
+		{"unchanged without newlines", "a\nb", "a\nb", 0},
+
+		// Texts differing by too many lines are diffed as a replacement of
+		// everything between their common prefix and suffix
+		{"too many edits", "first\n" + numbered("a", 0, 1500) + "last", "first\n" + numbered("b", 0, 1500) + "last", 3000},
+		{"many edits", numbered("a", 0, 1000), numbered("a", 500, 1000), 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := diff.Lines(tt.from, tt.to)
+
+			if from, to := rebuild(lines); from != tt.from || to != tt.to {
+				t.Fatalf("Lines() rebuilds %q and %q, want %q and %q", from, to, tt.from, tt.to)
+			}
+
+			edits := 0
+			for i, line := range lines {
+				if line.Op != diff.OpEqual {
+					edits++
+				}
+
+				if strings.Contains(line.Text, "\n") {
+					t.Errorf("line %d = %q, want no newline in its text", i, line.Text)
+				}
+			}
+
+			if edits != tt.edits {
+				t.Errorf("Lines() = %d edits, want %d", edits, tt.edits)
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package highlight
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrUnknownFormat is returned when asked for a format that doesn't exist
+var ErrUnknownFormat = errors.New("unknown format")
+
+// Format is an output format highlighted code can be written in
+type Format string
+
+const (
+	HTML Format = "html"
+	ANSI Format = "ansi"
+	SVG  Format = "svg"
+)
+
+// Formats lists the formats in the order they're documented
+var Formats = []Format{HTML, ANSI, SVG}
+
+// ContentType returns the media type of output in the format
+func (f Format) ContentType() string {
+	switch f {
+	case ANSI:
+		return "text/plain; charset=utf-8"
+	case SVG:
+		return "image/svg+xml"
+	default:
+		return "text/html; charset=utf-8"
+	}
+}
+
+// Valid reports whether f is a known format
+func (f Format) Valid() bool {
+	switch f {
+	case HTML, ANSI, SVG:
+		return true
+	default:
+		return false
+	}
+}
+
+// Layout of SVG output in pixels. Glyphs of the monospace font are assumed to
+// be 0.6 em wide, which is close for the common ones.
+const (
+	svgFontSize   = 14
+	svgLineHeight = 20
+	svgCharWidth  = svgFontSize * 0.6
+	svgPadding    = 16
+	svgTabWidth   = 4
+)
+
+// Write writes tokens to w in the format, colored with the theme
+func Write(w io.Writer, format Format, theme *Theme, tokens []Token) error {
+	var b strings.Builder
+
+	switch format {
+	case HTML:
+		writeHTML(&b, theme, tokens)
+	case ANSI:
+		writeANSI(&b, theme, tokens)
+	case SVG:
+		writeSVG(&b, theme, tokens)
+	default:
+		return ErrUnknownFormat
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// writeHTML writes a pre element with inline styles, so that the output
+// needs no stylesheet wherever it is embedded
+func writeHTML(b *strings.Builder, theme *Theme, tokens []Token) {
+	fmt.Fprintf(b, `<pre class="highlight" style="background-color:%s;color:%s;padding:1em;overflow:auto"><code>`,
+		theme.Background, theme.Foreground)
+
+	for _, token := range tokens {
+		text := html.EscapeString(token.Text)
+		if token.Kind == Plain {
+			b.WriteString(text)
+			continue
+		}
+
+		style := theme.style(token.Kind)
+		fmt.Fprintf(b, `<span class="%s" style="%s">%s</span>`, token.Kind, cssStyle(style), text)
+	}
+
+	b.WriteString("</code></pre>\n")
+}
+
+// cssStyle returns the declarations of an inline style attribute for style
+func cssStyle(style Style) string {
+	css := "color:" + style.Color
+	if style.Bold {
+		css += ";font-weight:bold"
+	}
+
+	if style.Italic {
+		css += ";font-style:italic"
+	}
+
+	return css
+}
+
+// writeANSI writes tokens colored with 24-bit ANSI escape sequences. Styles
+// are reset at the end of every line so that lines can be copied on their own.
+// Control characters in the code are shown rather than run by the terminal,
+// as printable does.
+func writeANSI(b *strings.Builder, theme *Theme, tokens []Token) {
+	for _, token := range tokens {
+		if token.Kind == Plain {
+			b.WriteString(printable(token.Text))
+			continue
+		}
+
+		escape := ansiEscape(theme.style(token.Kind))
+
+		for i, line := range strings.Split(printable(token.Text), "\n") {
+			if i > 0 {
+				b.WriteByte('\n')
+			}
+
+			if line != "" {
+				b.WriteString(escape + line + "\x1b[0m")
+			}
+		}
+	}
+}
+
+// ansiEscape returns the escape sequence that sets the color and font of
+// style
+func ansiEscape(style Style) string {
+	params := []string{}
+	if style.Bold {
+		params = append(params, "1")
+	}
+
+	if style.Italic {
+		params = append(params, "3")
+	}
+
+	if r, g, bl, ok := parseHex(style.Color); ok {
+		params = append(params, fmt.Sprintf("38;2;%d;%d;%d", r, g, bl))
+	}
+
+	return "\x1b[" + strings.Join(params, ";") + "m"
+}
+
+// parseHex parses a color like #d73a49
+func parseHex(color string) (r, g, b uint8, ok bool) {
+	if len(color) != 7 || color[0] != '#' {
+		return 0, 0, 0, false
+	}
+
+	rgb, err := strconv.ParseUint(color[1:], 16, 32)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+
+	return uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb), true
+}
+
+// writeSVG writes a standalone SVG image of the code, one text element per
+// line. Tabs are expanded since SVG text has no tab stops, and control
+// characters, which XML doesn't allow, are replaced as printable does.
+func writeSVG(b *strings.Builder, theme *Theme, tokens []Token) {
+	lines := [][]Token{nil}
+
+	for _, token := range tokens {
+		for i, text := range strings.Split(printable(token.Text), "\n") {
+			if i > 0 {
+				lines = append(lines, nil)
+			}
+
+			if text != "" {
+				lines[len(lines)-1] = append(lines[len(lines)-1], Token{Kind: token.Kind, Text: text})
+			}
+		}
+	}
+
+	// A trailing newline doesn't start another line
+	if len(lines) > 1 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+
+	var (
+		body  strings.Builder
+		width int
+	)
+
+	for i, line := range lines {
+		column := 0
+
+		fmt.Fprintf(&body, `<text x="%d" y="%d" xml:space="preserve">`, svgPadding, svgPadding+(i+1)*svgLineHeight-svgLineHeight/4)
+
+		for _, token := range line {
+			text := expandTabs(token.Text, &column)
+			if token.Kind == Plain {
+				body.WriteString(html.EscapeString(text))
+				continue
+			}
+
+			style := theme.style(token.Kind)
+			fmt.Fprintf(&body, `<tspan fill="%s"%s>%s</tspan>`, style.Color, svgFont(style), html.EscapeString(text))
+		}
+
+		body.WriteString("</text>\n")
+
+		width = max(width, column)
+	}
+
+	imageWidth := int(float64(width)*svgCharWidth) + 2*svgPadding
+	imageHeight := len(lines)*svgLineHeight + 2*svgPadding
+
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		imageWidth, imageHeight, imageWidth, imageHeight)
+	fmt.Fprintf(b, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", theme.Background)
+	fmt.Fprintf(b, `<g font-family="ui-monospace,SFMono-Regular,Menlo,Consolas,monospace" font-size="%d" fill="%s">`+"\n",
+		svgFontSize, theme.Foreground)
+	b.WriteString(body.String())
+	b.WriteString("</g>\n</svg>\n")
+}
+
+// svgFont returns the font attributes of a tspan drawn in style
+func svgFont(style Style) string {
+	font := ""
+	if style.Bold {
+		font += ` font-weight="bold"`
+	}
+
+	if style.Italic {
+		font += ` font-style="italic"`
+	}
+
+	return font
+}
+
+// printable returns text with the control characters other than newlines and
+// tabs replaced, so that escape sequences in code can't act on the terminal it
+// is printed to. C0 controls and DEL are replaced by their Unicode control
+// pictures, like ␛ for escape, except for carriage returns, which are dropped
+// so that CRLF line endings print as newlines. C1 controls, noncharacters XML
+// doesn't allow and invalid UTF-8 are replaced by U+FFFD.
+func printable(text string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\t':
+			return r
+		case r == '\r':
+			return -1
+		case r < 0x20:
+			return '\u2400' + r
+		case r == 0x7f:
+			return '\u2421'
+		case r >= 0x80 && r <= 0x9f, r == 0xfffe, r == 0xffff:
+			return utf8.RuneError
+		default:
+			return r
+		}
+	}, text)
+}
+
+// expandTabs replaces the tabs in text with spaces up to the next tab stop,
+// advancing column by the width of text
+func expandTabs(text string, column *int) string {
+	if !strings.Contains(text, "\t") {
+		*column += utf8.RuneCountInString(text)
+		return text
+	}
+
+	var b strings.Builder
+
+	for _, r := range text {
+		if r == '\t' {
+			spaces := svgTabWidth - *column%svgTabWidth
+			b.WriteString(strings.Repeat(" ", spaces))
+			*column += spaces
+
+			continue
+		}
+
+		b.WriteRune(r)
+		*column++
+	}
+
+	return b.String()
+}
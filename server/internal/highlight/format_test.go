@@ -0,0 +1,78 @@
+package highlight_test
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/villaleo/cstash/internal/highlight"
+)
+
+// hostile is code that rewrites the clipboard and the window title of the
+// terminal it's printed to, with a CSI sequence and a C1 control as well
+const hostile = "echo hi # \x1b]52;c;cm0gLXJmIH4=\x07 \x1b]0;owned\x1b\\\r\n" +
+	"printf '\x1b[2J\u009b31m'\n"
+
+// styleEscape matches the escape sequences that set the color and font
+var styleEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+func render(t *testing.T, format highlight.Format, language, content string) string {
+	t.Helper()
+
+	theme, err := highlight.LookupTheme("")
+	if err != nil {
+		t.Fatalf("LookupTheme() = %v", err)
+	}
+
+	var b strings.Builder
+
+	if err := highlight.Write(&b, format, theme, highlight.Tokenize(language, content)); err != nil {
+		t.Fatalf("Write(%s) = %v", format, err)
+	}
+
+	return b.String()
+}
+
+func TestWriteANSIShowsControlCharacters(t *testing.T) {
+	// Both a known syntax, which styles the code, and plain text
+	for _, language := range []string{"bash", ""} {
+		out := render(t, highlight.ANSI, language, hostile)
+
+		// The only escape sequences left are the ones setting styles
+		unstyled := styleEscape.ReplaceAllString(out, "")
+
+		for _, control := range []string{"\x1b", "\x07", "\r", "\u009b"} {
+			if strings.Contains(unstyled, control) {
+				t.Errorf("ANSI output for %q contains %q", language, control)
+			}
+		}
+
+		if !strings.Contains(unstyled, "␛]52;c;cm0gLXJmIH4=␇") {
+			t.Errorf("ANSI output for %q = %q, want the clipboard sequence shown", language, out)
+		}
+
+		if got := strings.Count(out, "\n"); got != 2 {
+			t.Errorf("ANSI output for %q has %d lines, want 2", language, got)
+		}
+	}
+}
+
+func TestWriteSVGIsValidXML(t *testing.T) {
+	for _, language := range []string{"bash", ""} {
+		decoder := xml.NewDecoder(strings.NewReader(render(t, highlight.SVG, language, hostile)))
+
+		for {
+			_, err := decoder.Token()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			if err != nil {
+				t.Fatalf("SVG output for %q isn't valid XML: %v", language, err)
+			}
+		}
+	}
+}
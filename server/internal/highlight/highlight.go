@@ -0,0 +1,146 @@
+// Package highlight splits source code into tokens of the kinds a syntax
+// highlighter colors, and formats them as HTML, ANSI escape sequences or SVG.
+package highlight
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Kind is the kind of a token, which decides its color
+type Kind string
+
+const (
+	Plain    Kind = "plain"
+	Keyword  Kind = "keyword"
+	Type     Kind = "type"
+	String   Kind = "string"
+	Number   Kind = "number"
+	Comment  Kind = "comment"
+	Operator Kind = "operator"
+)
+
+// Token is a piece of source code of a single kind
+type Token struct {
+	Kind Kind
+	Text string
+}
+
+// operatorChars are the characters tokenized as operators and punctuation
+const operatorChars = "+-*/%=<>!&|^~?:;,.(){}[]@#\\"
+
+// Tokenize splits content into tokens according to the syntax of the
+// language with the ID language. Content in a language without a known syntax
+// is a single plain token. Joining the text of the tokens gives back content.
+func Tokenize(language, content string) []Token {
+	syn, ok := syntaxes[language]
+	if !ok {
+		if content == "" {
+			return nil
+		}
+
+		return []Token{{Kind: Plain, Text: content}}
+	}
+
+	var (
+		tokens []Token
+		i      int
+	)
+
+	emit := func(kind Kind, end int) {
+		// Adjacent tokens of the same kind are merged
+		if n := len(tokens); n > 0 && tokens[n-1].Kind == kind {
+			tokens[n-1].Text += content[i:end]
+		} else {
+			tokens = append(tokens, Token{Kind: kind, Text: content[i:end]})
+		}
+
+		i = end
+	}
+
+	for i < len(content) {
+		rest := content[i:]
+		r, size := utf8.DecodeRuneInString(rest)
+
+		if start, end := syn.blockComment(rest); start != "" {
+			emit(Comment, i+len(start)+closeEnd(rest[len(start):], end))
+			continue
+		}
+
+		if quote := syn.quote(rest); quote != "" {
+			emit(String, i+len(quote)+syn.stringEnd(rest[len(quote):], quote))
+			continue
+		}
+
+		switch {
+		case syn.lineComment(rest):
+			emit(Comment, i+lineEnd(rest))
+		case unicode.IsDigit(r) || (r == '.' && len(rest) > 1 && isDigit(rest[1])):
+			emit(Number, i+wordEnd(rest, true))
+		case isWordStart(r) || (r == '$' && syn.variables):
+			end := i + size + wordEnd(rest[size:], false)
+			emit(syn.classify(content[i:end]), end)
+		case strings.ContainsRune(operatorChars, r):
+			emit(Operator, i+size)
+		default:
+			emit(Plain, i+size)
+		}
+	}
+
+	return tokens
+}
+
+// closeEnd returns the index just past the first end in s, or the length of s
+// if it doesn't contain end
+func closeEnd(s, end string) int {
+	if j := strings.Index(s, end); j >= 0 {
+		return j + len(end)
+	}
+
+	return len(s)
+}
+
+// lineEnd returns the index of the newline ending the first line of s, or
+// the length of s if there's none
+func lineEnd(s string) int {
+	if j := strings.IndexByte(s, '\n'); j >= 0 {
+		return j
+	}
+
+	return len(s)
+}
+
+// wordEnd returns the length of the word or number at the start of s
+func wordEnd(s string, number bool) int {
+	for j, r := range s {
+		if isWordPart(r) {
+			continue
+		}
+
+		// Decimal points and exponent signs are part of numbers
+		if number && (r == '.' || ((r == '+' || r == '-') && j > 0 && strings.ContainsRune("eEpP", rune(s[j-1])))) {
+			if r == '.' && (j+1 >= len(s) || !isDigit(s[j+1])) {
+				return j
+			}
+
+			continue
+		}
+
+		return j
+	}
+
+	return len(s)
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+func isWordStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isWordPart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
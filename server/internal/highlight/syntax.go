@@ -0,0 +1,233 @@
+package highlight
+
+import (
+	"strings"
+)
+
+// syntax describes what Tokenize needs to know about a language
+type syntax struct {
+	lineComments  []string
+	blockComments [][2]string
+	// quotes are the delimiters of strings, longest first. Strings with a
+	// single character delimiter other than a backtick end at a newline.
+	quotes []string
+	// escapes reports whether a backslash escapes the next character in
+	// strings
+	escapes bool
+	// variables reports whether identifiers may start with $
+	variables bool
+	// caseInsensitive reports whether keywords are matched ignoring case
+	caseInsensitive bool
+	keywords        map[string]bool
+	types           map[string]bool
+}
+
+// words returns the set of space-separated words in s
+func words(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(s) {
+		set[word] = true
+	}
+
+	return set
+}
+
+// blockComment returns the delimiters of the block comment rest starts with,
+// or empty strings if it doesn't start with one
+func (s *syntax) blockComment(rest string) (start, end string) {
+	for _, delims := range s.blockComments {
+		if strings.HasPrefix(rest, delims[0]) {
+			return delims[0], delims[1]
+		}
+	}
+
+	return "", ""
+}
+
+// lineComment reports whether rest starts with a line comment
+func (s *syntax) lineComment(rest string) bool {
+	for _, prefix := range s.lineComments {
+		if strings.HasPrefix(rest, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// quote returns the string delimiter rest starts with, or an empty string if
+// it doesn't start with one
+func (s *syntax) quote(rest string) string {
+	for _, quote := range s.quotes {
+		if strings.HasPrefix(rest, quote) {
+			return quote
+		}
+	}
+
+	return ""
+}
+
+// stringEnd returns the length of the rest of a string delimited by quote,
+// including its closing delimiter
+func (s *syntax) stringEnd(rest, quote string) int {
+	multiline := len(quote) > 1 || quote == "`"
+
+	for j := 0; j < len(rest); j++ {
+		switch {
+		case s.escapes && rest[j] == '\\' && quote != "`":
+			j++
+		case rest[j] == '\n' && !multiline:
+			return j
+		case strings.HasPrefix(rest[j:], quote):
+			return j + len(quote)
+		}
+	}
+
+	return len(rest)
+}
+
+// classify returns the type of the identifier word
+func (s *syntax) classify(word string) Kind {
+	if s.caseInsensitive {
+		word = strings.ToLower(word)
+	}
+
+	switch {
+	case s.keywords[word]:
+		return Keyword
+	case s.types[word]:
+		return Type
+	default:
+		return Plain
+	}
+}
+
+// Comments, strings and keywords shared by the syntaxes of similar languages
+var (
+	cComments      = [][2]string{{"/*", "*/"}}
+	cLineComments  = []string{"//"}
+	hashComments   = []string{"#"}
+	cQuotes        = []string{`"`, "'"}
+	scriptQuotes   = []string{`"`, "'", "`"}
+	pythonQuotes   = []string{`"""`, "'''", `"`, "'"}
+	cTypes         = "bool char double float int long short signed unsigned void size_t int8_t int16_t int32_t int64_t uint8_t uint16_t uint32_t uint64_t"
+	cKeywords      = "auto break case const continue default do else enum extern for goto if inline register restrict return sizeof static struct switch typedef union volatile while NULL true false"
+	jsKeywords     = "async await break case catch class const continue debugger default delete do else export extends false finally for from function if import in instanceof let new null of return super switch this throw true try typeof undefined var void while yield"
+	shellKeywords  = "if then else elif fi for while until do done case esac in function return local export readonly unset shift exit break continue select time"
+	sqlKeywords    = "select from where and or not insert into values update set delete create table alter drop index view join inner left right outer full cross on as group by order having limit offset union all distinct primary key foreign references null is in like between exists case when then else end begin commit rollback transaction default unique check constraint returning with if asc desc"
+	sqlTypes       = "int integer bigint smallint serial bigserial text varchar char boolean bool real double precision numeric decimal date time timestamp timestamptz interval json jsonb uuid blob bytea"
+	pythonKeywords = "and as assert async await break class continue def del elif else except False finally for from global if import in is lambda None nonlocal not or pass raise return True try while with yield match case self"
+)
+
+// syntaxes holds the syntax of every language Tokenize can tokenize, by
+// language ID
+var syntaxes = map[string]*syntax{
+	"c": {
+		lineComments: cLineComments, blockComments: cComments, quotes: cQuotes, escapes: true,
+		keywords: words(cKeywords + " #include #define #ifdef #ifndef #endif #if #else #pragma"),
+		types:    words(cTypes + " FILE"),
+	},
+	"cpp": {
+		lineComments: cLineComments, blockComments: cComments, quotes: cQuotes, escapes: true,
+		keywords: words(cKeywords + " class namespace template typename public private protected virtual override using new delete this throw try catch nullptr constexpr operator friend explicit mutable noexcept"),
+		types:    words(cTypes + " string vector map set auto std"),
+	},
+	"csharp": {
+		lineComments: cLineComments, blockComments: cComments, quotes: []string{`"`, "'"}, escapes: true,
+		keywords: words("abstract as async await base break case catch class const continue default delegate do else enum event explicit extern false finally fixed for foreach get goto if implicit in interface internal is lock namespace new null operator out override params private protected public readonly ref return sealed set sizeof stackalloc static struct switch this throw true try typeof unchecked unsafe using var virtual void volatile while yield"),
+		types:    words("bool byte char decimal double float int long object sbyte short string uint ulong ushort dynamic Task List Dictionary"),
+	},
+	"go": {
+		lineComments: cLineComments, blockComments: cComments, quotes: scriptQuotes, escapes: true,
+		keywords: words("break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var nil true false iota"),
+		types:    words("any bool byte comparable complex64 complex128 error float32 float64 int int8 int16 int32 int64 rune string uint uint8 uint16 uint32 uint64 uintptr"),
+	},
+	"java": {
+		lineComments: cLineComments, blockComments: cComments, quotes: []string{`"""`, `"`, "'"}, escapes: true,
+		keywords: words("abstract assert break case catch class const continue default do else enum extends final finally for goto if implements import instanceof interface native new package private protected public record return static strictfp super switch synchronized this throw throws transient try var void volatile while null true false"),
+		types:    words("boolean byte char double float int long short String Object Integer Long List Map"),
+	},
+	"javascript": {
+		lineComments: cLineComments, blockComments: cComments, quotes: scriptQuotes, escapes: true, variables: true,
+		keywords: words(jsKeywords),
+	},
+	"kotlin": {
+		lineComments: cLineComments, blockComments: cComments, quotes: []string{`"""`, `"`, "'"}, escapes: true,
+		keywords: words("as break class continue do else false for fun if in interface is null object package return super this throw true try typealias typeof val var when while by catch constructor data enum finally get import init internal open override private protected public sealed set suspend companion lateinit"),
+		types:    words("Any Boolean Byte Char Double Float Int Long Nothing Short String Unit List Map Set"),
+	},
+	"lua": {
+		lineComments: []string{"--"}, blockComments: [][2]string{{"--[[", "]]"}}, quotes: cQuotes, escapes: true,
+		keywords: words("and break do else elseif end false for function goto if in local nil not or repeat return then true until while"),
+	},
+	"php": {
+		lineComments: append([]string{"//"}, hashComments...), blockComments: cComments, quotes: cQuotes, escapes: true, variables: true,
+		keywords: words("abstract and array as break callable case catch class clone const continue declare default do echo else elseif empty enddeclare endfor endforeach endif endswitch endwhile extends final finally fn for foreach function global goto if implements include instanceof insteadof interface isset list match namespace new null or print private protected public readonly require return static switch throw trait true false try unset use var while yield"),
+		types:    words("int float string bool array object mixed void"),
+	},
+	"python": {
+		lineComments: hashComments, quotes: pythonQuotes, escapes: true,
+		keywords: words(pythonKeywords),
+		types:    words("int float str bool list dict set tuple bytes object type"),
+	},
+	"ruby": {
+		lineComments: hashComments, blockComments: [][2]string{{"=begin", "=end"}}, quotes: scriptQuotes, escapes: true,
+		keywords: words("alias and begin break case class def defined? do else elsif end ensure false for if in module next nil not or redo rescue retry return self super then true undef unless until when while yield require attr_accessor attr_reader attr_writer puts"),
+	},
+	"rust": {
+		lineComments: cLineComments, blockComments: cComments, quotes: []string{`"`}, escapes: true,
+		keywords: words("as async await break const continue crate dyn else enum extern false fn for if impl in let loop match mod move mut pub ref return self Self static struct super trait true type unsafe use where while"),
+		types:    words("bool char f32 f64 i8 i16 i32 i64 i128 isize str u8 u16 u32 u64 u128 usize String Vec Option Result Box"),
+	},
+	"shell": {
+		lineComments: hashComments, quotes: scriptQuotes, escapes: true, variables: true,
+		keywords: words(shellKeywords + " echo cd"),
+	},
+	"sql": {
+		lineComments: []string{"--"}, blockComments: cComments, quotes: []string{"'", `"`}, caseInsensitive: true,
+		keywords: words(sqlKeywords),
+		types:    words(sqlTypes),
+	},
+	"swift": {
+		lineComments: cLineComments, blockComments: cComments, quotes: []string{`"""`, `"`}, escapes: true,
+		keywords: words("associatedtype class deinit enum extension fileprivate func import init inout internal let open operator private protocol public rethrows static struct subscript typealias var break case continue default defer do else fallthrough for guard if in repeat return switch where while as catch false is nil self Self super throw throws true try async await"),
+		types:    words("Int Double Float Bool String Character Array Dictionary Set Optional Any Void"),
+	},
+	"typescript": {
+		lineComments: cLineComments, blockComments: cComments, quotes: scriptQuotes, escapes: true, variables: true,
+		keywords: words(jsKeywords + " abstract as declare enum implements interface keyof namespace private protected public readonly type"),
+		types:    words("any boolean never number object string symbol unknown void bigint"),
+	},
+	"css": {
+		blockComments: cComments, quotes: cQuotes, escapes: true,
+	},
+	"scss": {
+		lineComments: cLineComments, blockComments: cComments, quotes: cQuotes, escapes: true, variables: true,
+	},
+	"html": {
+		blockComments: [][2]string{{"<!--", "-->"}}, quotes: cQuotes,
+	},
+	"xml": {
+		blockComments: [][2]string{{"<!--", "-->"}}, quotes: cQuotes,
+	},
+	"json": {
+		quotes: []string{`"`}, escapes: true,
+		keywords: words("true false null"),
+	},
+	"yaml": {
+		lineComments: hashComments, quotes: cQuotes, escapes: true,
+		keywords: words("true false null yes no on off"),
+	},
+	"toml": {
+		lineComments: hashComments, quotes: []string{`"""`, "'''", `"`, "'"}, escapes: true,
+		keywords: words("true false"),
+	},
+	"dockerfile": {
+		lineComments: hashComments, quotes: cQuotes, escapes: true, caseInsensitive: true,
+		keywords: words("from run cmd label expose env add copy entrypoint volume user workdir arg onbuild stopsignal healthcheck shell as"),
+	},
+	"makefile": {
+		lineComments: hashComments, quotes: cQuotes, variables: true,
+		keywords: words("ifeq ifneq ifdef ifndef else endif include define endef export override"),
+	},
+}
@@ -0,0 +1,113 @@
+package highlight
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+// ErrUnknownTheme is returned when looking up a theme that doesn't exist
+var ErrUnknownTheme = errors.New("unknown theme")
+
+// DefaultTheme is the name of the theme used when none is asked for
+const DefaultTheme = "github"
+
+// Style is how tokens of a kind are drawn. Colors are hex RGB like #d73a49.
+type Style struct {
+	Color  string
+	Bold   bool
+	Italic bool
+}
+
+// Theme is a set of colors to highlight code with
+type Theme struct {
+	Name       string
+	Background string
+	Foreground string
+	Styles     map[Kind]Style
+}
+
+// style returns the style of tokens of kind, which is the foreground color
+// for kinds the theme doesn't style
+func (t *Theme) style(kind Kind) Style {
+	if style, ok := t.Styles[kind]; ok {
+		return style
+	}
+
+	return Style{Color: t.Foreground}
+}
+
+// themes holds the themes by name
+var themes = map[string]*Theme{
+	"github": {
+		Name: "github", Background: "#ffffff", Foreground: "#24292e",
+		Styles: map[Kind]Style{
+			Keyword:  {Color: "#d73a49", Bold: true},
+			Type:     {Color: "#6f42c1"},
+			String:   {Color: "#032f62"},
+			Number:   {Color: "#005cc5"},
+			Comment:  {Color: "#6a737d", Italic: true},
+			Operator: {Color: "#d73a49"},
+		},
+	},
+	"monokai": {
+		Name: "monokai", Background: "#272822", Foreground: "#f8f8f2",
+		Styles: map[Kind]Style{
+			Keyword:  {Color: "#f92672", Bold: true},
+			Type:     {Color: "#66d9ef", Italic: true},
+			String:   {Color: "#e6db74"},
+			Number:   {Color: "#ae81ff"},
+			Comment:  {Color: "#75715e", Italic: true},
+			Operator: {Color: "#f92672"},
+		},
+	},
+	"solarized-dark": {
+		Name: "solarized-dark", Background: "#002b36", Foreground: "#839496",
+		Styles: map[Kind]Style{
+			Keyword:  {Color: "#859900", Bold: true},
+			Type:     {Color: "#b58900"},
+			String:   {Color: "#2aa198"},
+			Number:   {Color: "#d33682"},
+			Comment:  {Color: "#586e75", Italic: true},
+			Operator: {Color: "#93a1a1"},
+		},
+	},
+	"solarized-light": {
+		Name: "solarized-light", Background: "#fdf6e3", Foreground: "#657b83",
+		Styles: map[Kind]Style{
+			Keyword:  {Color: "#859900", Bold: true},
+			Type:     {Color: "#b58900"},
+			String:   {Color: "#2aa198"},
+			Number:   {Color: "#d33682"},
+			Comment:  {Color: "#93a1a1", Italic: true},
+			Operator: {Color: "#586e75"},
+		},
+	},
+}
+
+// LookupTheme finds the theme called name, ignoring case. An empty name is
+// the default theme.
+func LookupTheme(name string) (*Theme, error) {
+	if name == "" {
+		name = DefaultTheme
+	}
+
+	theme, ok := themes[strings.ToLower(name)]
+	if !ok {
+		return nil, ErrUnknownTheme
+	}
+
+	return theme, nil
+}
+
+// ThemeNames returns the names of the themes in alphabetical order
+func ThemeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	return names
+}
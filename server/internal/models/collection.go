@@ -0,0 +1,79 @@
+package models
+
+import (
+	"slices"
+	"time"
+
+	"github.com/villaleo/cstash/internal/auth"
+)
+
+// Collection is a named, ordered list of snippets, like a playlist. A snippet
+// may be in any number of collections. The WorkspaceID of personal
+// collections is empty, and they're only visible to their owner.
+type Collection struct {
+	ID          string    `json:"id"`
+	OwnerID     string    `json:"ownerId"`
+	WorkspaceID string    `json:"workspaceId"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	SnippetIDs  []string  `json:"snippetIds"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// NewCollection creates a new empty collection with default values
+func NewCollection(name, description string) *Collection {
+	now := time.Now()
+	return &Collection{
+		ID:          auth.NewSecureID(),
+		Name:        name,
+		Description: description,
+		SnippetIDs:  []string{},
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// Contains reports whether the snippet with id is in the collection
+func (c *Collection) Contains(id string) bool {
+	return slices.Contains(c.SnippetIDs, id)
+}
+
+// Insert adds the snippet with id to the collection at position, or at the
+// end if position is out of range. The snippet must not already be in the
+// collection.
+func (c *Collection) Insert(id string, position int) {
+	if position < 0 || position > len(c.SnippetIDs) {
+		position = len(c.SnippetIDs)
+	}
+
+	c.SnippetIDs = slices.Insert(c.SnippetIDs, position, id)
+}
+
+// Remove removes the snippet with id from the collection, reporting whether
+// it was in it
+func (c *Collection) Remove(id string) bool {
+	i := slices.Index(c.SnippetIDs, id)
+	if i < 0 {
+		return false
+	}
+
+	c.SnippetIDs = slices.Delete(c.SnippetIDs, i, i+1)
+
+	return true
+}
+
+// Reorder puts the snippets of the collection in the order of ids, reporting
+// whether ids holds every snippet in the collection exactly once. The
+// collection is left as is if it doesn't.
+func (c *Collection) Reorder(ids []string) bool {
+	// The collection holds each snippet once, so ids does too if they sort
+	// the same
+	if !slices.Equal(slices.Sorted(slices.Values(ids)), slices.Sorted(slices.Values(c.SnippetIDs))) {
+		return false
+	}
+
+	c.SnippetIDs = slices.Clone(ids)
+
+	return true
+}
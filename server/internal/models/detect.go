@@ -0,0 +1,217 @@
+package models
+
+import (
+	"encoding/json"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	// maxDetectSize is how much of the start of a snippet's content is looked
+	// at to detect its language
+	maxDetectSize = 16 << 10
+	// minDetectScore is the score a language needs to be detected
+	minDetectScore = 3
+)
+
+// marker is a piece of syntax typical of a language. A language scores the
+// weight of every marker found in a snippet's content.
+type marker struct {
+	pattern *regexp.Regexp
+	weight  int
+}
+
+// markerPatterns holds the weights of the markers of the languages
+// DetectLanguage can detect, by language ID then pattern. Patterns are
+// matched line by line.
+var markerPatterns = map[string]map[string]int{
+	"c": {
+		`^#include\s*<\w+\.h>`: 3, `\bint main\s*\(`: 2, `\bprintf\s*\(`: 1, `\bmalloc\s*\(`: 2, `^\s*typedef struct\b`: 2,
+	},
+	"cpp": {
+		`^#include\s*<\w+>`: 3, `\bstd::`: 3, `\bcout\s*<<`: 2, `\btemplate\s*<`: 2, `\bnamespace \w+\s*\{`: 1,
+		`\bnullptr\b`: 2,
+	},
+	"csharp": {
+		`^using System(\.\w+)*;`: 4, `\bConsole\.Write(Line)?\(`: 3, `\bnamespace [\w.]+`: 1,
+		`\bpublic (static |async )*(void|Task|string|int) \w+\(`: 1, `\{\s*get;\s*(set;\s*)?\}`: 3,
+	},
+	"css": {
+		`^\s*[.#]?[\w-]+(\s*[,>+~]\s*[.#]?[\w-]+)*\s*\{\s*$`: 1, `^\s*[\w-]+\s*:\s*[^;{]+;\s*$`: 2, `@media\b`: 3,
+		`^\s*(color|margin|padding|display|font-size)\s*:`: 2,
+	},
+	"dockerfile": {
+		`^FROM \S+`: 3, `^(RUN|CMD|ENTRYPOINT|COPY|ADD|WORKDIR|EXPOSE|ENV) `: 2,
+	},
+	"go": {
+		`^package \w+\s*$`: 3, `\bfunc (\(\w+ \*?\w+\) )?\w+\(`: 2, `:=`: 1, `^import \($`: 2, `\bfmt\.\w+\(`: 2,
+		`\berr != nil\b`: 3, `\bchan \w+`: 1, `^func \w+\(.*\) \(?[\w*\[\], ]+\)? \{$`: 1,
+	},
+	"haskell": {
+		`^module [\w.]+ (\(.*\) )?where`: 4, `^\w+ :: `: 3, `^import qualified `: 3, `\bwhere$`: 1,
+	},
+	"html": {
+		`(?i)<!doctype html`: 5, `(?i)</?(html|head|body|div|span|script|ul|li)\b[^>]*>`: 2,
+	},
+	"java": {
+		`\bpublic (static )?(final )?(class|interface|enum) \w+`: 3, `\bSystem\.out\.print`: 3, `^import java\.`: 3,
+		`@Override\b`: 2, `\bpublic static void main\(`: 2,
+	},
+	"javascript": {
+		`\bconst \w+ = `: 1, `=>`: 1, `\bfunction\s*\w*\s*\(`: 2, `\bconsole\.log\(`: 2, `\brequire\(['"]`: 2,
+		`\bmodule\.exports\b`: 3, `\b(document|window)\.\w+`: 2,
+	},
+	"kotlin": {
+		`\bfun \w+\(`: 3, `\bval \w+(: \w+)? =`: 2, `\bprintln\(`: 1, `^import kotlin\.`: 3,
+	},
+	"lua": {
+		`\blocal \w+ = `: 2, `\bfunction \w+(\.\w+)*\(`: 1, `^\s*end$`: 1, `\bthen$`: 1, `\brequire\s*\(?["']`: 1,
+	},
+	"makefile": {
+		`^\.PHONY:`: 4, `^[\w./-]+:( [\w./-]+)*$\n\t`: 3, `\$\(\w+\)`: 1,
+	},
+	"markdown": {
+		`^#{1,6} \S`: 1, `\[[^\]]+\]\([^)]+\)`: 3, "^```": 3, `^\s*[-*] \S`: 1,
+	},
+	"php": {
+		`<\?php`: 6, `\$\w+\s*=`: 1, `\becho\b`: 1,
+	},
+	"python": {
+		`^\s*def \w+\(.*\):`: 3, `^\s*class \w+(\(.*\))?:`: 2, `\bself\b`: 1, `\belif\b`: 2,
+		`^\s*(from [\w.]+ )?import \w+`: 1, `\bprint\(`: 1, `^if __name__ == `: 4,
+	},
+	"ruby": {
+		`^\s*def \w+[?!]?(\(.*\))?\s*$`: 2, `^\s*end$`: 2, `\bputs\b`: 2, `^require ['"]`: 2, `\.each do\b`: 3,
+		`\battr_(accessor|reader|writer)\b`: 3,
+	},
+	"rust": {
+		`\bfn \w+(<.*>)?\(`: 2, `\blet mut\b`: 3, `^\s*impl\b`: 2, `\bprintln!\(`: 3, `\bpub (fn|struct|enum)\b`: 2,
+		`^use \w+(::\w+)+`: 2,
+	},
+	"scala": {
+		`\bobject \w+`: 2, `\bdef \w+(\[.*\])?\(.*\)\s*:`: 2, `\bcase class\b`: 3, `^import scala\.`: 3,
+	},
+	"shell": {
+		`^\s*(if \[|then$|fi$|done$|esac$)`: 2, `^\s*(for|while) .*; do$`: 2, `\becho\b`: 1, `^\s*export \w+=`: 2,
+		`\$\(`: 1, `^\s*\w+=\S`: 1,
+	},
+	"sql": {
+		`(?i)\bselect\b.+\bfrom\b`: 3, `(?i)\b(insert into|create table|alter table|delete from)\b`: 3,
+		`(?i)^\s*update \w+ set\b`: 3, `(?i)\bwhere\b`: 1,
+	},
+	"swift": {
+		`^import (UIKit|Foundation|SwiftUI)$`: 4, `\bguard let\b`: 3, `\bfunc \w+\(.*\)\s*(->|\{)`: 1, `\bvar \w+: \w+`: 1,
+	},
+	"terraform": {
+		`^(resource|provider|variable|module|output|data) "`: 4,
+	},
+	"toml": {
+		`^\[[\w.-]+\]\s*$`: 2, `^[\w-]+\s*=\s*("|\d|true|false|\[)`: 1,
+	},
+	"typescript": {
+		`:\s*(string|number|boolean|any|void|unknown)\b`: 3, `\binterface \w+\s*\{`: 2, `\btype \w+ = `: 2,
+		`\bimport .* from ['"]`: 1, `\bconst \w+(: \w+)? = `: 1, `=>`: 1,
+	},
+	"xml": {
+		`^<\?xml`: 6,
+	},
+	"yaml": {
+		`^[\w-]+:(\s|$)`: 1, `^---\s*$`: 2, `^\s*- [\w-]+:`: 2,
+	},
+}
+
+// languageMarkers holds the compiled markerPatterns
+var languageMarkers = func() map[string][]marker {
+	compiled := make(map[string][]marker, len(markerPatterns))
+
+	for id, patterns := range markerPatterns {
+		for pattern, weight := range patterns {
+			compiled[id] = append(compiled[id], marker{pattern: regexp.MustCompile("(?m)" + pattern), weight: weight})
+		}
+	}
+
+	return compiled
+}()
+
+// DetectLanguage guesses the ID of the language content is written in. A
+// shebang line names its interpreter; otherwise languages are scored by the
+// syntax markers found in content, and the highest scoring language is
+// chosen. An empty string is returned if no language stands out.
+func DetectLanguage(content string) string {
+	if len(content) > maxDetectSize {
+		content = content[:maxDetectSize]
+	}
+
+	if strings.TrimSpace(content) == "" {
+		return ""
+	}
+
+	if id := detectShebang(content); id != "" {
+		return id
+	}
+
+	// Markers can't tell JSON from other data, but parsing it can
+	if trimmed := strings.TrimSpace(content); strings.ContainsAny(trimmed[:1], "{[") && json.Valid([]byte(trimmed)) {
+		return "json"
+	}
+
+	var (
+		best      string
+		bestScore = minDetectScore - 1
+	)
+
+	// Languages are scored in registry order so that ties are broken the same
+	// way every time
+	for _, language := range Languages {
+		score := 0
+
+		for _, m := range languageMarkers[language.ID] {
+			if m.pattern.MatchString(content) {
+				score += m.weight
+			}
+		}
+
+		if score > bestScore {
+			best, bestScore = language.ID, score
+		}
+	}
+
+	return best
+}
+
+// detectShebang returns the ID of the language of the interpreter named by a
+// shebang line at the start of content, or an empty string if there's none
+func detectShebang(content string) string {
+	line, _, _ := strings.Cut(content, "\n")
+	if !strings.HasPrefix(line, "#!") {
+		return ""
+	}
+
+	fields := strings.Fields(line[2:])
+	if len(fields) == 0 {
+		return ""
+	}
+
+	interpreter := path.Base(fields[0])
+	if interpreter == "env" {
+		// Skip options like env -S
+		interpreter = ""
+
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") {
+				interpreter = path.Base(field)
+				break
+			}
+		}
+	}
+
+	// Versioned interpreters like python3.12 are known by their name
+	interpreter = strings.TrimRight(interpreter, "0123456789.")
+
+	if language, ok := LookupLanguage(interpreter); ok {
+		return language.ID
+	}
+
+	return ""
+}
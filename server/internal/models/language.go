@@ -0,0 +1,113 @@
+package models
+
+import (
+	"strings"
+)
+
+// Language is a language snippets can be written in. Snippets refer to it by
+// its canonical ID; its name, aliases and file extensions are other names it
+// is known by.
+type Language struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Aliases    []string `json:"aliases"`
+	Extensions []string `json:"extensions"`
+}
+
+// Languages is the registry of known languages, ordered by ID
+var Languages = []Language{
+	{ID: "c", Name: "C", Aliases: []string{}, Extensions: []string{".c", ".h"}},
+	{ID: "clojure", Name: "Clojure", Aliases: []string{"clj"}, Extensions: []string{".clj", ".cljs", ".edn"}},
+	{ID: "cpp", Name: "C++", Aliases: []string{"c++", "cplusplus"}, Extensions: []string{".cpp", ".cc", ".cxx", ".hpp", ".hh"}},
+	{ID: "csharp", Name: "C#", Aliases: []string{"c#", "cs", "dotnet"}, Extensions: []string{".cs"}},
+	{ID: "css", Name: "CSS", Aliases: []string{}, Extensions: []string{".css"}},
+	{ID: "dart", Name: "Dart", Aliases: []string{}, Extensions: []string{".dart"}},
+	{ID: "dockerfile", Name: "Dockerfile", Aliases: []string{"docker", "containerfile"}, Extensions: []string{".dockerfile"}},
+	{ID: "elixir", Name: "Elixir", Aliases: []string{"ex"}, Extensions: []string{".ex", ".exs"}},
+	{ID: "erlang", Name: "Erlang", Aliases: []string{"erl"}, Extensions: []string{".erl", ".hrl"}},
+	{ID: "go", Name: "Go", Aliases: []string{"golang"}, Extensions: []string{".go"}},
+	{ID: "graphql", Name: "GraphQL", Aliases: []string{"gql"}, Extensions: []string{".graphql", ".gql"}},
+	{ID: "haskell", Name: "Haskell", Aliases: []string{"hs"}, Extensions: []string{".hs", ".lhs"}},
+	{ID: "html", Name: "HTML", Aliases: []string{"xhtml"}, Extensions: []string{".html", ".htm"}},
+	{ID: "java", Name: "Java", Aliases: []string{}, Extensions: []string{".java"}},
+	{ID: "javascript", Name: "JavaScript", Aliases: []string{"js", "node", "nodejs", "ecmascript"}, Extensions: []string{".js", ".mjs", ".cjs", ".jsx"}},
+	{ID: "json", Name: "JSON", Aliases: []string{}, Extensions: []string{".json"}},
+	{ID: "julia", Name: "Julia", Aliases: []string{"jl"}, Extensions: []string{".jl"}},
+	{ID: "kotlin", Name: "Kotlin", Aliases: []string{"kt"}, Extensions: []string{".kt", ".kts"}},
+	{ID: "lua", Name: "Lua", Aliases: []string{}, Extensions: []string{".lua"}},
+	{ID: "makefile", Name: "Makefile", Aliases: []string{"make", "mk"}, Extensions: []string{".mk", ".mak"}},
+	{ID: "markdown", Name: "Markdown", Aliases: []string{"md"}, Extensions: []string{".md", ".markdown"}},
+	{ID: "nix", Name: "Nix", Aliases: []string{}, Extensions: []string{".nix"}},
+	{ID: "objectivec", Name: "Objective-C", Aliases: []string{"objective-c", "objc"}, Extensions: []string{".m", ".mm"}},
+	{ID: "ocaml", Name: "OCaml", Aliases: []string{"ml"}, Extensions: []string{".ml", ".mli"}},
+	{ID: "perl", Name: "Perl", Aliases: []string{"pl"}, Extensions: []string{".pl", ".pm"}},
+	{ID: "php", Name: "PHP", Aliases: []string{}, Extensions: []string{".php"}},
+	{ID: "plaintext", Name: "Plain text", Aliases: []string{"text", "txt", "plain"}, Extensions: []string{".txt"}},
+	{ID: "powershell", Name: "PowerShell", Aliases: []string{"pwsh", "ps1"}, Extensions: []string{".ps1", ".psm1"}},
+	{ID: "python", Name: "Python", Aliases: []string{"py", "python3", "python2"}, Extensions: []string{".py", ".pyw"}},
+	{ID: "r", Name: "R", Aliases: []string{"rlang"}, Extensions: []string{".r"}},
+	{ID: "ruby", Name: "Ruby", Aliases: []string{"rb"}, Extensions: []string{".rb"}},
+	{ID: "rust", Name: "Rust", Aliases: []string{"rs"}, Extensions: []string{".rs"}},
+	{ID: "scala", Name: "Scala", Aliases: []string{}, Extensions: []string{".scala", ".sc"}},
+	{ID: "scss", Name: "SCSS", Aliases: []string{"sass"}, Extensions: []string{".scss", ".sass"}},
+	{ID: "shell", Name: "Shell", Aliases: []string{"sh", "bash", "zsh", "ksh", "shellscript"}, Extensions: []string{".sh", ".bash", ".zsh"}},
+	{ID: "sql", Name: "SQL", Aliases: []string{"postgresql", "mysql", "sqlite", "plsql"}, Extensions: []string{".sql"}},
+	{ID: "swift", Name: "Swift", Aliases: []string{}, Extensions: []string{".swift"}},
+	{ID: "terraform", Name: "Terraform", Aliases: []string{"hcl", "tf"}, Extensions: []string{".tf", ".hcl"}},
+	{ID: "toml", Name: "TOML", Aliases: []string{}, Extensions: []string{".toml"}},
+	{ID: "typescript", Name: "TypeScript", Aliases: []string{"ts"}, Extensions: []string{".ts", ".tsx", ".mts", ".cts"}},
+	{ID: "xml", Name: "XML", Aliases: []string{}, Extensions: []string{".xml", ".xsd", ".svg"}},
+	{ID: "yaml", Name: "YAML", Aliases: []string{"yml"}, Extensions: []string{".yaml", ".yml"}},
+	{ID: "zig", Name: "Zig", Aliases: []string{}, Extensions: []string{".zig"}},
+}
+
+// languageNames maps every lowercased ID, name, alias and extension in
+// Languages to the index of its language
+var languageNames = func() map[string]int {
+	names := make(map[string]int)
+
+	for i, language := range Languages {
+		for _, name := range append([]string{language.ID, language.Name}, language.Aliases...) {
+			names[strings.ToLower(name)] = i
+		}
+
+		for _, ext := range language.Extensions {
+			names[ext] = i
+		}
+	}
+
+	return names
+}()
+
+// LookupLanguage finds the language known by name, which may be its ID, name,
+// an alias or a file extension, ignoring case and surrounding space. File
+// extensions may be given without their leading dot if they aren't the name
+// of another language.
+func LookupLanguage(name string) (Language, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	i, ok := languageNames[name]
+	if !ok && name != "" && !strings.HasPrefix(name, ".") {
+		i, ok = languageNames["."+name]
+	}
+
+	if !ok {
+		return Language{}, false
+	}
+
+	return Languages[i], true
+}
+
+// NormalizeLanguage sets the language of the snippet to the ID of the
+// language it names, or detects it from the content if it's empty. Languages
+// that aren't known are left as they are for Validate to report.
+func (s *Snippet) NormalizeLanguage() {
+	if strings.TrimSpace(s.Language) == "" {
+		s.Language = DetectLanguage(s.Content)
+		return
+	}
+
+	if language, ok := LookupLanguage(s.Language); ok {
+		s.Language = language.ID
+	}
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// Revision is an immutable record of every field of a snippet as it was
+// after it was created or changed. The revisions of a snippet are numbered
+// from 1 in the order they were made.
+type Revision struct {
+	SnippetID   string     `json:"snippetId"`
+	Number      int        `json:"number"`
+	AuthorID    string     `json:"authorId"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	Visibility  Visibility `json:"visibility"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Content     string     `json:"content"`
+	Language    string     `json:"language"`
+	Tags        []string   `json:"tags"`
+	IsFavorite  bool       `json:"isFavorite"`
+}
+
+// NewRevision records the fields of snippet as its revision number, made by
+// the user with authorID when snippet was last updated
+func NewRevision(snippet *Snippet, number int, authorID string) *Revision {
+	return &Revision{
+		SnippetID:   snippet.ID,
+		Number:      number,
+		AuthorID:    authorID,
+		CreatedAt:   snippet.UpdatedAt,
+		Visibility:  snippet.Visibility,
+		Title:       snippet.Title,
+		Description: snippet.Description,
+		Content:     snippet.Content,
+		Language:    snippet.Language,
+		Tags:        append([]string{}, snippet.Tags...),
+		IsFavorite:  snippet.IsFavorite,
+	}
+}
+
+// Restore sets the fields of snippet to those recorded by the revision
+func (r *Revision) Restore(snippet *Snippet) {
+	snippet.Visibility = r.Visibility
+	snippet.Title = r.Title
+	snippet.Description = r.Description
+	snippet.Content = r.Content
+	snippet.Language = r.Language
+	snippet.Tags = slices.Clone(r.Tags)
+	snippet.IsFavorite = r.IsFavorite
+}
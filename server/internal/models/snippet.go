@@ -7,17 +7,46 @@ import (
 	"github.com/villaleo/cstash/internal/auth"
 )
 
-// Snippet represents a code snippet with metadata
+// Visibility controls who besides its owner may view a snippet
+type Visibility string
+
+const (
+	// VisibilityPrivate snippets are only visible to their owner
+	VisibilityPrivate Visibility = "private"
+	// VisibilityTeam snippets are visible to every signed-in user
+	VisibilityTeam Visibility = "team"
+	// VisibilityPublic snippets are visible to everyone, including callers who
+	// aren't signed in
+	VisibilityPublic Visibility = "public"
+)
+
+// Valid reports whether v is a known visibility level
+func (v Visibility) Valid() bool {
+	switch v {
+	case VisibilityPrivate, VisibilityTeam, VisibilityPublic:
+		return true
+	default:
+		return false
+	}
+}
+
+// Snippet represents a code snippet with metadata. The WorkspaceID of
+// personal snippets is empty. Version starts at 1 and is incremented by every
+// change.
 type Snippet struct {
-	ID          string    `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Content     string    `json:"content"`
-	Language    string    `json:"language"`
-	Tags        []string  `json:"tags"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
-	IsFavorite  bool      `json:"isFavorite"`
+	ID          string     `json:"id"`
+	OwnerID     string     `json:"ownerId"`
+	WorkspaceID string     `json:"workspaceId"`
+	Visibility  Visibility `json:"visibility"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Content     string     `json:"content"`
+	Language    string     `json:"language"`
+	Tags        []string   `json:"tags"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	IsFavorite  bool       `json:"isFavorite"`
+	Version     int64      `json:"version"`
 }
 
 // NewSnippet creates a new snippet with default values
@@ -25,6 +54,7 @@ func NewSnippet(title, content, language string) *Snippet {
 	now := time.Now()
 	return &Snippet{
 		ID:         auth.NewSecureID(),
+		Visibility: VisibilityPrivate,
 		Title:      title,
 		Content:    content,
 		Language:   language,
@@ -32,13 +62,16 @@ func NewSnippet(title, content, language string) *Snippet {
 		CreatedAt:  now,
 		UpdatedAt:  now,
 		IsFavorite: false,
+		Version:    1,
 	}
 }
 
-// AddTags adds tags to the snippet. Duplicate tags are not allowed
+// AddTags adds tags to the snippet, normalized with NormalizeTag. Duplicate
+// and empty tags are not allowed
 func (s *Snippet) AddTags(tags ...string) {
 	for _, tag := range tags {
-		if slices.Contains(s.Tags, tag) {
+		tag = NormalizeTag(tag)
+		if tag == "" || slices.Contains(s.Tags, tag) {
 			continue
 		}
 
@@ -47,6 +80,19 @@ func (s *Snippet) AddTags(tags ...string) {
 	}
 }
 
+// NormalizeTags normalizes the tags of the snippet with NormalizeTag,
+// dropping the empty tags and the duplicates it makes
+func (s *Snippet) NormalizeTags() {
+	tags := s.Tags
+	s.Tags = make([]string, 0, len(tags))
+
+	for _, tag := range tags {
+		if tag = NormalizeTag(tag); tag != "" && !slices.Contains(s.Tags, tag) {
+			s.Tags = append(s.Tags, tag)
+		}
+	}
+}
+
 // RemoveTags removes tags from the snippet.
 func (s *Snippet) RemoveTags(tags ...string) {
 	deleteFn := func(tag string) bool {
@@ -67,3 +113,33 @@ func (s *Snippet) ToggleFavorite() {
 	s.IsFavorite = !s.IsFavorite
 	s.UpdatedAt = time.Now()
 }
+
+// VisibleTo reports whether the user with userID may view the snippet. An
+// empty userID denotes an anonymous caller, who may only view public snippets.
+//
+// Snippets without an owner predate ownership and are visible to every
+// signed-in user. Access to snippets in a workspace is granted by membership
+// instead.
+func (s *Snippet) VisibleTo(userID string) bool {
+	switch {
+	case s.Visibility == VisibilityPublic:
+		return true
+	case userID == "":
+		return false
+	case s.OwnerID == "" || s.OwnerID == userID:
+		return true
+	default:
+		return s.Visibility == VisibilityTeam
+	}
+}
+
+// EditableBy reports whether the user with userID may change or delete the
+// snippet. Only the owner may do so, unless the snippet has no owner, in
+// which case any signed-in user may.
+func (s *Snippet) EditableBy(userID string) bool {
+	if s.OwnerID == "" {
+		return userID != ""
+	}
+
+	return s.OwnerID == userID
+}
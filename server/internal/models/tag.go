@@ -0,0 +1,65 @@
+package models
+
+import (
+	"strings"
+)
+
+// TagSeparator separates the levels of hierarchical tags like infra/k8s/helm.
+// A tag is below every tag its name starts with followed by the separator.
+const TagSeparator = "/"
+
+// Tag is a tag of the snippets in a workspace, along with the description and
+// color its users gave it. Count is the number of snippets the caller may
+// view that have the tag.
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Color       string `json:"color"`
+	Count       int    `json:"count"`
+}
+
+// TagNode is a tag in the hierarchy of tags. Label is the last level of its
+// name, and Total the number of snippets the caller may view that have the
+// tag or any tag below it. Tags that only exist as the parent of other tags
+// have a Count of 0.
+type TagNode struct {
+	Tag
+	Label    string     `json:"label"`
+	Total    int        `json:"total"`
+	Children []*TagNode `json:"children"`
+}
+
+// NormalizeTag returns tag lowercased, with the space around each level
+// trimmed and the space within it replaced by dashes, so that "K8s " and
+// "k8s" are the same tag. Empty levels are dropped.
+func NormalizeTag(tag string) string {
+	var levels []string
+
+	for _, level := range strings.Split(strings.ToLower(tag), TagSeparator) {
+		if level := strings.Join(strings.Fields(level), "-"); level != "" {
+			levels = append(levels, level)
+		}
+	}
+
+	return strings.Join(levels, TagSeparator)
+}
+
+// TagMatches reports whether tag is the tag called name or below it, ignoring
+// case, so that lang/go matches lang
+func TagMatches(tag, name string) bool {
+	tag, name = strings.ToLower(tag), strings.ToLower(name)
+
+	return tag == name || strings.HasPrefix(tag, name+TagSeparator)
+}
+
+// TagParents returns the names of every tag above tag, nearest first
+func TagParents(tag string) []string {
+	var parents []string
+
+	for i := strings.LastIndex(tag, TagSeparator); i > 0; i = strings.LastIndex(tag, TagSeparator) {
+		tag = tag[:i]
+		parents = append(parents, tag)
+	}
+
+	return parents
+}
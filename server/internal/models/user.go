@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"github.com/villaleo/cstash/internal/auth"
+)
+
+// User represents a registered cstash account
+type User struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	// PasswordHash is empty for users who sign in with single sign-on
+	PasswordHash string `json:"-"`
+	// ExternalID identifies the user at their single sign-on provider, if any
+	ExternalID string    `json:"-"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// Session represents a signed-in user. Only a hash of the session token is
+// stored, so a leaked store can't be used to impersonate users.
+type Session struct {
+	TokenHash string    `json:"-"`
+	UserID    string    `json:"userId"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// NewUser creates a new user with default values
+func NewUser(username, passwordHash string) *User {
+	return &User{
+		ID:           auth.NewSecureID(),
+		Username:     username,
+		PasswordHash: passwordHash,
+		CreatedAt:    time.Now(),
+	}
+}
+
+// Expired reports whether the session is no longer valid
+func (s *Session) Expired() bool {
+	return time.Now().After(s.ExpiresAt)
+}
+
+// AccessToken represents a named personal access token used for scripting
+// against the API. Only a hash of the token is stored.
+type AccessToken struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	TokenHash string    `json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,213 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits on the fields of the models checked by Validate
+const (
+	MaxTitleLength       = 200
+	MaxDescriptionLength = 2000
+	MaxContentSize       = 256 << 10
+	MaxTags              = 20
+	MaxTagLength         = 100
+
+	MaxTagDescriptionLength = 200
+
+	MaxCollectionNameLength = 100
+	MaxCollectionSnippets   = 500
+)
+
+// The codes of the problems a FieldError can report
+const (
+	CodeRequired        = "required"
+	CodeTooLong         = "too_long"
+	CodeTooMany         = "too_many"
+	CodeInvalidType     = "invalid_type"
+	CodeInvalidValue    = "invalid_value"
+	CodeInvalidFormat   = "invalid_format"
+	CodeDuplicate       = "duplicate"
+	CodeUnknownField    = "unknown_field"
+	CodeUnknownLanguage = "unknown_language"
+	CodeReadOnly        = "read_only"
+)
+
+// tagPattern matches a well-formed tag: levels separated by TagSeparator,
+// made of letters, digits and a few symbols used in the names of languages
+// and tools, like c++ or c#
+var tagPattern = regexp.MustCompile(`^[\pL\pN][\pL\pN._+#-]*(/[\pL\pN][\pL\pN._+#-]*)*$`)
+
+// colorPattern matches a hex RGB color like #d73a49
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// FieldError is a problem with a field of a model. Field is a JSON Pointer to
+// the field, which is empty for the whole model, and Code identifies the kind
+// of problem.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every problem found with a model
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	problems := make([]string, len(e))
+	for i, fe := range e {
+		if fe.Field == "" {
+			problems[i] = fe.Message
+		} else {
+			problems[i] = fe.Field + ": " + fe.Message
+		}
+	}
+
+	return "invalid request: " + strings.Join(problems, "; ")
+}
+
+// Validate checks the fields of the snippet that its owner sets, returning a
+// ValidationError listing every problem found. The language may be any name
+// of a language in Languages, or empty for snippets whose language isn't
+// known.
+func (s *Snippet) Validate() error {
+	var errs ValidationError
+
+	invalid := func(field, code, message string) {
+		errs = append(errs, FieldError{Field: field, Code: code, Message: message})
+	}
+
+	if !s.Visibility.Valid() {
+		invalid("/visibility", CodeInvalidValue, "must be one of private, team or public")
+	}
+
+	switch {
+	case strings.TrimSpace(s.Title) == "":
+		invalid("/title", CodeRequired, "is required")
+	case utf8.RuneCountInString(s.Title) > MaxTitleLength:
+		invalid("/title", CodeTooLong, fmt.Sprintf("must be at most %d characters", MaxTitleLength))
+	}
+
+	if utf8.RuneCountInString(s.Description) > MaxDescriptionLength {
+		invalid("/description", CodeTooLong, fmt.Sprintf("must be at most %d characters", MaxDescriptionLength))
+	}
+
+	if len(s.Content) > MaxContentSize {
+		invalid("/content", CodeTooLong, fmt.Sprintf("must be at most %d bytes", MaxContentSize))
+	}
+
+	if _, ok := LookupLanguage(s.Language); s.Language != "" && !ok {
+		invalid("/language", CodeUnknownLanguage, fmt.Sprintf("%q is not a known language", s.Language))
+	}
+
+	if len(s.Tags) > MaxTags {
+		invalid("/tags", CodeTooMany, fmt.Sprintf("must have at most %d tags", MaxTags))
+	}
+
+	for i, tag := range s.Tags {
+		field := fmt.Sprintf("/tags/%d", i)
+
+		if fe := checkTagName(field, tag); fe != nil {
+			errs = append(errs, *fe)
+		} else if slices.Contains(s.Tags[:i], tag) {
+			invalid(field, CodeDuplicate, fmt.Sprintf("%q is already a tag", tag))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// Validate checks the name, description and color of the tag, returning a
+// ValidationError listing every problem found. The color may be empty.
+func (t *Tag) Validate() error {
+	var errs ValidationError
+
+	if fe := checkTagName("/name", t.Name); fe != nil {
+		errs = append(errs, *fe)
+	}
+
+	if utf8.RuneCountInString(t.Description) > MaxTagDescriptionLength {
+		errs = append(errs, FieldError{
+			Field:   "/description",
+			Code:    CodeTooLong,
+			Message: fmt.Sprintf("must be at most %d characters", MaxTagDescriptionLength),
+		})
+	}
+
+	if t.Color != "" && !colorPattern.MatchString(t.Color) {
+		errs = append(errs, FieldError{Field: "/color", Code: CodeInvalidFormat, Message: "must be a hex color like #d73a49"})
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// Validate checks the fields of the collection that its owner sets, returning
+// a ValidationError listing every problem found
+func (c *Collection) Validate() error {
+	var errs ValidationError
+
+	invalid := func(field, code, message string) {
+		errs = append(errs, FieldError{Field: field, Code: code, Message: message})
+	}
+
+	switch {
+	case strings.TrimSpace(c.Name) == "":
+		invalid("/name", CodeRequired, "is required")
+	case utf8.RuneCountInString(c.Name) > MaxCollectionNameLength:
+		invalid("/name", CodeTooLong, fmt.Sprintf("must be at most %d characters", MaxCollectionNameLength))
+	}
+
+	if utf8.RuneCountInString(c.Description) > MaxDescriptionLength {
+		invalid("/description", CodeTooLong, fmt.Sprintf("must be at most %d characters", MaxDescriptionLength))
+	}
+
+	if len(c.SnippetIDs) > MaxCollectionSnippets {
+		invalid("/snippetIds", CodeTooMany, fmt.Sprintf("must have at most %d snippets", MaxCollectionSnippets))
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// ValidateTagName checks that name is a well-formed tag, returning a
+// ValidationError for the field if it isn't
+func ValidateTagName(field, name string) error {
+	if fe := checkTagName(field, name); fe != nil {
+		return ValidationError{*fe}
+	}
+
+	return nil
+}
+
+// checkTagName returns the problem with the tag name in field, or nil if
+// there's none
+func checkTagName(field, name string) *FieldError {
+	switch {
+	case name == "":
+		return &FieldError{Field: field, Code: CodeRequired, Message: "is required"}
+	case utf8.RuneCountInString(name) > MaxTagLength:
+		return &FieldError{Field: field, Code: CodeTooLong, Message: fmt.Sprintf("must be at most %d characters", MaxTagLength)}
+	case !tagPattern.MatchString(name):
+		return &FieldError{
+			Field:   field,
+			Code:    CodeInvalidFormat,
+			Message: "must be levels separated by /, each starting with a letter or digit and only containing letters, digits and . _ + # -",
+		}
+	default:
+		return nil
+	}
+}
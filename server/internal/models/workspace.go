@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"github.com/villaleo/cstash/internal/auth"
+)
+
+// Role controls what a member may do in a workspace
+type Role string
+
+const (
+	// RoleOwner members may manage the workspace and its members, and edit its
+	// snippets
+	RoleOwner Role = "owner"
+	// RoleEditor members may create, change and delete the workspace's
+	// snippets
+	RoleEditor Role = "editor"
+	// RoleViewer members may only view the workspace's snippets
+	RoleViewer Role = "viewer"
+)
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	switch r {
+	case RoleOwner, RoleEditor, RoleViewer:
+		return true
+	default:
+		return false
+	}
+}
+
+// CanEdit reports whether members with role r may change the workspace's
+// snippets
+func (r Role) CanEdit() bool {
+	return r == RoleOwner || r == RoleEditor
+}
+
+// CanManage reports whether members with role r may change the workspace
+// itself and its members
+func (r Role) CanManage() bool {
+	return r == RoleOwner
+}
+
+// Workspace is shared by several users, its members. Snippets and tags in a
+// workspace are separate from those of its members.
+type Workspace struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Membership grants a user a role in a workspace
+type Membership struct {
+	WorkspaceID string    `json:"workspaceId"`
+	UserID      string    `json:"userId"`
+	Role        Role      `json:"role"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// NewWorkspace creates a new workspace with default values
+func NewWorkspace(name string) *Workspace {
+	return &Workspace{
+		ID:        auth.NewSecureID(),
+		Name:      name,
+		CreatedAt: time.Now(),
+	}
+}
@@ -0,0 +1,419 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidPatch is returned for a JSON Patch document that isn't an
+	// array of well-formed operations
+	ErrInvalidPatch = errors.New("invalid patch")
+	// ErrPathNotFound is returned for an operation on a location the document
+	// doesn't have
+	ErrPathNotFound = errors.New("path not found")
+	// ErrTestFailed is returned for a test operation whose value differs from
+	// the document's
+	ErrTestFailed = errors.New("test failed")
+)
+
+// pointerUnescaper unescapes the reference tokens of a JSON Pointer
+var pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// Operation is a single operation of a JSON Patch document. Value is only
+// used by add, replace and test, and From by move and copy.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// JSONPatch is a parsed JSON Patch document
+type JSONPatch []Operation
+
+// ParseJSONPatch parses a JSON Patch document, checking that every operation
+// is known, has the members it needs and has none of them twice
+func ParseJSONPatch(data []byte) (JSONPatch, error) {
+	var ops JSONPatch
+	if err := json.Unmarshal(data, &ops); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+	}
+
+	if err := checkDuplicateMembers(data); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+	}
+
+	for i, op := range ops {
+		if err := op.check(); err != nil {
+			return nil, fmt.Errorf("%w: operation %d: %w", ErrInvalidPatch, i, err)
+		}
+	}
+
+	return ops, nil
+}
+
+// checkDuplicateMembers reports whether an operation of data, a JSON array of
+// operations, has a member twice. Decoding keeps the last of them, so an
+// operation like {"op": "add", ..., "op": "remove"} would otherwise be
+// applied as a remove.
+func checkDuplicateMembers(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	// data was already decoded as an array, so only its elements are checked
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+
+	for i := 0; dec.More(); i++ {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		if tok != json.Delim('{') {
+			continue
+		}
+
+		seen := make(map[string]bool)
+
+		for dec.More() {
+			name, err := dec.Token()
+			if err != nil {
+				return err
+			}
+
+			key := name.(string)
+			if seen[key] {
+				return fmt.Errorf("operation %d: duplicate member %q", i, key)
+			}
+
+			seen[key] = true
+
+			var value json.RawMessage
+			if err := dec.Decode(&value); err != nil {
+				return err
+			}
+		}
+
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// check reports whether op is a known operation with the members it needs
+func (op Operation) check() error {
+	if _, err := parsePointer(op.Path); err != nil {
+		return fmt.Errorf("path: %w", err)
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return fmt.Errorf("%s needs a value", op.Op)
+		}
+	case "move", "copy":
+		if _, err := parsePointer(op.From); err != nil {
+			return fmt.Errorf("from: %w", err)
+		}
+
+		if op.Op == "move" && strings.HasPrefix(op.Path+"/", op.From+"/") && op.Path != op.From {
+			return errors.New("can't move a value into itself")
+		}
+	case "remove":
+	default:
+		return fmt.Errorf("unknown op %q", op.Op)
+	}
+
+	return nil
+}
+
+// Apply returns the result of applying the operations of p to doc in order.
+// The patch is atomic: if an operation fails, its error is returned and doc
+// is left unchanged.
+func (p JSONPatch) Apply(doc any) (any, error) {
+	doc = clone(doc)
+
+	for i, op := range p {
+		var err error
+		if doc, err = op.apply(doc); err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+
+	return doc, nil
+}
+
+// apply applies op to doc, which it may change in place
+func (op Operation) apply(doc any) (any, error) {
+	// The pointers were checked by ParseJSONPatch
+	path, _ := parsePointer(op.Path)
+
+	switch op.Op {
+	case "add":
+		value, err := decodeValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		return add(doc, path, value)
+	case "remove":
+		doc, _, err := remove(doc, path)
+
+		return doc, err
+	case "replace":
+		value, err := decodeValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		if doc, _, err = remove(doc, path); err != nil {
+			return nil, err
+		}
+
+		return add(doc, path, value)
+	case "move":
+		from, _ := parsePointer(op.From)
+
+		doc, value, err := remove(doc, from)
+		if err != nil {
+			return nil, fmt.Errorf("from: %w", err)
+		}
+
+		return add(doc, path, value)
+	case "copy":
+		from, _ := parsePointer(op.From)
+
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, fmt.Errorf("from: %w", err)
+		}
+
+		return add(doc, path, clone(value))
+	default: // "test"
+		want, err := decodeValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		got, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			return nil, ErrTestFailed
+		}
+
+		return doc, nil
+	}
+}
+
+// decodeValue decodes the value of an operation
+func decodeValue(data json.RawMessage) (any, error) {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+	}
+
+	return value, nil
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its unescaped reference
+// tokens. The empty pointer refers to the whole document.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("pointer %q must start with /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		// ~ only escapes ~ as ~0 and / as ~1
+		for j := range len(token) {
+			if token[j] == '~' && (j+1 == len(token) || (token[j+1] != '0' && token[j+1] != '1')) {
+				return nil, fmt.Errorf("pointer %q has an invalid escape", pointer)
+			}
+		}
+
+		tokens[i] = pointerUnescaper.Replace(token)
+	}
+
+	return tokens, nil
+}
+
+// get returns the value at path in doc
+func get(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch container := doc.(type) {
+		case map[string]any:
+			value, ok := container[token]
+			if !ok {
+				return nil, ErrPathNotFound
+			}
+
+			doc = value
+		case []any:
+			i, err := arrayIndex(token, len(container)-1)
+			if err != nil {
+				return nil, err
+			}
+
+			doc = container[i]
+		default:
+			return nil, ErrPathNotFound
+		}
+	}
+
+	return doc, nil
+}
+
+// add returns doc with value added at path. Adding to an object sets the
+// member, adding to an array inserts the element before the one at the index,
+// or at the end for "-", and adding to the whole document replaces it.
+func add(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	last := path[len(path)-1]
+
+	switch container := parent.(type) {
+	case map[string]any:
+		container[last] = value
+
+		return doc, nil
+	case []any:
+		i := len(container)
+		if last != "-" {
+			if i, err = arrayIndex(last, len(container)); err != nil {
+				return nil, err
+			}
+		}
+
+		return setParent(doc, path, slices.Insert(container, i, value))
+	default:
+		return nil, ErrPathNotFound
+	}
+}
+
+// remove returns doc with the value at path removed, along with the removed
+// value
+func remove(doc any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	last := path[len(path)-1]
+
+	switch container := parent.(type) {
+	case map[string]any:
+		value, ok := container[last]
+		if !ok {
+			return nil, nil, ErrPathNotFound
+		}
+
+		delete(container, last)
+
+		return doc, value, nil
+	case []any:
+		i, err := arrayIndex(last, len(container)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		value := container[i]
+		doc, err = setParent(doc, path, slices.Delete(container, i, i+1))
+
+		return doc, value, err
+	default:
+		return nil, nil, ErrPathNotFound
+	}
+}
+
+// setParent returns doc with the array holding the last token of path
+// replaced by array, whose length changed
+func setParent(doc any, path []string, array []any) (any, error) {
+	parentPath := path[:len(path)-1]
+	if len(parentPath) == 0 {
+		return array, nil
+	}
+
+	grandparent, err := get(doc, parentPath[:len(parentPath)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	token := parentPath[len(parentPath)-1]
+
+	switch container := grandparent.(type) {
+	case map[string]any:
+		container[token] = array
+	case []any:
+		// The index was already resolved by get
+		i, _ := strconv.Atoi(token)
+		container[i] = array
+	}
+
+	return doc, nil
+}
+
+// arrayIndex parses token as an index of an array from 0 to maxIndex
+func arrayIndex(token string, maxIndex int) (int, error) {
+	// Leading zeros and signs aren't allowed
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.Trim(token, "0123456789") != "" {
+		return 0, ErrPathNotFound
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i > maxIndex {
+		return 0, ErrPathNotFound
+	}
+
+	return i, nil
+}
+
+// clone returns a deep copy of a decoded JSON value
+func clone(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		copied := maps.Clone(value)
+		for name, member := range copied {
+			copied[name] = clone(member)
+		}
+
+		return copied
+	case []any:
+		copied := slices.Clone(value)
+		for i, element := range copied {
+			copied[i] = clone(element)
+		}
+
+		return copied
+	default:
+		return value
+	}
+}
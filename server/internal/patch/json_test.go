@@ -0,0 +1,156 @@
+package patch_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/villaleo/cstash/internal/patch"
+)
+
+// decode decodes a JSON document
+func decode(t *testing.T, data string) any {
+	t.Helper()
+
+	var doc any
+	if err := json.Unmarshal([]byte(data), &doc); err != nil {
+		t.Fatalf("decode %s: %v", data, err)
+	}
+
+	return doc
+}
+
+// TestJSONPatchApply runs the examples of RFC 6902, Appendix A
+func TestJSONPatchApply(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		// want is the patched document, unless the patch fails with err
+		want string
+		err  error
+	}{
+		{
+			"A.1 adding an object member",
+			`{"foo": "bar"}`,
+			`[{"op": "add", "path": "/baz", "value": "qux"}]`,
+			`{"baz": "qux", "foo": "bar"}`, nil,
+		},
+		{
+			"A.2 adding an array element",
+			`{"foo": ["bar", "baz"]}`,
+			`[{"op": "add", "path": "/foo/1", "value": "qux"}]`,
+			`{"foo": ["bar", "qux", "baz"]}`, nil,
+		},
+		{
+			"A.3 removing an object member",
+			`{"baz": "qux", "foo": "bar"}`,
+			`[{"op": "remove", "path": "/baz"}]`,
+			`{"foo": "bar"}`, nil,
+		},
+		{
+			"A.4 removing an array element",
+			`{"foo": ["bar", "qux", "baz"]}`,
+			`[{"op": "remove", "path": "/foo/1"}]`,
+			`{"foo": ["bar", "baz"]}`, nil,
+		},
+		{
+			"A.5 replacing a value",
+			`{"baz": "qux", "foo": "bar"}`,
+			`[{"op": "replace", "path": "/baz", "value": "boo"}]`,
+			`{"baz": "boo", "foo": "bar"}`, nil,
+		},
+		{
+			"A.6 moving a value",
+			`{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`,
+			`[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`,
+			`{"foo": {"bar": "baz"}, "qux": {"corge": "grault", "thud": "fred"}}`, nil,
+		},
+		{
+			"A.7 moving an array element",
+			`{"foo": ["all", "grass", "cows", "eat"]}`,
+			`[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`,
+			`{"foo": ["all", "cows", "eat", "grass"]}`, nil,
+		},
+		{
+			"A.8 testing a value: success",
+			`{"baz": "qux", "foo": ["a", 2, "c"]}`,
+			`[{"op": "test", "path": "/baz", "value": "qux"}, {"op": "test", "path": "/foo/1", "value": 2}]`,
+			`{"baz": "qux", "foo": ["a", 2, "c"]}`, nil,
+		},
+		{
+			"A.9 testing a value: error",
+			`{"baz": "qux"}`,
+			`[{"op": "test", "path": "/baz", "value": "bar"}]`,
+			"", patch.ErrTestFailed,
+		},
+		{
+			"A.10 adding a nested member object",
+			`{"foo": "bar"}`,
+			`[{"op": "add", "path": "/child", "value": {"grandchild": {}}}]`,
+			`{"foo": "bar", "child": {"grandchild": {}}}`, nil,
+		},
+		{
+			"A.11 ignoring unrecognized elements",
+			`{"foo": "bar"}`,
+			`[{"op": "add", "path": "/baz", "value": "qux", "xyz": 123}]`,
+			`{"foo": "bar", "baz": "qux"}`, nil,
+		},
+		{
+			"A.12 adding to a nonexistent target",
+			`{"foo": "bar"}`,
+			`[{"op": "add", "path": "/baz/bat", "value": "qux"}]`,
+			"", patch.ErrPathNotFound,
+		},
+		{
+			"A.13 invalid JSON Patch document",
+			`{"foo": "bar"}`,
+			`[{"op": "add", "path": "/baz", "value": "qux", "op": "remove"}]`,
+			"", patch.ErrInvalidPatch,
+		},
+		{
+			"A.14 ~ escape ordering",
+			`{"/": 9, "~1": 10}`,
+			`[{"op": "test", "path": "/~01", "value": 10}]`,
+			`{"/": 9, "~1": 10}`, nil,
+		},
+		{
+			"A.15 comparing strings and numbers",
+			`{"/": 9, "~1": 10}`,
+			`[{"op": "test", "path": "/~01", "value": "10"}]`,
+			"", patch.ErrTestFailed,
+		},
+		{
+			"A.16 adding an array value",
+			`{"foo": ["bar"]}`,
+			`[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`,
+			`{"foo": ["bar", ["abc", "def"]]}`, nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := decode(t, tt.doc)
+
+			p, err := patch.ParseJSONPatch([]byte(tt.patch))
+			if err == nil {
+				var patched any
+				if patched, err = p.Apply(doc); err == nil && tt.err == nil {
+					if want := decode(t, tt.want); !reflect.DeepEqual(patched, want) {
+						t.Errorf("Apply() = %v, want %v", patched, want)
+					}
+				}
+			}
+
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Apply() = %v, want %v", err, tt.err)
+			}
+
+			// Patches never change the document they're applied to
+			if original := decode(t, tt.doc); !reflect.DeepEqual(doc, original) {
+				t.Errorf("Apply() changed the document to %v, want %v", doc, original)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+// Package patch applies JSON Merge Patch (RFC 7396) and JSON Patch
+// (RFC 6902) documents to JSON values decoded with encoding/json into any.
+package patch
+
+import "maps"
+
+// Merge returns the result of applying the merge patch to doc. Objects in
+// the patch are merged into the objects of doc, removing members whose value
+// is null; any other value replaces the value of doc. doc is left unchanged.
+func Merge(doc, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	// Anything but an object is replaced by one
+	docObject, _ := doc.(map[string]any)
+
+	merged := make(map[string]any, len(docObject)+len(patchObject))
+	maps.Copy(merged, docObject)
+
+	for name, value := range patchObject {
+		if value == nil {
+			delete(merged, name)
+			continue
+		}
+
+		merged[name] = Merge(merged[name], value)
+	}
+
+	return merged
+}
@@ -0,0 +1,257 @@
+package search
+
+import (
+	"math"
+	"slices"
+	"sync"
+)
+
+// BM25 parameters. k1 controls how quickly repeated terms stop adding to the
+// score and b how much long fields are penalized.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Field identifies a searchable part of a Document
+type Field int
+
+const (
+	FieldTitle Field = iota
+	FieldDescription
+	FieldContent
+	FieldLanguage
+	numFields
+)
+
+// allFields lists every Field
+var allFields = []Field{FieldTitle, FieldDescription, FieldContent, FieldLanguage}
+
+// fieldWeights makes a match in the title count for more than one in the
+// content
+var fieldWeights = [numFields]float64{
+	FieldTitle:       3,
+	FieldDescription: 2,
+	FieldContent:     1,
+	FieldLanguage:    1,
+}
+
+// Document is the searchable text of an indexed item
+type Document struct {
+	Title       string
+	Description string
+	Content     string
+	Language    string
+}
+
+// field returns the text of doc's field f
+func (doc Document) field(f Field) string {
+	switch f {
+	case FieldTitle:
+		return doc.Title
+	case FieldDescription:
+		return doc.Description
+	case FieldContent:
+		return doc.Content
+	default:
+		return doc.Language
+	}
+}
+
+// fieldCounts holds a count for each field of a document
+type fieldCounts [numFields]int
+
+// Index is an inverted index ranking documents with BM25F, which scores term
+// frequencies across all fields of a document at once, weighted by field and
+// normalized by each field's length. Documents are added, replaced and
+// removed incrementally.
+//
+// An Index is safe for concurrent use.
+type Index struct {
+	// postings holds the term frequencies of every document containing a
+	// term, keyed by term then document ID
+	postings map[string]map[string]*fieldCounts
+	// docs holds the field lengths and distinct terms of every document
+	docs map[string]*docInfo
+	// totalLengths is the sum of every document's field lengths, used for the
+	// average field lengths
+	totalLengths fieldCounts
+	mu           sync.RWMutex
+}
+
+// docInfo is what an Index keeps about each document besides its postings
+type docInfo struct {
+	lengths fieldCounts
+	terms   []string
+}
+
+// NewIndex creates an empty index
+func NewIndex() *Index {
+	return &Index{
+		postings: make(map[string]map[string]*fieldCounts),
+		docs:     make(map[string]*docInfo),
+	}
+}
+
+// Len returns the number of documents in the index
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return len(idx.docs)
+}
+
+// Add indexes doc under id, replacing the document already indexed under id,
+// if any
+func (idx *Index) Add(id string, doc Document) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(id)
+
+	info := &docInfo{}
+
+	for _, f := range allFields {
+		tokens := Tokenize(doc.field(f))
+		info.lengths[f] = len(tokens)
+		idx.totalLengths[f] += len(tokens)
+
+		for _, token := range tokens {
+			docs, ok := idx.postings[token]
+			if !ok {
+				docs = make(map[string]*fieldCounts)
+				idx.postings[token] = docs
+			}
+
+			counts, ok := docs[id]
+			if !ok {
+				counts = &fieldCounts{}
+				docs[id] = counts
+				info.terms = append(info.terms, token)
+			}
+
+			counts[f]++
+		}
+	}
+
+	idx.docs[id] = info
+}
+
+// Remove removes the document indexed under id, if any
+func (idx *Index) Remove(id string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(id)
+}
+
+// remove removes the document indexed under id. The caller must hold mu.
+func (idx *Index) remove(id string) {
+	info, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+
+	for f := range numFields {
+		idx.totalLengths[f] -= info.lengths[f]
+	}
+
+	for _, term := range info.terms {
+		docs := idx.postings[term]
+		delete(docs, id)
+
+		if len(docs) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+
+	delete(idx.docs, id)
+}
+
+// Matches returns the IDs of the documents containing every one of terms in
+// any of fields, or in any field if fields is empty
+func (idx *Index) Matches(terms []string, fields []Field) map[string]bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if len(fields) == 0 {
+		fields = allFields
+	}
+
+	var matches map[string]bool
+
+	for i, term := range terms {
+		next := make(map[string]bool)
+
+		for id, counts := range idx.postings[term] {
+			if i > 0 && !matches[id] {
+				continue
+			}
+
+			for _, f := range fields {
+				if counts[f] > 0 {
+					next[id] = true
+					break
+				}
+			}
+		}
+
+		matches = next
+	}
+
+	return matches
+}
+
+// Scores returns the BM25F score of every document containing any of terms.
+// The more often a document contains the rarer terms, the higher its score.
+func (idx *Index) Scores(terms []string) map[string]float64 {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	scores := make(map[string]float64)
+	if len(idx.docs) == 0 {
+		return scores
+	}
+
+	var (
+		docCount = float64(len(idx.docs))
+		avgLen   [numFields]float64
+	)
+
+	for f := range numFields {
+		avgLen[f] = float64(idx.totalLengths[f]) / docCount
+	}
+
+	for _, term := range slices.Compact(slices.Sorted(slices.Values(terms))) {
+		docs := idx.postings[term]
+		idf := math.Log(1 + (docCount-float64(len(docs))+0.5)/(float64(len(docs))+0.5))
+
+		for id, counts := range docs {
+			tf := weightedFrequency(counts, &idx.docs[id].lengths, avgLen)
+			scores[id] += idf * tf / (k1 + tf)
+		}
+	}
+
+	return scores
+}
+
+// weightedFrequency combines the frequencies of a term in each field of a
+// document into one, weighting each field and normalizing it by its length
+func weightedFrequency(counts, lengths *fieldCounts, avgLen [numFields]float64) float64 {
+	var tf float64
+
+	for f := range numFields {
+		if counts[f] == 0 {
+			continue
+		}
+
+		norm := 1 - b
+		if avgLen[f] > 0 {
+			norm += b * float64(lengths[f]) / avgLen[f]
+		}
+
+		tf += fieldWeights[f] * float64(counts[f]) / norm
+	}
+
+	return tf
+}
@@ -0,0 +1,281 @@
+package search
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Limits guarding regex and fuzzy searches against patterns that would be
+// too costly to run over every snippet
+const (
+	// MaxPatternLength is the longest pattern accepted, in bytes
+	MaxPatternLength = 256
+	// maxRegexInsts caps the size of a compiled regex, which bounds the time
+	// RE2 spends on each byte of text
+	maxRegexInsts = 2000
+	// maxFuzzyWords is the most words a fuzzy pattern may have
+	maxFuzzyWords = 8
+	// maxMatches is the most matches reported for a single text
+	maxMatches = 20
+)
+
+// FuzzyThreshold is the least trigram similarity a part of a text must have
+// with a fuzzy pattern to match it
+const FuzzyThreshold = 0.4
+
+// ErrInvalidPattern is wrapped by every error returned by NewRegex and
+// NewFuzzy
+var ErrInvalidPattern = errors.New("invalid pattern")
+
+// Match is a part of a text matched by a Pattern. Start and End are byte
+// offsets into the text, and Line is the 1-based line Start falls on.
+type Match struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Line  int    `json:"line"`
+	Text  string `json:"text"`
+	// Score is how closely a fuzzy pattern matched, from FuzzyThreshold to 1
+	Score float64 `json:"score,omitempty"`
+}
+
+// Pattern is a regex or fuzzy pattern looked for in the content of items
+type Pattern interface {
+	// Find returns the parts of text matched by the pattern, in order, and a
+	// score ranking text against other texts. Text isn't matched if no parts
+	// are returned.
+	Find(text string) ([]Match, float64)
+}
+
+// Regex is a Pattern matching a regular expression
+type Regex struct {
+	re *regexp.Regexp
+}
+
+// NewRegex compiles a regular expression with RE2 syntax, which runs in time
+// linear in the length of the text. Patterns longer than MaxPatternLength or
+// compiling to too large a program are rejected.
+func NewRegex(pattern string) (*Regex, error) {
+	if len(pattern) > MaxPatternLength {
+		return nil, fmt.Errorf("%w: must be at most %d bytes long", ErrInvalidPattern, MaxPatternLength)
+	}
+
+	parsed, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPattern, describeRegexError(err))
+	}
+
+	prog, err := syntax.Compile(parsed.Simplify())
+	if err != nil || len(prog.Inst) > maxRegexInsts {
+		return nil, fmt.Errorf("%w: regex is too complex", ErrInvalidPattern)
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPattern, describeRegexError(err))
+	}
+
+	return &Regex{re: re}, nil
+}
+
+// describeRegexError returns the reason err gives for a regex being invalid,
+// without the package prefix
+func describeRegexError(err error) string {
+	var syntaxErr *syntax.Error
+	if errors.As(err, &syntaxErr) {
+		return fmt.Sprintf("%s: `%s`", syntaxErr.Code, syntaxErr.Expr)
+	}
+
+	return err.Error()
+}
+
+// Find returns the non-empty matches of r in text. Text is scored by its
+// number of matches.
+func (r *Regex) Find(text string) ([]Match, float64) {
+	var matches []Match
+
+	for _, loc := range r.re.FindAllStringIndex(text, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+
+		matches = append(matches, newMatch(text, loc[0], loc[1], 0))
+		if len(matches) == maxMatches {
+			break
+		}
+	}
+
+	return matches, float64(len(matches))
+}
+
+// Fuzzy is a Pattern matching the runs of words in a text that are similar
+// to its words, tolerating typos. Similarity is measured by the trigrams
+// (runs of three letters) the words share.
+type Fuzzy struct {
+	words    int
+	trigrams map[string]bool
+}
+
+// NewFuzzy creates a fuzzy pattern from the words of pattern
+func NewFuzzy(pattern string) (*Fuzzy, error) {
+	if len(pattern) > MaxPatternLength {
+		return nil, fmt.Errorf("%w: must be at most %d bytes long", ErrInvalidPattern, MaxPatternLength)
+	}
+
+	words := findWords(pattern)
+
+	switch {
+	case len(words) == 0:
+		return nil, fmt.Errorf("%w: fuzzy pattern has no words", ErrInvalidPattern)
+	case len(words) > maxFuzzyWords:
+		return nil, fmt.Errorf("%w: fuzzy pattern must have at most %d words", ErrInvalidPattern, maxFuzzyWords)
+	}
+
+	f := &Fuzzy{words: len(words), trigrams: make(map[string]bool)}
+
+	for _, w := range words {
+		addTrigrams(f.trigrams, pattern[w[0]:w[1]])
+	}
+
+	return f, nil
+}
+
+// Find returns the runs of as many words as f has in text that are similar
+// enough to f, best first where they overlap. Text is scored by its best
+// match.
+func (f *Fuzzy) Find(text string) ([]Match, float64) {
+	var (
+		words      = findWords(text)
+		wordGrams  = make([]map[string]bool, len(words))
+		candidates []Match
+	)
+
+	for i, w := range words {
+		wordGrams[i] = make(map[string]bool)
+		addTrigrams(wordGrams[i], text[w[0]:w[1]])
+	}
+
+	for i := 0; i+f.words <= len(words); i++ {
+		shared, total := 0, len(f.trigrams)
+		seen := make(map[string]bool)
+
+		for _, grams := range wordGrams[i : i+f.words] {
+			for gram := range grams {
+				if seen[gram] {
+					continue
+				}
+
+				seen[gram] = true
+
+				if f.trigrams[gram] {
+					shared++
+				} else {
+					total++
+				}
+			}
+		}
+
+		if score := float64(shared) / float64(total); score >= FuzzyThreshold {
+			start, end := words[i][0], words[i+f.words-1][1]
+			candidates = append(candidates, newMatch(text, start, end, score))
+		}
+	}
+
+	// Keep the best of overlapping runs
+	slices.SortStableFunc(candidates, func(x, y Match) int {
+		return cmp.Compare(y.Score, x.Score)
+	})
+
+	var matches []Match
+
+	for _, c := range candidates {
+		overlaps := slices.ContainsFunc(matches, func(m Match) bool {
+			return c.Start < m.End && m.Start < c.End
+		})
+
+		if !overlaps {
+			matches = append(matches, c)
+		}
+
+		if len(matches) == maxMatches {
+			break
+		}
+	}
+
+	if len(matches) == 0 {
+		return nil, 0
+	}
+
+	best := matches[0].Score
+
+	slices.SortFunc(matches, func(x, y Match) int {
+		return cmp.Compare(x.Start, y.Start)
+	})
+
+	return matches, best
+}
+
+// findWords returns the byte offsets of the start and end of every word in
+// text. Code identifiers are split into their words like Tokenize does, so
+// that "max retries" is similar to "maxRetries".
+func findWords(text string) [][2]int {
+	var (
+		words [][2]int
+		start = -1
+	)
+
+	addIdentifier := func(end int) {
+		ident, offset := text[start:end], start
+
+		for _, word := range splitIdentifier(ident) {
+			i := strings.Index(ident, word)
+			words = append(words, [2]int{offset + i, offset + i + len(word)})
+			ident, offset = ident[i+len(word):], offset+i+len(word)
+		}
+	}
+
+	for i, r := range text {
+		isIdent := r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+
+		switch {
+		case isIdent && start < 0:
+			start = i
+		case !isIdent && start >= 0:
+			addIdentifier(i)
+			start = -1
+		}
+	}
+
+	if start >= 0 {
+		addIdentifier(len(text))
+	}
+
+	return words
+}
+
+// addTrigrams adds the trigrams of word to grams. The word is lowercased and
+// padded with two spaces in front and one behind, so that its start weighs
+// more than its end and short words have trigrams too.
+func addTrigrams(grams map[string]bool, word string) {
+	runes := []rune("  " + strings.ToLower(word) + " ")
+
+	for i := 0; i+3 <= len(runes); i++ {
+		grams[string(runes[i:i+3])] = true
+	}
+}
+
+// newMatch returns the match of text from start to end
+func newMatch(text string, start, end int, score float64) Match {
+	return Match{
+		Start: start,
+		End:   end,
+		Line:  strings.Count(text[:start], "\n") + 1,
+		Text:  text[start:end],
+		Score: score,
+	}
+}
@@ -0,0 +1,125 @@
+package search_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/villaleo/cstash/internal/search"
+)
+
+func TestRegexFind(t *testing.T) {
+	tests := []struct {
+		pattern, text string
+		want          []search.Match
+	}{
+		{"foo", "bar", nil},
+		{"foo", "foo bar\nfoo", []search.Match{
+			{Start: 0, End: 3, Line: 1, Text: "foo"},
+			{Start: 8, End: 11, Line: 2, Text: "foo"},
+		}},
+		{`\d+`, "a1\nb22\n\nc333", []search.Match{
+			{Start: 1, End: 2, Line: 1, Text: "1"},
+			{Start: 4, End: 6, Line: 2, Text: "22"},
+			{Start: 9, End: 12, Line: 4, Text: "333"},
+		}},
+		{`(?m)^func \w+`, "package main\n\nfunc main() {}\nfunc init() {}\n", []search.Match{
+			{Start: 14, End: 23, Line: 3, Text: "func main"},
+			{Start: 29, End: 38, Line: 4, Text: "func init"},
+		}},
+		// Offsets are in bytes, not runes
+		{"b", "ééb", []search.Match{{Start: 4, End: 5, Line: 1, Text: "b"}}},
+		// Empty matches are skipped
+		{"x*", "axxb", []search.Match{{Start: 1, End: 3, Line: 1, Text: "xx"}}},
+		{"$", "abc\n", nil},
+	}
+
+	for _, tt := range tests {
+		re, err := search.NewRegex(tt.pattern)
+		if err != nil {
+			t.Fatalf("NewRegex(%q) = %v", tt.pattern, err)
+		}
+
+		matches, score := re.Find(tt.text)
+		if !slices.Equal(matches, tt.want) {
+			t.Errorf("Find(%q) with %q = %+v, want %+v", tt.text, tt.pattern, matches, tt.want)
+		}
+
+		if score != float64(len(tt.want)) {
+			t.Errorf("Find(%q) with %q scored %v, want %d", tt.text, tt.pattern, score, len(tt.want))
+		}
+	}
+}
+
+func TestRegexFindStopsAtMaxMatches(t *testing.T) {
+	re, err := search.NewRegex("a")
+	if err != nil {
+		t.Fatalf("NewRegex() = %v", err)
+	}
+
+	matches, _ := re.Find(strings.Repeat("a\n", 50))
+	if len(matches) != 20 {
+		t.Fatalf("Find() = %d matches, want 20", len(matches))
+	}
+
+	if last := matches[len(matches)-1]; last.Start != 38 || last.Line != 20 {
+		t.Errorf("last match = %+v, want one at byte 38 on line 20", last)
+	}
+}
+
+func TestFuzzyFind(t *testing.T) {
+	tests := []struct {
+		pattern, text string
+		want          []search.Match
+	}{
+		{"retry", "nothing alike", nil},
+		// Identifiers are split into words like the pattern's
+		{"max retries", "x := 1\nfor maxRetries > 0 {", []search.Match{
+			{Start: 11, End: 21, Line: 2, Text: "maxRetries"},
+		}},
+		{"max retries", "// max retries\nconst MAX_RETRIES = 3", []search.Match{
+			{Start: 3, End: 14, Line: 1, Text: "max retries"},
+			{Start: 21, End: 32, Line: 2, Text: "MAX_RETRIES"},
+		}},
+		// Typos are tolerated
+		{"conext", "ctx context.Context", []search.Match{
+			{Start: 4, End: 11, Line: 1, Text: "context"},
+			{Start: 12, End: 19, Line: 1, Text: "Context"},
+		}},
+		// Offsets are in bytes, not runes
+		{"naïve", "é\n\nnaïve", []search.Match{{Start: 4, End: 10, Line: 3, Text: "naïve"}}},
+		// Overlapping runs are only matched once
+		{"foo bar", "foo bar foo bar", []search.Match{
+			{Start: 0, End: 7, Line: 1, Text: "foo bar"},
+			{Start: 8, End: 15, Line: 1, Text: "foo bar"},
+		}},
+	}
+
+	for _, tt := range tests {
+		fuzzy, err := search.NewFuzzy(tt.pattern)
+		if err != nil {
+			t.Fatalf("NewFuzzy(%q) = %v", tt.pattern, err)
+		}
+
+		matches, score := fuzzy.Find(tt.text)
+
+		// Scores are checked apart, since they're only bounded
+		best := 0.0
+		for i, m := range matches {
+			if m.Score < search.FuzzyThreshold || m.Score > 1 {
+				t.Errorf("Find(%q) with %q: match %+v scored below the threshold", tt.text, tt.pattern, m)
+			}
+
+			best = max(best, m.Score)
+			matches[i].Score = 0
+		}
+
+		if !slices.Equal(matches, tt.want) {
+			t.Errorf("Find(%q) with %q = %+v, want %+v", tt.text, tt.pattern, matches, tt.want)
+		}
+
+		if score != best {
+			t.Errorf("Find(%q) with %q scored %v, want the best match's %v", tt.text, tt.pattern, score, best)
+		}
+	}
+}
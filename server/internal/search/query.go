@@ -0,0 +1,573 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrInvalidQuery is wrapped by every error returned by ParseQuery
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Node is a parsed search query, or part of one. It is one of *And, *Or, *Not,
+// *Text, *Tag, *Language, *Is or *Date.
+type Node interface {
+	node()
+}
+
+// And matches items matched by every one of Nodes
+type And struct {
+	Nodes []Node
+}
+
+// Or matches items matched by any of Nodes
+type Or struct {
+	Nodes []Node
+}
+
+// Not matches items not matched by Node
+type Not struct {
+	Node Node
+}
+
+// Text matches items containing every term of Value. Phrases must also
+// contain Value as is, ignoring case. Fields limits where Value is looked
+// for; a nil Fields means every field.
+type Text struct {
+	Value  string
+	Phrase bool
+	Fields []Field
+	terms  []string
+}
+
+// Tag matches items tagged Name
+type Tag struct {
+	Name string
+}
+
+// Language matches items written in Name, ignoring case
+type Language struct {
+	Name string
+}
+
+// Is matches items with Flag, one of "favorite", "private", "team" or
+// "public"
+type Is struct {
+	Flag string
+}
+
+// Date matches items whose Field, "created" or "updated", is at or after
+// From and before To. A zero From or To leaves that end open.
+type Date struct {
+	Field    string
+	From, To time.Time
+}
+
+func (*And) node()      {}
+func (*Or) node()       {}
+func (*Not) node()      {}
+func (*Text) node()     {}
+func (*Tag) node()      {}
+func (*Language) node() {}
+func (*Is) node()       {}
+func (*Date) node()     {}
+
+// Terms returns the search terms of t's value
+func (t *Text) Terms() []string {
+	return t.terms
+}
+
+// Contained reports whether doc contains t's value as is, ignoring case. Only
+// phrases and values without any terms, such as "&&", need to be contained
+// as is; other values always are.
+func (t *Text) Contained(doc Document) bool {
+	if !t.Phrase && len(t.terms) > 0 {
+		return true
+	}
+
+	value := strings.ToLower(t.Value)
+
+	for _, f := range t.fields() {
+		if strings.Contains(strings.ToLower(doc.field(f)), value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fields returns the fields t's value is looked for in
+func (t *Text) fields() []Field {
+	if t.Fields == nil {
+		return allFields
+	}
+
+	return t.Fields
+}
+
+// Contains reports whether t falls within d's range
+func (d *Date) Contains(t time.Time) bool {
+	return (d.From.IsZero() || !t.Before(d.From)) && (d.To.IsZero() || t.Before(d.To))
+}
+
+// PositiveTerms returns the terms of every Text in node that isn't negated,
+// which are the terms the items matched by node are ranked by
+func PositiveTerms(node Node) []string {
+	var (
+		terms []string
+		walk  func(node Node, negated bool)
+	)
+
+	walk = func(node Node, negated bool) {
+		switch n := node.(type) {
+		case *And:
+			for _, child := range n.Nodes {
+				walk(child, negated)
+			}
+		case *Or:
+			for _, child := range n.Nodes {
+				walk(child, negated)
+			}
+		case *Not:
+			walk(n.Node, !negated)
+		case *Text:
+			if !negated {
+				terms = append(terms, n.terms...)
+			}
+		}
+	}
+
+	walk(node, false)
+
+	return terms
+}
+
+// ParseQuery parses a search query. Terms are separated by spaces and all
+// must match, unless joined with OR. A term is negated with a leading "-" or
+// NOT, and terms can be grouped with parentheses. For example:
+//
+//	lang:go tag:http -tag:deprecated "context.Context" is:favorite created:>2025-01-01
+//
+// A term is either a word, a "quoted phrase" or a qualifier:
+//
+//	tag:NAME                    tagged NAME
+//	lang:NAME                   written in NAME (also language:)
+//	is:FLAG                     favorite, private, team or public
+//	created:RANGE               created within RANGE (also updated:)
+//	title:, desc:, content:     the word or phrase appears in that field
+//
+// A RANGE is a date (2025-01-01) or an RFC 3339 time, optionally preceded by
+// >, >=, < or <=, or two of them separated by "..". Words that look like
+// qualifiers but aren't, such as "std::vector", are searched for as is.
+//
+// ParseQuery returns a nil Node for a blank query.
+func ParseQuery(query string) (Node, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	p := &parser{tokens: tokens}
+
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("%w: unexpected %s", ErrInvalidQuery, tok)
+	}
+
+	return node, nil
+}
+
+// tokenKind identifies the kind of a query token
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenLParen
+	tokenRParen
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenTerm
+)
+
+// token is a lexed query token. Terms have a value and, if they are a
+// qualifier, a key.
+type token struct {
+	kind   tokenKind
+	key    string
+	value  string
+	phrase bool
+}
+
+// String describes tok for error messages
+func (tok token) String() string {
+	switch tok.kind {
+	case tokenEOF:
+		return "end of query"
+	case tokenLParen:
+		return `"("`
+	case tokenRParen:
+		return `")"`
+	case tokenAnd:
+		return "AND"
+	case tokenOr:
+		return "OR"
+	case tokenNot:
+		return "NOT"
+	default:
+		return fmt.Sprintf("%q", tok.value)
+	}
+}
+
+// qualifiers lists the keys recognized before a colon in a term
+var qualifiers = map[string]bool{
+	"tag": true, "lang": true, "language": true, "is": true, "created": true,
+	"updated": true, "title": true, "desc": true, "description": true, "content": true,
+}
+
+// lex splits query into tokens
+func lex(query string) ([]token, error) {
+	var (
+		tokens []token
+		runes  = []rune(query)
+	)
+
+	// readPhrase reads a quoted phrase starting at runes[i] and returns it
+	// along with the index following the closing quote
+	readPhrase := func(i int) (string, int, error) {
+		end := i + 1
+		for end < len(runes) && runes[end] != '"' {
+			end++
+		}
+
+		if end == len(runes) {
+			return "", 0, fmt.Errorf("%w: unclosed quote", ErrInvalidQuery)
+		}
+
+		return string(runes[i+1 : end]), end + 1, nil
+	}
+
+	isDelimiter := func(r rune) bool {
+		return unicode.IsSpace(r) || r == '(' || r == ')' || r == '"'
+	}
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen})
+			i++
+		case r == '-' && i+1 < len(runes) && isNegatable(runes[i+1]):
+			tokens = append(tokens, token{kind: tokenNot})
+			i++
+		case r == '"':
+			phrase, next, err := readPhrase(i)
+			if err != nil {
+				return nil, err
+			}
+
+			tokens = append(tokens, token{kind: tokenTerm, value: phrase, phrase: true})
+			i = next
+		default:
+			start := i
+			for i < len(runes) && !isDelimiter(runes[i]) {
+				i++
+			}
+
+			word := string(runes[start:i])
+
+			switch word {
+			case "AND":
+				tokens = append(tokens, token{kind: tokenAnd})
+				continue
+			case "OR":
+				tokens = append(tokens, token{kind: tokenOr})
+				continue
+			case "NOT":
+				tokens = append(tokens, token{kind: tokenNot})
+				continue
+			}
+
+			key, value, ok := strings.Cut(word, ":")
+			if !ok || !qualifiers[strings.ToLower(key)] {
+				tokens = append(tokens, token{kind: tokenTerm, value: word})
+				continue
+			}
+
+			tok := token{kind: tokenTerm, key: strings.ToLower(key), value: value}
+
+			// A qualifier's value may be quoted, as in title:"hello world"
+			if value == "" && i < len(runes) && runes[i] == '"' {
+				phrase, next, err := readPhrase(i)
+				if err != nil {
+					return nil, err
+				}
+
+				tok.value, tok.phrase = phrase, true
+				i = next
+			}
+
+			tokens = append(tokens, tok)
+		}
+	}
+
+	return tokens, nil
+}
+
+// isNegatable reports whether a "-" followed by r negates the term it starts,
+// rather than being part of a word like "--verbose"
+func isNegatable(r rune) bool {
+	return r == '"' || r == '(' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// parser builds a Node from tokens by recursive descent. NOT binds tighter
+// than AND, which binds tighter than OR.
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// peek returns the next token without consuming it
+func (p *parser) peek() token {
+	if p.pos == len(p.tokens) {
+		return token{kind: tokenEOF}
+	}
+
+	return p.tokens[p.pos]
+}
+
+// next consumes and returns the next token
+func (p *parser) next() token {
+	tok := p.peek()
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+
+	return tok
+}
+
+// parseOr parses terms joined by OR
+func (p *parser) parseOr() (Node, error) {
+	node, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []Node{node}
+
+	for p.peek().kind == tokenOr {
+		p.next()
+
+		node, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+
+	return &Or{Nodes: nodes}, nil
+}
+
+// parseAnd parses terms joined by AND or by nothing at all
+func (p *parser) parseAnd() (Node, error) {
+	var nodes []Node
+
+	for {
+		switch p.peek().kind {
+		case tokenEOF, tokenRParen, tokenOr:
+			if len(nodes) == 0 {
+				return nil, fmt.Errorf("%w: expected a term before %s", ErrInvalidQuery, p.peek())
+			}
+
+			if len(nodes) == 1 {
+				return nodes[0], nil
+			}
+
+			return &And{Nodes: nodes}, nil
+		case tokenAnd:
+			if len(nodes) == 0 {
+				return nil, fmt.Errorf("%w: expected a term before AND", ErrInvalidQuery)
+			}
+
+			p.next()
+
+			if kind := p.peek().kind; kind == tokenEOF || kind == tokenRParen || kind == tokenOr {
+				return nil, fmt.Errorf("%w: expected a term after AND", ErrInvalidQuery)
+			}
+		}
+
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		nodes = append(nodes, node)
+	}
+}
+
+// parseUnary parses a term, possibly negated
+func (p *parser) parseUnary() (Node, error) {
+	if p.peek().kind != tokenNot {
+		return p.parsePrimary()
+	}
+
+	p.next()
+
+	node, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Not{Node: node}, nil
+}
+
+// parsePrimary parses a single term or a parenthesized group
+func (p *parser) parsePrimary() (Node, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokenLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.next().kind != tokenRParen {
+			return nil, fmt.Errorf("%w: unclosed parenthesis", ErrInvalidQuery)
+		}
+
+		return node, nil
+	case tokenTerm:
+		return newTerm(tok)
+	default:
+		return nil, fmt.Errorf("%w: unexpected %s", ErrInvalidQuery, tok)
+	}
+}
+
+// newTerm creates the Node for a term token
+func newTerm(tok token) (Node, error) {
+	if tok.key != "" && tok.value == "" {
+		return nil, fmt.Errorf("%w: %s: needs a value", ErrInvalidQuery, tok.key)
+	}
+
+	switch tok.key {
+	case "tag":
+		return &Tag{Name: tok.value}, nil
+	case "lang", "language":
+		return &Language{Name: tok.value}, nil
+	case "is":
+		flag := strings.ToLower(tok.value)
+
+		switch flag {
+		case "favorite", "private", "team", "public":
+			return &Is{Flag: flag}, nil
+		default:
+			return nil, fmt.Errorf("%w: is: must be one of favorite, private, team or public", ErrInvalidQuery)
+		}
+	case "created", "updated":
+		return parseDateRange(tok.key, tok.value)
+	case "title":
+		return newText(tok.value, tok.phrase, FieldTitle), nil
+	case "desc", "description":
+		return newText(tok.value, tok.phrase, FieldDescription), nil
+	case "content":
+		return newText(tok.value, tok.phrase, FieldContent), nil
+	default:
+		return newText(tok.value, tok.phrase), nil
+	}
+}
+
+// newText creates a Text looked for in fields, or every field if none are
+// given
+func newText(value string, phrase bool, fields ...Field) *Text {
+	return &Text{
+		Value:  value,
+		Phrase: phrase,
+		Fields: fields,
+		terms:  tokenizeQuery(value),
+	}
+}
+
+// parseDateRange parses the RANGE of a created: or updated: qualifier
+func parseDateRange(field, value string) (*Date, error) {
+	date := &Date{Field: field}
+
+	if from, to, ok := strings.Cut(value, ".."); ok {
+		start, _, err := parseDate(field, from)
+		if err != nil {
+			return nil, err
+		}
+
+		_, end, err := parseDate(field, to)
+		if err != nil {
+			return nil, err
+		}
+
+		date.From, date.To = start, end
+
+		return date, nil
+	}
+
+	var op string
+	for _, prefix := range []string{">=", "<=", ">", "<"} {
+		if rest, ok := strings.CutPrefix(value, prefix); ok {
+			op, value = prefix, rest
+			break
+		}
+	}
+
+	start, end, err := parseDate(field, value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case ">":
+		date.From = end
+	case ">=":
+		date.From = start
+	case "<":
+		date.To = start
+	case "<=":
+		date.To = end
+	default:
+		date.From, date.To = start, end
+	}
+
+	return date, nil
+}
+
+// parseDate parses a date or an RFC 3339 time, returning the range of times
+// it covers. A date covers the whole day in UTC.
+func parseDate(field, value string) (start, end time.Time, err error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, t.AddDate(0, 0, 1), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, t.Add(time.Nanosecond), nil
+	}
+
+	return start, end, fmt.Errorf("%w: %s: expects a date like 2025-01-01, got %q", ErrInvalidQuery, field, value)
+}
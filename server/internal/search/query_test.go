@@ -0,0 +1,209 @@
+package search_test
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/villaleo/cstash/internal/search"
+)
+
+// fieldNames names the fields a Text can be limited to, as qualifiers do
+var fieldNames = map[search.Field]string{
+	search.FieldTitle:       "title",
+	search.FieldDescription: "desc",
+	search.FieldContent:     "content",
+}
+
+// format writes node as an s-expression, such as (or (and a b) (not tag:c)),
+// so that the shape of a parsed query can be compared at a glance
+func format(node search.Node) string {
+	switch n := node.(type) {
+	case nil:
+		return "<nil>"
+	case *search.And:
+		return formatList("and", n.Nodes)
+	case *search.Or:
+		return formatList("or", n.Nodes)
+	case *search.Not:
+		return "(not " + format(n.Node) + ")"
+	case *search.Text:
+		var prefix string
+		for _, f := range n.Fields {
+			prefix += fieldNames[f] + ":"
+		}
+
+		if n.Phrase {
+			return fmt.Sprintf("%s%q", prefix, n.Value)
+		}
+
+		return prefix + n.Value
+	case *search.Tag:
+		return "tag:" + n.Name
+	case *search.Language:
+		return "lang:" + n.Name
+	case *search.Is:
+		return "is:" + n.Flag
+	case *search.Date:
+		return fmt.Sprintf("%s:[%s,%s)", n.Field, formatTime(n.From), formatTime(n.To))
+	default:
+		return fmt.Sprintf("%T", node)
+	}
+}
+
+func formatList(op string, nodes []search.Node) string {
+	parts := []string{op}
+	for _, node := range nodes {
+		parts = append(parts, format(node))
+	}
+
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+// formatTime formats t, or * for the open end of a range
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "*"
+	}
+
+	return t.Format(time.RFC3339Nano)
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		// Blank queries match everything
+		{"", "<nil>"},
+		{"  \t", "<nil>"},
+
+		// Terms and qualifiers
+		{"hello", "hello"},
+		{"hello world", "(and hello world)"},
+		{`"hello world"`, `"hello world"`},
+		{"tag:http lang:Go is:Favorite", "(and tag:http lang:Go is:favorite)"},
+		{"language:rust", "lang:rust"},
+		{`title:"hello world" desc:retry content:ctx`, `(and title:"hello world" desc:retry content:ctx)`},
+		{"std::vector http://example.com", "(and std::vector http://example.com)"},
+
+		// NOT binds tighter than AND, which binds tighter than OR
+		{"a b OR c", "(or (and a b) c)"},
+		{"a OR b c", "(or a (and b c))"},
+		{"a AND b OR c AND d", "(or (and a b) (and c d))"},
+		{"a OR b OR c", "(or a b c)"},
+		{"NOT a OR b", "(or (not a) b)"},
+		{"NOT a b", "(and (not a) b)"},
+		{"(a OR b) c", "(and (or a b) c)"},
+		{"a (b OR (c d))", "(and a (or b (and c d)))"},
+		{"((a))", "a"},
+
+		// Negation
+		{"-a b", "(and (not a) b)"},
+		{"-tag:deprecated", "(not tag:deprecated)"},
+		{`-"hello world"`, `(not "hello world")`},
+		{"-(a OR b)", "(not (or a b))"},
+		{"NOT NOT a", "(not (not a))"},
+		{"--verbose", "--verbose"},
+		{"a - b", "(and a - b)"},
+
+		// Date ranges cover whole days in UTC
+		{"created:2025-01-01", "created:[2025-01-01T00:00:00Z,2025-01-02T00:00:00Z)"},
+		{"created:>2025-01-01", "created:[2025-01-02T00:00:00Z,*)"},
+		{"created:>=2025-01-01", "created:[2025-01-01T00:00:00Z,*)"},
+		{"updated:<2025-01-01", "updated:[*,2025-01-01T00:00:00Z)"},
+		{"updated:<=2025-01-01", "updated:[*,2025-01-02T00:00:00Z)"},
+		{"created:2025-01-01..2025-01-31", "created:[2025-01-01T00:00:00Z,2025-02-01T00:00:00Z)"},
+		{"created:>2025-01-01T12:00:00Z", "created:[2025-01-01T12:00:00.000000001Z,*)"},
+		{"created:2025-01-01T12:00:00+02:00..2025-01-02", "created:[2025-01-01T12:00:00+02:00,2025-01-03T00:00:00Z)"},
+	}
+
+	for _, tt := range tests {
+		node, err := search.ParseQuery(tt.query)
+		if err != nil {
+			t.Errorf("ParseQuery(%q) = %v", tt.query, err)
+			continue
+		}
+
+		if got := format(node); got != tt.want {
+			t.Errorf("ParseQuery(%q) = %s, want %s", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseQueryRejects(t *testing.T) {
+	tests := []string{
+		// Unbalanced parentheses
+		"(a",
+		"((a) b",
+		"a)",
+		"(a))",
+		")(",
+		"()",
+
+		// Operators missing a term
+		"OR a",
+		"a OR",
+		"a OR OR b",
+		"AND a",
+		"a AND",
+		"a AND OR b",
+		"NOT",
+		"a -(",
+
+		// Bad terms
+		`"unclosed`,
+		`title:"unclosed`,
+		"tag:",
+		"is:deleted",
+		"created:yesterday",
+		"created:>",
+		"created:2025-01-01..",
+		"created:2025-13-01",
+	}
+
+	for _, query := range tests {
+		node, err := search.ParseQuery(query)
+		if !errors.Is(err, search.ErrInvalidQuery) {
+			t.Errorf("ParseQuery(%q) = %s, %v, want %v", query, format(node), err, search.ErrInvalidQuery)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", nil},
+		{"Hello, World!", []string{"hello", "world"}},
+
+		// camelCase and acronyms
+		{"parseHTTPRequest", []string{"parse", "http", "request", "parsehttprequest"}},
+		{"HTTPServer", []string{"http", "server", "httpserver"}},
+		{"XMLHttpRequest", []string{"xml", "http", "request", "xmlhttprequest"}},
+		{"getID", []string{"get", "id", "getid"}},
+		{"IOError", []string{"io", "error", "ioerror"}},
+		{"utf8Decode", []string{"utf8", "decode", "utf8decode"}},
+		{"URL", []string{"url"}},
+
+		// snake_case
+		{"max_retry_count", []string{"max", "retry", "count", "max_retry_count"}},
+		{"MAX_RETRIES", []string{"max", "retries", "max_retries"}},
+		{"_private", []string{"private"}},
+		{"__init__", []string{"init"}},
+		{"snake_camelCase", []string{"snake", "camel", "case", "snake_camelcase"}},
+
+		// Identifiers end at anything but letters, digits and underscores
+		{"ctx.Done() <- nil", []string{"ctx", "done", "nil"}},
+	}
+
+	for _, tt := range tests {
+		if got := search.Tokenize(tt.text); !slices.Equal(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
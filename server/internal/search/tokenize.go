@@ -0,0 +1,88 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Tokenize splits text into lowercase search terms. Code identifiers are split
+// into their words as well as kept whole, so that "parseHTTPRequest" yields
+// "parse", "http", "request" and "parsehttprequest", and "max_retry_count"
+// yields "max", "retry", "count" and "max_retry_count".
+func Tokenize(text string) []string {
+	return tokenize(text, true)
+}
+
+// tokenizeQuery splits a search query into the terms a document must contain.
+// Identifiers are only split into their words, since the whole identifier a
+// query names may be part of a longer one in the document.
+func tokenizeQuery(query string) []string {
+	return tokenize(query, false)
+}
+
+// tokenize splits text into lowercase terms, keeping whole identifiers as well
+// as their words if keepWhole is true
+func tokenize(text string, keepWhole bool) []string {
+	var tokens []string
+
+	isIdentRune := func(r rune) bool {
+		return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+
+	for _, ident := range strings.FieldsFunc(text, func(r rune) bool { return !isIdentRune(r) }) {
+		words := splitIdentifier(ident)
+
+		for _, word := range words {
+			tokens = append(tokens, strings.ToLower(word))
+		}
+
+		if whole := strings.Trim(ident, "_"); keepWhole && len(words) > 1 && whole != "" {
+			tokens = append(tokens, strings.ToLower(whole))
+		}
+	}
+
+	return tokens
+}
+
+// splitIdentifier splits ident into its words at underscores and changes of
+// case. A run of capitals is kept together as an acronym, except for its last
+// letter if that starts a new word, as in "HTTPServer".
+func splitIdentifier(ident string) []string {
+	var (
+		words []string
+		runes = []rune(ident)
+		start = 0
+	)
+
+	flush := func(end int) {
+		if end > start {
+			words = append(words, string(runes[start:end]))
+		}
+	}
+
+	for i, r := range runes {
+		if r == '_' {
+			flush(i)
+			start = i + 1
+
+			continue
+		}
+
+		if i == start || !unicode.IsUpper(r) {
+			continue
+		}
+
+		prev := runes[i-1]
+		nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+
+		// camelCase, or the end of an acronym followed by a word
+		if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextIsLower) {
+			flush(i)
+			start = i
+		}
+	}
+
+	flush(len(runes))
+
+	return words
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"slices"
+
+	"github.com/villaleo/cstash/internal/models"
+)
+
+// canViewCollection reports whether the caller making the call with ctx may
+// view collection
+func canViewCollection(ctx context.Context, collection *models.Collection) bool {
+	if collection.WorkspaceID != workspaceID(ctx) {
+		return false
+	}
+
+	// Every member of a workspace may view its collections
+	return collection.WorkspaceID != "" || collection.OwnerID == callerID(ctx)
+}
+
+// canEditCollection reports whether the caller making the call with ctx may
+// change the collections they may view
+func canEditCollection(ctx context.Context) bool {
+	if membership := workspaceMembership(ctx); membership != nil {
+		return membership.Role.CanEdit()
+	}
+
+	// Personal collections are only visible to their owner
+	return true
+}
+
+// addedSnippets returns the IDs of the snippets in after that aren't in before
+func addedSnippets(before, after *models.Collection) []string {
+	var added []string
+
+	for _, id := range after.SnippetIDs {
+		if before == nil || !before.Contains(id) {
+			added = append(added, id)
+		}
+	}
+
+	return added
+}
+
+// cloneCollection returns a copy of collection that can be changed without
+// changing it
+func cloneCollection(collection *models.Collection) *models.Collection {
+	clone := *collection
+	clone.SnippetIDs = slices.Clone(collection.SnippetIDs)
+
+	return &clone
+}
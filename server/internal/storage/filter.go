@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/villaleo/cstash/internal/models"
+	"github.com/villaleo/cstash/internal/search"
+)
+
+// TagMode is how the tags of a SnippetFilter are combined
+type TagMode string
+
+const (
+	// TagModeAny selects snippets with any of the tags
+	TagModeAny TagMode = "any"
+	// TagModeAll selects snippets with every one of the tags
+	TagModeAll TagMode = "all"
+)
+
+// Valid reports whether m is a known tag mode
+func (m TagMode) Valid() bool {
+	return m == TagModeAny || m == TagModeAll
+}
+
+// SnippetFilter selects the snippets returned by ListSnippets. A snippet is
+// selected if it has the tags in Tags as TagMode asks, none of NotTags,
+// matches Query and its content matches Pattern; a snippet has a tag if it
+// has the tag or one below it. Unset fields select every snippet, so the zero
+// SnippetFilter selects every snippet.
+type SnippetFilter struct {
+	Tags []string
+	// TagMode defaults to TagModeAny
+	TagMode TagMode
+	NotTags []string
+	// Query is parsed with search.ParseQuery
+	Query   search.Node
+	Pattern search.Pattern
+}
+
+// SnippetHit is a snippet selected by a SnippetFilter, along with the parts
+// of its content matched by the filter's pattern
+type SnippetHit struct {
+	*models.Snippet
+	Matches []search.Match `json:"matches,omitempty"`
+	// score is the relevance of the snippet to the filter's query and pattern
+	score float64
+}
+
+// node combines the tags and query of f into a single query, or returns nil
+// if they select every snippet
+func (f SnippetFilter) node() search.Node {
+	var nodes, tags []search.Node
+
+	for _, tag := range f.Tags {
+		tags = append(tags, &search.Tag{Name: tag})
+	}
+
+	switch {
+	case len(tags) == 1:
+		nodes = append(nodes, tags[0])
+	case len(tags) > 1 && f.TagMode == TagModeAll:
+		nodes = append(nodes, tags...)
+	case len(tags) > 1:
+		nodes = append(nodes, &search.Or{Nodes: tags})
+	}
+
+	for _, tag := range f.NotTags {
+		nodes = append(nodes, &search.Not{Node: &search.Tag{Name: tag}})
+	}
+
+	if f.Query != nil {
+		nodes = append(nodes, f.Query)
+	}
+
+	switch len(nodes) {
+	case 0:
+		return nil
+	case 1:
+		return nodes[0]
+	default:
+		return &search.And{Nodes: nodes}
+	}
+}
+
+// snippetDocument returns the searchable text of snippet
+func snippetDocument(snippet *models.Snippet) search.Document {
+	return search.Document{
+		Title:       snippet.Title,
+		Description: snippet.Description,
+		Content:     snippet.Content,
+		Language:    snippet.Language,
+	}
+}
+
+// filterSnippets returns the snippets visible to the caller that are selected
+// by filter, scored by their relevance to its query and pattern. The words of
+// the filter's query are looked up in index, which must hold every snippet.
+//
+// Looking for a pattern in every snippet can take a while, so ctx's error is
+// returned if it's done before every snippet is checked.
+func filterSnippets(ctx context.Context, snippets []*models.Snippet, filter SnippetFilter, index *search.Index) ([]*SnippetHit, error) {
+	var (
+		node    = filter.node()
+		matcher = &queryMatcher{index: index, matches: make(map[*search.Text]map[string]bool)}
+		scores  map[string]float64
+		results = make([]*SnippetHit, 0, len(snippets))
+	)
+
+	if node != nil {
+		scores = index.Scores(search.PositiveTerms(node))
+	}
+
+	for _, snippet := range snippets {
+		if !canView(ctx, snippet) {
+			continue
+		}
+
+		hit := &SnippetHit{Snippet: snippet, score: scores[snippet.ID]}
+
+		if node != nil && !matcher.match(snippet, node) {
+			continue
+		}
+
+		if filter.Pattern != nil {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			var score float64
+
+			hit.Matches, score = filter.Pattern.Find(snippet.Content)
+			if len(hit.Matches) == 0 {
+				continue
+			}
+
+			hit.score += score
+		}
+
+		results = append(results, hit)
+	}
+
+	return results, nil
+}
+
+// queryMatcher evaluates a parsed query against snippets. The snippets
+// containing the words of each Text are looked up in index once and reused
+// for every snippet.
+type queryMatcher struct {
+	index   *search.Index
+	matches map[*search.Text]map[string]bool
+}
+
+// match reports whether snippet is matched by node
+func (m *queryMatcher) match(snippet *models.Snippet, node search.Node) bool {
+	switch n := node.(type) {
+	case *search.And:
+		for _, child := range n.Nodes {
+			if !m.match(snippet, child) {
+				return false
+			}
+		}
+
+		return true
+	case *search.Or:
+		for _, child := range n.Nodes {
+			if m.match(snippet, child) {
+				return true
+			}
+		}
+
+		return false
+	case *search.Not:
+		return !m.match(snippet, n.Node)
+	case *search.Text:
+		if len(n.Terms()) > 0 {
+			matches, ok := m.matches[n]
+			if !ok {
+				matches = m.index.Matches(n.Terms(), n.Fields)
+				m.matches[n] = matches
+			}
+
+			if !matches[snippet.ID] {
+				return false
+			}
+		}
+
+		return n.Contained(snippetDocument(snippet))
+	case *search.Tag:
+		return slices.ContainsFunc(snippet.Tags, func(tag string) bool {
+			return models.TagMatches(tag, n.Name)
+		})
+	case *search.Language:
+		return strings.EqualFold(snippet.Language, n.Name)
+	case *search.Is:
+		switch n.Flag {
+		case "favorite":
+			return snippet.IsFavorite
+		default:
+			return string(snippet.Visibility) == n.Flag
+		}
+	case *search.Date:
+		if n.Field == "updated" {
+			return n.Contains(snippet.UpdatedAt)
+		}
+
+		return n.Contains(snippet.CreatedAt)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,388 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"slices"
+
+	"github.com/villaleo/cstash/internal/models"
+	"github.com/villaleo/cstash/internal/search"
+	"go.uber.org/zap"
+)
+
+// MemoryStore represents an in-memory storage solution for snippets.
+//
+// A MemoryStore created with OpenMemoryStore is durable: every change is
+// recorded in a write-ahead log before it is applied, and the log is
+// periodically compacted into a snapshot with Snapshot.
+type MemoryStore struct {
+	snippets   map[string]*models.Snippet
+	snippetsMu sync.RWMutex
+	// index is kept up to date with snippets under snippetsMu
+	index *search.Index
+	// revisions holds the revisions of every snippet, oldest first, keyed by
+	// snippet ID. It's guarded by snippetsMu.
+	revisions map[string][]*models.Revision
+	// tags indexes the snippets by tag, and tagMetadata holds the
+	// descriptions and colors of tags. Both are guarded by snippetsMu.
+	tags        tagIndex
+	tagMetadata map[tagKey]tagMetadata
+	// collections is guarded by snippetsMu, so that deleting a snippet
+	// removes it from every collection at once
+	collections map[string]*models.Collection
+	users       map[string]*models.User
+	sessions    map[string]*models.Session
+	tokens      map[string]*models.AccessToken
+	usersMu     sync.RWMutex
+	// memberships are keyed by workspace ID then user ID
+	workspaces   map[string]*models.Workspace
+	memberships  map[string]map[string]*models.Membership
+	workspacesMu sync.RWMutex
+	wal          *writeAheadLog
+	logger       *zap.Logger
+}
+
+// Ensure MemoryStore implements Store, UserStore, TokenStore, WorkspaceStore
+// and CollectionStore
+var (
+	_ Store           = (*MemoryStore)(nil)
+	_ UserStore       = (*MemoryStore)(nil)
+	_ TokenStore      = (*MemoryStore)(nil)
+	_ WorkspaceStore  = (*MemoryStore)(nil)
+	_ CollectionStore = (*MemoryStore)(nil)
+)
+
+// NewMemoryStore creates a new in-memory store
+func NewMemoryStore(logger *zap.Logger) *MemoryStore {
+	logger.Sugar().Debug("memory store initialized")
+
+	return &MemoryStore{
+		snippets:    make(map[string]*models.Snippet),
+		index:       search.NewIndex(),
+		revisions:   make(map[string][]*models.Revision),
+		tags:        make(tagIndex),
+		tagMetadata: make(map[tagKey]tagMetadata),
+		collections: make(map[string]*models.Collection),
+		users:       make(map[string]*models.User),
+		sessions:    make(map[string]*models.Session),
+		tokens:      make(map[string]*models.AccessToken),
+		workspaces:  make(map[string]*models.Workspace),
+		memberships: make(map[string]map[string]*models.Membership),
+		logger:      logger.Named("store"),
+	}
+}
+
+// OpenMemoryStore creates an in-memory store that persists its changes to a
+// write-ahead log in dir. The snippets, tags, collections, users, sessions,
+// access tokens and workspaces are rebuilt from the snapshot and log already
+// in dir, if any. Sessions that have expired since are dropped.
+func OpenMemoryStore(dir string, logger *zap.Logger) (*MemoryStore, error) {
+	wal, err := openWAL(dir)
+	if err != nil {
+		return nil, fmt.Errorf("open write-ahead log: %w", err)
+	}
+
+	state, err := wal.load()
+	if err != nil {
+		_ = wal.close()
+		return nil, fmt.Errorf("load write-ahead log: %w", err)
+	}
+
+	s := NewMemoryStore(logger)
+	s.snippets = state.snippets
+	s.revisions = state.revisions
+	s.tagMetadata = state.tags
+	s.collections = state.collections
+	s.users = state.users
+	s.tokens = state.tokens
+	s.sessions = state.sessions
+	s.workspaces = state.workspaces
+	s.memberships = state.memberships
+	s.wal = wal
+
+	for _, snippet := range state.snippets {
+		// Snippets logged before versions were tracked start at the first
+		if snippet.Version == 0 {
+			snippet.Version = 1
+		}
+
+		s.index.Add(snippet.ID, snippetDocument(snippet))
+		s.tags.add(snippet)
+	}
+
+	s.logger.Sugar().Debugw("memory store restored", "dir", dir, "count", len(state.snippets))
+
+	return s, nil
+}
+
+// Snapshot compacts the write-ahead log into a snapshot of every snippet,
+// collection, user, unexpired session, access token and workspace. It is a no-op if the store
+// isn't durable.
+func (s *MemoryStore) Snapshot() error {
+	if s.wal == nil {
+		return nil
+	}
+
+	// Hold the write lock so that no changes are appended while the log is
+	// being truncated
+	s.snippetsMu.Lock()
+	defer s.snippetsMu.Unlock()
+	s.usersMu.Lock()
+	defer s.usersMu.Unlock()
+	s.workspacesMu.Lock()
+	defer s.workspacesMu.Unlock()
+
+	snap := snapshot{Snippets: slices.Collect(maps.Values(s.snippets))}
+	for _, revisions := range s.revisions {
+		snap.Revisions = append(snap.Revisions, revisions...)
+	}
+
+	snap.Tags = slices.Collect(maps.Values(s.tagMetadata))
+	snap.Collections = slices.Collect(maps.Values(s.collections))
+
+	for _, user := range s.users {
+		snap.Users = append(snap.Users, newUserRecord(user))
+	}
+
+	for _, token := range s.tokens {
+		snap.Tokens = append(snap.Tokens, newTokenRecord(token))
+	}
+
+	for _, session := range s.sessions {
+		if !session.Expired() {
+			snap.Sessions = append(snap.Sessions, newSessionRecord(session))
+		}
+	}
+
+	snap.Workspaces = slices.Collect(maps.Values(s.workspaces))
+	for _, members := range s.memberships {
+		snap.Memberships = slices.AppendSeq(snap.Memberships, maps.Values(members))
+	}
+
+	if err := s.wal.compact(snap); err != nil {
+		return err
+	}
+
+	s.logger.Sugar().Debugw("snapshot taken", "count", len(s.snippets))
+
+	return nil
+}
+
+// Close takes a final snapshot and closes the write-ahead log. It is a no-op if
+// the store isn't durable.
+func (s *MemoryStore) Close() error {
+	if s.wal == nil {
+		return nil
+	}
+
+	if err := s.Snapshot(); err != nil {
+		return err
+	}
+
+	return s.wal.close()
+}
+
+// logChange appends entry to the write-ahead log if the store is durable
+func (s *MemoryStore) logChange(entry walEntry) error {
+	if s.wal == nil {
+		return nil
+	}
+
+	return s.wal.append(entry)
+}
+
+// CreateSnippet adds a new snippet to the store
+func (s *MemoryStore) CreateSnippet(ctx context.Context, snippet *models.Snippet) error {
+	if membership := workspaceMembership(ctx); membership != nil && !membership.Role.CanEdit() {
+		s.logger.Sugar().Debugw("caller may not create snippets in workspace", "workspace.id", membership.WorkspaceID)
+		return ErrForbidden
+	}
+
+	snippet.WorkspaceID = workspaceID(ctx)
+	snippet.Version = 1
+
+	s.snippetsMu.Lock()
+	defer s.snippetsMu.Unlock()
+
+	if _, ok := s.snippets[snippet.ID]; ok {
+		s.logger.Sugar().Debugw("snippet already exists", "snippet.id", snippet.ID)
+		return ErrSnippetExists
+	}
+
+	revision := models.NewRevision(snippet, 1, callerID(ctx))
+
+	err := s.logChange(walEntry{Op: walPut, ID: snippet.ID, Snippet: snippet, Revisions: []*models.Revision{revision}})
+	if err != nil {
+		return err
+	}
+
+	s.logger.Sugar().Debugw("snippet saved", "snippet.id", snippet.ID)
+	s.snippets[snippet.ID] = snippet
+	s.revisions[snippet.ID] = []*models.Revision{revision}
+	s.index.Add(snippet.ID, snippetDocument(snippet))
+	s.tags.add(snippet)
+
+	return nil
+}
+
+// GetSnippet retrieves a snippet by ID
+func (s *MemoryStore) GetSnippet(ctx context.Context, id string) (*models.Snippet, error) {
+	sugar := s.logger.Sugar()
+
+	s.snippetsMu.RLock()
+	defer s.snippetsMu.RUnlock()
+
+	snippet, ok := s.snippets[id]
+	if !ok || !canView(ctx, snippet) {
+		sugar.Debugw("snippet not found", "snippet.id", id)
+		return nil, ErrSnippetNotFound
+	}
+
+	sugar.Debugw("snippet retreived", "snippet.id", snippet.ID)
+
+	return snippet, nil
+}
+
+// UpdateSnippet updates an existing snippet
+func (s *MemoryStore) UpdateSnippet(ctx context.Context, id string, change func(snippet *models.Snippet) error) (*models.Snippet, error) {
+	s.logger.Sugar().Debugw("updating snippet", "snippet.id", id)
+
+	return s.updateSnippet(ctx, id, change)
+}
+
+// updateSnippet changes the snippet with id by calling change on a copy of
+// it, then records a revision of the result. The caller must not hold
+// snippetsMu, which is held while change is called.
+func (s *MemoryStore) updateSnippet(ctx context.Context, id string, change func(snippet *models.Snippet) error) (*models.Snippet, error) {
+	var (
+		sugar  = s.logger.Sugar()
+		caller = callerID(ctx)
+	)
+
+	s.snippetsMu.Lock()
+	defer s.snippetsMu.Unlock()
+
+	snippet, ok := s.snippets[id]
+	if !ok || !canView(ctx, snippet) {
+		sugar.Debugw("snippet not found", "snippet.id", id)
+		return nil, ErrSnippetNotFound
+	}
+
+	if !canEdit(ctx, snippet) {
+		sugar.Debugw("snippet not editable by caller", "snippet.id", id, "user.id", caller)
+		return nil, ErrForbidden
+	}
+
+	if err := checkVersion(ctx, snippet); err != nil {
+		sugar.Debugw("snippet version mismatch", "snippet.id", id, "version", snippet.Version)
+		return nil, err
+	}
+
+	// Apply the change to a copy so that it can be logged before it is made
+	updated := *snippet
+	updated.Tags = slices.Clone(snippet.Tags)
+	if err := change(&updated); err != nil {
+		return nil, err
+	}
+
+	updated.UpdatedAt = time.Now()
+	updated.Version++
+	revisions := newRevisions(ctx, snippet, &updated, len(s.revisions[id]))
+
+	if err := s.logChange(walEntry{Op: walPut, ID: id, Snippet: &updated, Revisions: revisions}); err != nil {
+		return nil, err
+	}
+
+	if !slices.Equal(snippet.Tags, updated.Tags) {
+		s.tags.update(snippet, &updated)
+	}
+
+	// The snippet is replaced rather than changed in place, so that callers
+	// still holding it see it as it was
+	s.snippets[id] = &updated
+	s.revisions[id] = append(s.revisions[id], revisions...)
+	s.index.Add(id, snippetDocument(&updated))
+	sugar.Debugw("snippet updated", "snippet.id", id, "revision", len(s.revisions[id]))
+
+	return &updated, nil
+}
+
+// DeleteSnippet removes a snippet from the store and from every collection
+// it's in
+func (s *MemoryStore) DeleteSnippet(ctx context.Context, id string) error {
+	var (
+		sugar  = s.logger.Sugar()
+		caller = callerID(ctx)
+	)
+
+	s.snippetsMu.Lock()
+	defer s.snippetsMu.Unlock()
+
+	snippet, ok := s.snippets[id]
+	if !ok || !canView(ctx, snippet) {
+		sugar.Debugw("snippet not found", "snippet.id", id)
+		return ErrSnippetNotFound
+	}
+
+	if !canEdit(ctx, snippet) {
+		sugar.Debugw("snippet not editable by caller", "snippet.id", id, "user.id", caller)
+		return ErrForbidden
+	}
+
+	if err := checkVersion(ctx, snippet); err != nil {
+		sugar.Debugw("snippet version mismatch", "snippet.id", id, "version", snippet.Version)
+		return err
+	}
+
+	if err := s.logChange(walEntry{Op: walDelete, ID: id}); err != nil {
+		return err
+	}
+
+	sugar.Debugw("deleted snippet", "snippet.id", id)
+	delete(s.snippets, id)
+	delete(s.revisions, id)
+	s.index.Remove(id)
+	s.tags.remove(snippet)
+	s.removeFromCollections(id)
+
+	return nil
+}
+
+// ListSnippets returns a page of the snippets visible to the caller that are
+// selected by filter
+func (s *MemoryStore) ListSnippets(ctx context.Context, filter SnippetFilter, page Page) (*SnippetPage, error) {
+	s.snippetsMu.RLock()
+	defer s.snippetsMu.RUnlock()
+
+	sugar := s.logger.Sugar()
+
+	hits, err := filterSnippets(ctx, s.candidates(ctx, filter), filter, s.index)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := paginate(hits, filter, page)
+	if err != nil {
+		return nil, err
+	}
+
+	sugar.Debugw("fetched snippets", "count", len(results.Snippets), "total", results.Total, "tags", filter.Tags)
+
+	return results, nil
+}
+
+// CountLanguages counts the snippets the caller may view in their workspace
+// by language
+func (s *MemoryStore) CountLanguages(ctx context.Context) (map[string]int, error) {
+	s.snippetsMu.RLock()
+	defer s.snippetsMu.RUnlock()
+
+	counts := countLanguages(ctx, slices.Collect(maps.Values(s.snippets)))
+	s.logger.Sugar().Debugw("counted languages", "count", len(counts))
+
+	return counts, nil
+}
@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/villaleo/cstash/internal/models"
+)
+
+// CreateCollection adds a new collection to the store
+func (s *MemoryStore) CreateCollection(ctx context.Context, collection *models.Collection) error {
+	if !canEditCollection(ctx) {
+		s.logger.Sugar().Debugw("caller may not create collections in workspace", "workspace.id", workspaceID(ctx))
+		return ErrForbidden
+	}
+
+	collection.WorkspaceID = workspaceID(ctx)
+
+	s.snippetsMu.Lock()
+	defer s.snippetsMu.Unlock()
+
+	if err := s.checkCollectionSnippets(ctx, addedSnippets(nil, collection)); err != nil {
+		return err
+	}
+
+	if err := s.logChange(walEntry{Op: walPutCollection, ID: collection.ID, Collection: collection}); err != nil {
+		return err
+	}
+
+	s.collections[collection.ID] = collection
+	s.logger.Sugar().Debugw("collection saved", "collection.id", collection.ID)
+
+	return nil
+}
+
+// GetCollection retrieves a collection by ID
+func (s *MemoryStore) GetCollection(ctx context.Context, id string) (*models.Collection, error) {
+	s.snippetsMu.RLock()
+	defer s.snippetsMu.RUnlock()
+
+	return s.visibleCollection(ctx, id)
+}
+
+// ListCollections returns every collection the caller may view in their
+// workspace, oldest first
+func (s *MemoryStore) ListCollections(ctx context.Context) ([]*models.Collection, error) {
+	s.snippetsMu.RLock()
+	defer s.snippetsMu.RUnlock()
+
+	results := []*models.Collection{}
+
+	for _, collection := range s.collections {
+		if canViewCollection(ctx, collection) {
+			results = append(results, collection)
+		}
+	}
+
+	slices.SortFunc(results, func(a, b *models.Collection) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	s.logger.Sugar().Debugw("fetched collections", "count", len(results))
+
+	return results, nil
+}
+
+// UpdateCollection changes the collection with id by calling change on a copy
+// of it
+func (s *MemoryStore) UpdateCollection(ctx context.Context, id string, change func(collection *models.Collection) error) (*models.Collection, error) {
+	s.snippetsMu.Lock()
+	defer s.snippetsMu.Unlock()
+
+	collection, err := s.editableCollection(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply the change to a copy so that it can be logged before it is made
+	updated := cloneCollection(collection)
+	if err := change(updated); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkCollectionSnippets(ctx, addedSnippets(collection, updated)); err != nil {
+		return nil, err
+	}
+
+	updated.UpdatedAt = time.Now()
+
+	if err := s.logChange(walEntry{Op: walPutCollection, ID: id, Collection: updated}); err != nil {
+		return nil, err
+	}
+
+	// The collection is replaced rather than changed in place, so that callers
+	// still holding it see it as it was
+	s.collections[id] = updated
+	s.logger.Sugar().Debugw("collection updated", "collection.id", id)
+
+	return updated, nil
+}
+
+// DeleteCollection removes a collection from the store
+func (s *MemoryStore) DeleteCollection(ctx context.Context, id string) error {
+	s.snippetsMu.Lock()
+	defer s.snippetsMu.Unlock()
+
+	if _, err := s.editableCollection(ctx, id); err != nil {
+		return err
+	}
+
+	if err := s.logChange(walEntry{Op: walDeleteCollection, ID: id}); err != nil {
+		return err
+	}
+
+	delete(s.collections, id)
+	s.logger.Sugar().Debugw("deleted collection", "collection.id", id)
+
+	return nil
+}
+
+// ListCollectionSnippets returns the snippets in the collection with id that
+// the caller may view, in order
+func (s *MemoryStore) ListCollectionSnippets(ctx context.Context, id string) ([]*models.Snippet, error) {
+	s.snippetsMu.RLock()
+	defer s.snippetsMu.RUnlock()
+
+	collection, err := s.visibleCollection(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []*models.Snippet{}
+
+	for _, snippetID := range collection.SnippetIDs {
+		if snippet, ok := s.snippets[snippetID]; ok && canView(ctx, snippet) {
+			results = append(results, snippet)
+		}
+	}
+
+	s.logger.Sugar().Debugw("fetched collection snippets", "collection.id", id, "count", len(results))
+
+	return results, nil
+}
+
+// visibleCollection returns the collection with id if the caller may view it.
+// The caller must hold snippetsMu.
+func (s *MemoryStore) visibleCollection(ctx context.Context, id string) (*models.Collection, error) {
+	collection, ok := s.collections[id]
+	if !ok || !canViewCollection(ctx, collection) {
+		s.logger.Sugar().Debugw("collection not found", "collection.id", id)
+		return nil, ErrCollectionNotFound
+	}
+
+	return collection, nil
+}
+
+// editableCollection returns the collection with id if the caller may change
+// it. The caller must hold snippetsMu.
+func (s *MemoryStore) editableCollection(ctx context.Context, id string) (*models.Collection, error) {
+	collection, err := s.visibleCollection(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canEditCollection(ctx) {
+		s.logger.Sugar().Debugw("collection not editable by caller", "collection.id", id, "user.id", callerID(ctx))
+		return nil, ErrForbidden
+	}
+
+	return collection, nil
+}
+
+// checkCollectionSnippets returns ErrSnippetNotFound unless the caller may
+// view every snippet with ids. The caller must hold snippetsMu.
+func (s *MemoryStore) checkCollectionSnippets(ctx context.Context, ids []string) error {
+	for _, id := range ids {
+		if snippet, ok := s.snippets[id]; !ok || !canView(ctx, snippet) {
+			s.logger.Sugar().Debugw("snippet not found", "snippet.id", id)
+			return ErrSnippetNotFound
+		}
+	}
+
+	return nil
+}
+
+// removeFromCollections removes the snippet with id from every collection
+// it's in. The caller must hold snippetsMu.
+func (s *MemoryStore) removeFromCollections(id string) {
+	for collectionID, collection := range s.collections {
+		if collection.Contains(id) {
+			updated := cloneCollection(collection)
+			updated.Remove(id)
+			s.collections[collectionID] = updated
+		}
+	}
+}
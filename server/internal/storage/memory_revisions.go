@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"slices"
+
+	"github.com/villaleo/cstash/internal/models"
+)
+
+// ListRevisions returns every revision of the snippet with id, newest first
+func (s *MemoryStore) ListRevisions(ctx context.Context, id string) ([]*models.Revision, error) {
+	s.snippetsMu.RLock()
+	defer s.snippetsMu.RUnlock()
+
+	snippet, ok := s.snippets[id]
+	if !ok || !canView(ctx, snippet) {
+		s.logger.Sugar().Debugw("snippet not found", "snippet.id", id)
+		return nil, ErrSnippetNotFound
+	}
+
+	results := slices.Clone(s.revisions[id])
+	slices.Reverse(results)
+
+	return results, nil
+}
+
+// GetRevision retrieves revision number of the snippet with id
+func (s *MemoryStore) GetRevision(ctx context.Context, id string, number int) (*models.Revision, error) {
+	s.snippetsMu.RLock()
+	defer s.snippetsMu.RUnlock()
+
+	snippet, ok := s.snippets[id]
+	if !ok || !canView(ctx, snippet) {
+		s.logger.Sugar().Debugw("snippet not found", "snippet.id", id)
+		return nil, ErrSnippetNotFound
+	}
+
+	return s.revision(id, number)
+}
+
+// RestoreRevision sets the fields of the snippet with id back to those of its
+// revision number
+func (s *MemoryStore) RestoreRevision(ctx context.Context, id string, number int) (*models.Snippet, error) {
+	s.logger.Sugar().Debugw("restoring snippet", "snippet.id", id, "revision", number)
+
+	return s.updateSnippet(ctx, id, func(snippet *models.Snippet) error {
+		revision, err := s.revision(id, number)
+		if err != nil {
+			return err
+		}
+
+		revision.Restore(snippet)
+
+		return nil
+	})
+}
+
+// revision returns revision number of the snippet with id. The caller must
+// hold snippetsMu.
+func (s *MemoryStore) revision(id string, number int) (*models.Revision, error) {
+	revisions := s.revisions[id]
+	if number < 1 || number > len(revisions) {
+		return nil, ErrRevisionNotFound
+	}
+
+	return revisions[number-1], nil
+}
@@ -0,0 +1,304 @@
+package storage
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"github.com/villaleo/cstash/internal/models"
+)
+
+// tagIndex maps every tag to the IDs of the snippets tagged with it, keyed by
+// workspace ID then tag. Personal snippets are in the workspace with an empty
+// ID. A tag is in the index exactly while at least one snippet has it, so the
+// number of IDs is the tag's reference count.
+type tagIndex map[string]map[string]map[string]struct{}
+
+// add indexes the tags of snippet. Tags repeated on the snippet are indexed
+// once.
+func (x tagIndex) add(snippet *models.Snippet) {
+	if len(snippet.Tags) == 0 {
+		return
+	}
+
+	tags := x[snippet.WorkspaceID]
+	if tags == nil {
+		tags = make(map[string]map[string]struct{})
+		x[snippet.WorkspaceID] = tags
+	}
+
+	for _, tag := range snippet.Tags {
+		if tags[tag] == nil {
+			tags[tag] = make(map[string]struct{})
+		}
+
+		tags[tag][snippet.ID] = struct{}{}
+	}
+}
+
+// remove removes the tags of snippet from the index, dropping the tags no
+// other snippet has
+func (x tagIndex) remove(snippet *models.Snippet) {
+	tags := x[snippet.WorkspaceID]
+
+	for _, tag := range snippet.Tags {
+		delete(tags[tag], snippet.ID)
+
+		if len(tags[tag]) == 0 {
+			delete(tags, tag)
+		}
+	}
+
+	if len(tags) == 0 {
+		delete(x, snippet.WorkspaceID)
+	}
+}
+
+// update reindexes a snippet whose tags changed from those of old to those of
+// updated
+func (x tagIndex) update(old, updated *models.Snippet) {
+	x.remove(old)
+	x.add(updated)
+}
+
+// snippetIDs returns the IDs of the snippets in the workspace with
+// workspaceID that have any of names or a tag below one
+func (x tagIndex) snippetIDs(workspaceID string, names []string) map[string]struct{} {
+	ids := make(map[string]struct{})
+
+	for tag, tagged := range x[workspaceID] {
+		if slices.ContainsFunc(names, func(name string) bool { return models.TagMatches(tag, name) }) {
+			maps.Copy(ids, tagged)
+		}
+	}
+
+	return ids
+}
+
+// snippetIDsWithAll returns the IDs of the snippets in the workspace with
+// workspaceID that have every one of names or a tag below it. The sets of IDs
+// are intersected smallest first, so the result never grows past the
+// smallest.
+func (x tagIndex) snippetIDsWithAll(workspaceID string, names []string) map[string]struct{} {
+	sets := make([]map[string]struct{}, len(names))
+	for i, name := range names {
+		sets[i] = x.snippetIDs(workspaceID, []string{name})
+	}
+
+	slices.SortFunc(sets, func(a, b map[string]struct{}) int { return len(a) - len(b) })
+
+	ids := sets[0]
+	for _, set := range sets[1:] {
+		maps.DeleteFunc(ids, func(id string, _ struct{}) bool {
+			_, ok := set[id]
+			return !ok
+		})
+	}
+
+	return ids
+}
+
+// ListTags fetches every tag of the snippets the caller may view in their
+// workspace, ordered by name
+func (s *MemoryStore) ListTags(ctx context.Context) ([]models.Tag, error) {
+	s.snippetsMu.RLock()
+	defer s.snippetsMu.RUnlock()
+
+	results := describeTags(countTags(ctx, s.taggedSnippets(ctx)), s.visibleTagMetadata(ctx))
+	s.logger.Sugar().Debugw("fetched tags", "count", len(results))
+
+	return results, nil
+}
+
+// TagTree fetches the hierarchy of the tags of the snippets the caller may
+// view in their workspace
+func (s *MemoryStore) TagTree(ctx context.Context) ([]*models.TagNode, error) {
+	s.snippetsMu.RLock()
+	defer s.snippetsMu.RUnlock()
+
+	results := tagTree(ctx, s.taggedSnippets(ctx), s.visibleTagMetadata(ctx))
+	s.logger.Sugar().Debugw("fetched tag tree", "count", len(results))
+
+	return results, nil
+}
+
+// taggedSnippets returns the snippets in the caller's workspace that have any
+// tag. The caller must hold snippetsMu.
+func (s *MemoryStore) taggedSnippets(ctx context.Context) []*models.Snippet {
+	ids := make(map[string]struct{})
+	for _, tagged := range s.tags[workspaceID(ctx)] {
+		maps.Copy(ids, tagged)
+	}
+
+	snippets := make([]*models.Snippet, 0, len(ids))
+	for id := range ids {
+		snippets = append(snippets, s.snippets[id])
+	}
+
+	return snippets
+}
+
+// UpdateTag renames the tag called name and sets its description and color
+func (s *MemoryStore) UpdateTag(ctx context.Context, name string, tag models.Tag) (*models.Tag, error) {
+	s.snippetsMu.Lock()
+	defer s.snippetsMu.Unlock()
+
+	change, err := s.tagPlanner(ctx).updateTag(name, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.applyTagChange(ctx, change); err != nil {
+		return nil, err
+	}
+
+	s.logger.Sugar().Debugw("tag updated", "tag", name, "name", tag.Name, "count", len(change.snippets))
+
+	return change.tag, nil
+}
+
+// MergeTags replaces the tags called sources with target
+func (s *MemoryStore) MergeTags(ctx context.Context, sources []string, target string) (*models.Tag, error) {
+	s.snippetsMu.Lock()
+	defer s.snippetsMu.Unlock()
+
+	change, err := s.tagPlanner(ctx).mergeTags(sources, target)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.applyTagChange(ctx, change); err != nil {
+		return nil, err
+	}
+
+	s.logger.Sugar().Debugw("tags merged", "sources", sources, "target", target, "count", len(change.snippets))
+
+	return change.tag, nil
+}
+
+// DeleteTag removes the tag called name from every snippet
+func (s *MemoryStore) DeleteTag(ctx context.Context, name string) error {
+	s.snippetsMu.Lock()
+	defer s.snippetsMu.Unlock()
+
+	change, err := s.tagPlanner(ctx).deleteTag(name)
+	if err != nil {
+		return err
+	}
+
+	if err := s.applyTagChange(ctx, change); err != nil {
+		return err
+	}
+
+	s.logger.Sugar().Debugw("tag deleted", "tag", name, "count", len(change.snippets))
+
+	return nil
+}
+
+// tagPlanner returns a planner of tag changes in the caller's workspace. The
+// caller must hold snippetsMu.
+func (s *MemoryStore) tagPlanner(ctx context.Context) *tagPlanner {
+	snippets := []*models.Snippet{}
+
+	for _, snippet := range s.snippets {
+		if snippet.WorkspaceID == workspaceID(ctx) {
+			snippets = append(snippets, snippet)
+		}
+	}
+
+	return &tagPlanner{ctx: ctx, snippets: snippets, metadata: s.visibleTagMetadata(ctx)}
+}
+
+// visibleTagMetadata returns the metadata of the tags the caller sees, by
+// name. The caller must hold snippetsMu.
+func (s *MemoryStore) visibleTagMetadata(ctx context.Context) map[string]tagMetadata {
+	var (
+		visible = make(map[string]tagMetadata)
+		scope   = newTagMetadata(ctx, "", "", "")
+	)
+
+	for key, metadata := range s.tagMetadata {
+		if key.workspaceID == scope.WorkspaceID && key.ownerID == scope.OwnerID {
+			visible[key.name] = metadata
+		}
+	}
+
+	return visible
+}
+
+// applyTagChange logs change as a single entry, then applies it to the
+// snippets, their revisions, the tag index and the tag metadata. The caller
+// must hold snippetsMu.
+func (s *MemoryStore) applyTagChange(ctx context.Context, change *tagChange) error {
+	var (
+		snippets  = make([]*models.Snippet, len(change.snippets))
+		revisions = make(map[string][]*models.Revision, len(change.snippets))
+		logged    []*models.Revision
+	)
+
+	for i, c := range change.snippets {
+		snippets[i] = c.after
+		revisions[c.after.ID] = newRevisions(ctx, c.before, c.after, len(s.revisions[c.after.ID]))
+		logged = append(logged, revisions[c.after.ID]...)
+	}
+
+	err := s.logChange(walEntry{Op: walPutTags, Snippets: snippets, Revisions: logged, Tags: change.metadata})
+	if err != nil {
+		return err
+	}
+
+	// Snippets are replaced rather than changed in place, so that callers
+	// still holding them see them as they were
+	for _, c := range change.snippets {
+		s.tags.update(c.before, c.after)
+		s.snippets[c.after.ID] = c.after
+		s.revisions[c.after.ID] = append(s.revisions[c.after.ID], revisions[c.after.ID]...)
+	}
+
+	for _, metadata := range change.metadata {
+		if metadata.empty() {
+			delete(s.tagMetadata, metadata.key())
+		} else {
+			s.tagMetadata[metadata.key()] = metadata
+		}
+	}
+
+	return nil
+}
+
+// candidates returns the snippets ListSnippets needs to check against filter:
+// those with the tags it selects and without those it excludes, worked out
+// from the tag index. The caller must hold snippetsMu.
+func (s *MemoryStore) candidates(ctx context.Context, filter SnippetFilter) []*models.Snippet {
+	var (
+		wsID     = workspaceID(ctx)
+		excluded = s.tags.snippetIDs(wsID, filter.NotTags)
+		snippets []*models.Snippet
+	)
+
+	if len(filter.Tags) == 0 {
+		for id, snippet := range s.snippets {
+			if _, ok := excluded[id]; !ok {
+				snippets = append(snippets, snippet)
+			}
+		}
+
+		return snippets
+	}
+
+	var ids map[string]struct{}
+
+	if filter.TagMode == TagModeAll {
+		ids = s.tags.snippetIDsWithAll(wsID, filter.Tags)
+	} else {
+		ids = s.tags.snippetIDs(wsID, filter.Tags)
+	}
+
+	for id := range ids {
+		if _, ok := excluded[id]; !ok {
+			snippets = append(snippets, s.snippets[id])
+		}
+	}
+
+	return snippets
+}
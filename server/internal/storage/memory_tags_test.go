@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/villaleo/cstash/internal/auth"
+	"github.com/villaleo/cstash/internal/models"
+	"go.uber.org/zap"
+)
+
+// Tags and names the tag index tests pick from. They overlap by prefix, case
+// and wildcard characters so that matching below a tag is exercised.
+var (
+	testTags       = []string{"lang", "lang/go", "lang/go/std", "lang/rust", "language", "Lang/Go", "db", "db/sql", "x_y", "xay"}
+	testTagNames   = []string{"lang", "LANG", "lang/go", "lang/go/std", "language", "db", "db/sql", "x_y", "missing"}
+	testWorkspaces = []string{"", "ws"}
+)
+
+// tagOps decodes data into operations on a tag index and checks the index
+// against a brute-force scan of the snippets after each one
+type tagOps struct {
+	data     []byte
+	index    tagIndex
+	snippets map[string]*models.Snippet
+}
+
+// next returns the next byte of data modulo n, or zero once data runs out
+func (o *tagOps) next(n int) int {
+	if len(o.data) == 0 {
+		return 0
+	}
+
+	b := o.data[0]
+	o.data = o.data[1:]
+
+	return int(b) % n
+}
+
+// tags returns up to three tags picked from testTags, possibly repeated
+func (o *tagOps) tags() []string {
+	tags := []string{}
+	for range o.next(4) {
+		tags = append(tags, testTags[o.next(len(testTags))])
+	}
+
+	return tags
+}
+
+// names returns one to three names picked from testTagNames
+func (o *tagOps) names() []string {
+	names := []string{}
+	for range o.next(3) + 1 {
+		names = append(names, testTagNames[o.next(len(testTagNames))])
+	}
+
+	return names
+}
+
+// run applies every operation held by data
+func (o *tagOps) run(t *testing.T) {
+	t.Helper()
+
+	for len(o.data) > 0 {
+		var (
+			op      = o.next(3)
+			id      = fmt.Sprintf("s%d", o.next(8))
+			old, ok = o.snippets[id]
+			snippet = &models.Snippet{ID: id, WorkspaceID: testWorkspaces[o.next(len(testWorkspaces))], Tags: o.tags()}
+		)
+
+		switch {
+		case op == 0 && ok:
+			o.index.remove(old)
+			delete(o.snippets, id)
+		case ok:
+			// A snippet's workspace never changes
+			snippet.WorkspaceID = old.WorkspaceID
+			o.index.update(old, snippet)
+			o.snippets[id] = snippet
+		default:
+			o.index.add(snippet)
+			o.snippets[id] = snippet
+		}
+
+		checkTagIndex(t, o.index, o.snippets)
+
+		names := o.names()
+		for _, workspaceID := range testWorkspaces {
+			if got, want := o.index.snippetIDs(workspaceID, names), bruteForceTags(o.snippets, workspaceID, names, false); !maps.Equal(got, want) {
+				t.Fatalf("snippetIDs(%q, %q) = %v, want %v", workspaceID, names, slices.Sorted(maps.Keys(got)), slices.Sorted(maps.Keys(want)))
+			}
+
+			if got, want := o.index.snippetIDsWithAll(workspaceID, names), bruteForceTags(o.snippets, workspaceID, names, true); !maps.Equal(got, want) {
+				t.Fatalf("snippetIDsWithAll(%q, %q) = %v, want %v", workspaceID, names, slices.Sorted(maps.Keys(got)), slices.Sorted(maps.Keys(want)))
+			}
+		}
+	}
+}
+
+// bruteForceTags returns the IDs of the snippets in the workspace with
+// workspaceID that have any of names, or every one of them if all is true, by
+// checking every snippet
+func bruteForceTags(snippets map[string]*models.Snippet, workspaceID string, names []string, all bool) map[string]struct{} {
+	ids := make(map[string]struct{})
+
+	for id, snippet := range snippets {
+		if snippet.WorkspaceID != workspaceID {
+			continue
+		}
+
+		has := func(name string) bool {
+			return slices.ContainsFunc(snippet.Tags, func(tag string) bool { return models.TagMatches(tag, name) })
+		}
+
+		if all && !slices.ContainsFunc(names, func(name string) bool { return !has(name) }) ||
+			!all && slices.ContainsFunc(names, has) {
+			ids[id] = struct{}{}
+		}
+	}
+
+	return ids
+}
+
+// checkTagIndex fails t unless index holds exactly the tags of snippets, with
+// no tag left behind that no snippet has
+func checkTagIndex(t *testing.T, index tagIndex, snippets map[string]*models.Snippet) {
+	t.Helper()
+
+	want := make(tagIndex)
+	for _, snippet := range snippets {
+		want.add(snippet)
+	}
+
+	if !maps.EqualFunc(index, want, func(a, b map[string]map[string]struct{}) bool {
+		return maps.EqualFunc(a, b, maps.Equal)
+	}) {
+		t.Fatalf("tag index = %v, want %v", index, want)
+	}
+}
+
+func TestTagIndexMatchesScan(t *testing.T) {
+	for seed := range uint64(200) {
+		rng := rand.New(rand.NewPCG(seed, seed))
+
+		data := make([]byte, 256)
+		for i := range data {
+			data[i] = byte(rng.UintN(256))
+		}
+
+		t.Run(fmt.Sprint(seed), func(t *testing.T) {
+			ops := &tagOps{data: data, index: make(tagIndex), snippets: make(map[string]*models.Snippet)}
+			ops.run(t)
+		})
+	}
+}
+
+func FuzzTagIndex(f *testing.F) {
+	f.Add([]byte{1, 0, 0, 3, 1, 2, 3, 0, 1, 1})
+	f.Add([]byte{1, 2, 1, 2, 0, 1, 0, 2, 1, 0, 0, 2, 0, 2})
+	f.Add([]byte{2, 5, 0, 3, 4, 5, 6, 2, 7, 8, 2, 5, 1, 1, 9, 0, 5})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ops := &tagOps{data: data, index: make(tagIndex), snippets: make(map[string]*models.Snippet)}
+		ops.run(t)
+	})
+}
+
+func TestMemoryStoreTagsConcurrently(t *testing.T) {
+	var (
+		store = NewMemoryStore(zap.NewNop())
+		ctx   = auth.WithIdentity(context.Background(), auth.Identity{UserID: "alice"})
+		wg    sync.WaitGroup
+	)
+
+	for worker := range 8 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			rng := rand.New(rand.NewPCG(uint64(worker), 0))
+			pick := func() []string {
+				return []string{testTags[rng.IntN(len(testTags))], testTags[rng.IntN(len(testTags))]}
+			}
+
+			for range 50 {
+				snippet := models.NewSnippet("title", "content", "go")
+				snippet.Tags = pick()
+
+				if err := store.CreateSnippet(ctx, snippet); err != nil {
+					t.Error(err)
+					return
+				}
+
+				_, err := store.UpdateSnippet(ctx, snippet.ID, func(snippet *models.Snippet) error {
+					snippet.Tags = pick()
+					return nil
+				})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				filter := SnippetFilter{Tags: pick(), TagMode: TagModeAll, NotTags: pick()[:1]}
+				if _, err := store.ListSnippets(ctx, filter, Page{}); err != nil {
+					t.Error(err)
+					return
+				}
+
+				if _, err := store.ListTags(ctx); err != nil {
+					t.Error(err)
+					return
+				}
+
+				if rng.IntN(2) == 0 {
+					if err := store.DeleteSnippet(ctx, snippet.ID); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	store.snippetsMu.RLock()
+	defer store.snippetsMu.RUnlock()
+
+	checkTagIndex(t, store.tags, store.snippets)
+}
@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"context"
+	"slices"
+
+	"github.com/villaleo/cstash/internal/models"
+)
+
+// CreateUser adds a new user to the store
+func (s *MemoryStore) CreateUser(_ context.Context, user *models.User) error {
+	s.usersMu.Lock()
+	defer s.usersMu.Unlock()
+
+	for _, existing := range s.users {
+		if existing.Username == user.Username || (user.ExternalID != "" && existing.ExternalID == user.ExternalID) {
+			s.logger.Sugar().Debugw("username or external ID already taken", "user.username", user.Username)
+			return ErrUserExists
+		}
+	}
+
+	if err := s.logChange(walEntry{Op: walPutUser, ID: user.ID, User: newUserRecord(user)}); err != nil {
+		return err
+	}
+
+	s.users[user.ID] = user
+	s.logger.Sugar().Debugw("user saved", "user.id", user.ID)
+
+	return nil
+}
+
+// GetUser retrieves a user by ID
+func (s *MemoryStore) GetUser(_ context.Context, id string) (*models.User, error) {
+	s.usersMu.RLock()
+	defer s.usersMu.RUnlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+// GetUserByUsername retrieves a user by username
+func (s *MemoryStore) GetUserByUsername(_ context.Context, username string) (*models.User, error) {
+	s.usersMu.RLock()
+	defer s.usersMu.RUnlock()
+
+	for _, user := range s.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+
+	return nil, ErrUserNotFound
+}
+
+// GetUserByExternalID retrieves a user by their single sign-on identifier
+func (s *MemoryStore) GetUserByExternalID(_ context.Context, externalID string) (*models.User, error) {
+	s.usersMu.RLock()
+	defer s.usersMu.RUnlock()
+
+	for _, user := range s.users {
+		if externalID != "" && user.ExternalID == externalID {
+			return user, nil
+		}
+	}
+
+	return nil, ErrUserNotFound
+}
+
+// CreateSession adds a new session to the store
+func (s *MemoryStore) CreateSession(_ context.Context, session *models.Session) error {
+	s.usersMu.Lock()
+	defer s.usersMu.Unlock()
+
+	entry := walEntry{Op: walPutSession, ID: session.TokenHash, Session: newSessionRecord(session)}
+	if err := s.logChange(entry); err != nil {
+		return err
+	}
+
+	s.sessions[session.TokenHash] = session
+	s.logger.Sugar().Debugw("session saved", "user.id", session.UserID)
+
+	return nil
+}
+
+// GetSession retrieves an unexpired session by token hash. Expired sessions are
+// removed from the store.
+func (s *MemoryStore) GetSession(_ context.Context, tokenHash string) (*models.Session, error) {
+	s.usersMu.Lock()
+	defer s.usersMu.Unlock()
+
+	session, ok := s.sessions[tokenHash]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+
+	if session.Expired() {
+		delete(s.sessions, tokenHash)
+		return nil, ErrSessionNotFound
+	}
+
+	return session, nil
+}
+
+// DeleteSession removes a session from the store
+func (s *MemoryStore) DeleteSession(_ context.Context, tokenHash string) error {
+	s.usersMu.Lock()
+	defer s.usersMu.Unlock()
+
+	if _, ok := s.sessions[tokenHash]; !ok {
+		return ErrSessionNotFound
+	}
+
+	if err := s.logChange(walEntry{Op: walDeleteSession, ID: tokenHash}); err != nil {
+		return err
+	}
+
+	delete(s.sessions, tokenHash)
+
+	return nil
+}
+
+// CreateAccessToken adds a new access token to the store
+func (s *MemoryStore) CreateAccessToken(_ context.Context, token *models.AccessToken) error {
+	s.usersMu.Lock()
+	defer s.usersMu.Unlock()
+
+	if err := s.logChange(walEntry{Op: walPutAccessToken, ID: token.ID, Token: newTokenRecord(token)}); err != nil {
+		return err
+	}
+
+	s.tokens[token.TokenHash] = token
+	s.logger.Sugar().Debugw("access token saved", "token.id", token.ID, "user.id", token.UserID)
+
+	return nil
+}
+
+// GetAccessToken retrieves an access token by hash
+func (s *MemoryStore) GetAccessToken(_ context.Context, tokenHash string) (*models.AccessToken, error) {
+	s.usersMu.RLock()
+	defer s.usersMu.RUnlock()
+
+	token, ok := s.tokens[tokenHash]
+	if !ok {
+		return nil, ErrAccessTokenNotFound
+	}
+
+	return token, nil
+}
+
+// ListAccessTokens returns every access token owned by the user with userID,
+// oldest first
+func (s *MemoryStore) ListAccessTokens(_ context.Context, userID string) ([]*models.AccessToken, error) {
+	s.usersMu.RLock()
+	defer s.usersMu.RUnlock()
+
+	results := []*models.AccessToken{}
+
+	for _, token := range s.tokens {
+		if token.UserID == userID {
+			results = append(results, token)
+		}
+	}
+
+	slices.SortFunc(results, func(a, b *models.AccessToken) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return results, nil
+}
+
+// DeleteAccessToken revokes the access token with id owned by the user with
+// userID
+func (s *MemoryStore) DeleteAccessToken(_ context.Context, userID, id string) error {
+	s.usersMu.Lock()
+	defer s.usersMu.Unlock()
+
+	for hash, token := range s.tokens {
+		if token.ID != id || token.UserID != userID {
+			continue
+		}
+
+		if err := s.logChange(walEntry{Op: walDeleteAccessToken, ID: hash}); err != nil {
+			return err
+		}
+
+		delete(s.tokens, hash)
+		s.logger.Sugar().Debugw("access token revoked", "token.id", id, "user.id", userID)
+
+		return nil
+	}
+
+	return ErrAccessTokenNotFound
+}
@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/villaleo/cstash/internal/models"
+)
+
+// CreateWorkspace adds a new workspace to the store, owned by the user with
+// ownerID
+func (s *MemoryStore) CreateWorkspace(_ context.Context, workspace *models.Workspace, ownerID string) error {
+	s.workspacesMu.Lock()
+	defer s.workspacesMu.Unlock()
+
+	owner := &models.Membership{
+		WorkspaceID: workspace.ID,
+		UserID:      ownerID,
+		Role:        models.RoleOwner,
+		CreatedAt:   workspace.CreatedAt,
+	}
+
+	// The owner is logged along with the workspace, so that a crash can't
+	// leave a workspace without an owner
+	err := s.logChange(walEntry{Op: walPutWorkspace, ID: workspace.ID, Workspace: workspace, Membership: owner})
+	if err != nil {
+		return err
+	}
+
+	s.workspaces[workspace.ID] = workspace
+	s.memberships[workspace.ID] = map[string]*models.Membership{ownerID: owner}
+	s.logger.Sugar().Debugw("workspace saved", "workspace.id", workspace.ID)
+
+	return nil
+}
+
+// GetWorkspace retrieves a workspace by ID
+func (s *MemoryStore) GetWorkspace(_ context.Context, id string) (*models.Workspace, error) {
+	s.workspacesMu.RLock()
+	defer s.workspacesMu.RUnlock()
+
+	workspace, ok := s.workspaces[id]
+	if !ok {
+		return nil, ErrWorkspaceNotFound
+	}
+
+	return workspace, nil
+}
+
+// ListWorkspaces returns every workspace the user with userID is a member of,
+// oldest first
+func (s *MemoryStore) ListWorkspaces(_ context.Context, userID string) ([]*models.Workspace, error) {
+	s.workspacesMu.RLock()
+	defer s.workspacesMu.RUnlock()
+
+	results := []*models.Workspace{}
+
+	for id, members := range s.memberships {
+		if _, ok := members[userID]; ok {
+			results = append(results, s.workspaces[id])
+		}
+	}
+
+	slices.SortFunc(results, func(a, b *models.Workspace) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return results, nil
+}
+
+// DeleteWorkspace removes a workspace from the store, along with its
+// memberships, snippets, tags and collections
+func (s *MemoryStore) DeleteWorkspace(_ context.Context, id string) error {
+	s.snippetsMu.Lock()
+	defer s.snippetsMu.Unlock()
+	s.workspacesMu.Lock()
+	defer s.workspacesMu.Unlock()
+
+	if _, ok := s.workspaces[id]; !ok {
+		return ErrWorkspaceNotFound
+	}
+
+	if err := s.logChange(walEntry{Op: walDeleteWorkspace, ID: id}); err != nil {
+		return err
+	}
+
+	maps.DeleteFunc(s.snippets, func(snippetID string, snippet *models.Snippet) bool {
+		if snippet.WorkspaceID != id {
+			return false
+		}
+
+		s.index.Remove(snippetID)
+		delete(s.revisions, snippetID)
+
+		return true
+	})
+
+	delete(s.tags, id)
+	maps.DeleteFunc(s.collections, func(_ string, collection *models.Collection) bool {
+		return collection.WorkspaceID == id
+	})
+	maps.DeleteFunc(s.tagMetadata, func(key tagKey, _ tagMetadata) bool {
+		return key.workspaceID == id
+	})
+
+	delete(s.workspaces, id)
+	delete(s.memberships, id)
+	s.logger.Sugar().Debugw("deleted workspace", "workspace.id", id)
+
+	return nil
+}
+
+// GetMembership retrieves the membership of the user with userID in the
+// workspace with workspaceID
+func (s *MemoryStore) GetMembership(_ context.Context, workspaceID, userID string) (*models.Membership, error) {
+	s.workspacesMu.RLock()
+	defer s.workspacesMu.RUnlock()
+
+	membership, ok := s.memberships[workspaceID][userID]
+	if !ok {
+		return nil, ErrMembershipNotFound
+	}
+
+	return membership, nil
+}
+
+// ListMemberships returns every membership of the workspace with workspaceID,
+// oldest first
+func (s *MemoryStore) ListMemberships(_ context.Context, workspaceID string) ([]*models.Membership, error) {
+	s.workspacesMu.RLock()
+	defer s.workspacesMu.RUnlock()
+
+	if _, ok := s.workspaces[workspaceID]; !ok {
+		return nil, ErrWorkspaceNotFound
+	}
+
+	results := slices.SortedFunc(maps.Values(s.memberships[workspaceID]), func(a, b *models.Membership) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return results, nil
+}
+
+// PutMembership adds a member to a workspace or changes their role
+func (s *MemoryStore) PutMembership(_ context.Context, membership *models.Membership) error {
+	s.workspacesMu.Lock()
+	defer s.workspacesMu.Unlock()
+
+	if _, ok := s.workspaces[membership.WorkspaceID]; !ok {
+		return ErrWorkspaceNotFound
+	}
+
+	members := s.memberships[membership.WorkspaceID]
+
+	if existing, ok := members[membership.UserID]; ok {
+		if membership.Role != models.RoleOwner && s.isLastOwner(existing) {
+			return ErrLastOwner
+		}
+
+		// Members keep the time they joined when their role changes
+		membership.CreatedAt = existing.CreatedAt
+	}
+
+	if membership.CreatedAt.IsZero() {
+		membership.CreatedAt = time.Now()
+	}
+
+	if err := s.logChange(walEntry{Op: walPutMembership, ID: membership.WorkspaceID, Membership: membership}); err != nil {
+		return err
+	}
+
+	members[membership.UserID] = membership
+	s.logger.Sugar().Debugw("membership saved", "workspace.id", membership.WorkspaceID, "user.id", membership.UserID)
+
+	return nil
+}
+
+// DeleteMembership removes the user with userID from the workspace with
+// workspaceID
+func (s *MemoryStore) DeleteMembership(_ context.Context, workspaceID, userID string) error {
+	s.workspacesMu.Lock()
+	defer s.workspacesMu.Unlock()
+
+	membership, ok := s.memberships[workspaceID][userID]
+	if !ok {
+		return ErrMembershipNotFound
+	}
+
+	if s.isLastOwner(membership) {
+		return ErrLastOwner
+	}
+
+	if err := s.logChange(walEntry{Op: walDeleteMembership, ID: workspaceID, Membership: membership}); err != nil {
+		return err
+	}
+
+	delete(s.memberships[workspaceID], userID)
+	s.logger.Sugar().Debugw("deleted membership", "workspace.id", workspaceID, "user.id", userID)
+
+	return nil
+}
+
+// isLastOwner reports whether membership is the only owner of its workspace.
+// The caller must hold workspacesMu.
+func (s *MemoryStore) isLastOwner(membership *models.Membership) bool {
+	if membership.Role != models.RoleOwner {
+		return false
+	}
+
+	for userID, other := range s.memberships[membership.WorkspaceID] {
+		if userID != membership.UserID && other.Role == models.RoleOwner {
+			return false
+		}
+	}
+
+	return true
+}
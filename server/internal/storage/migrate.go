@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// migration is a single versioned schema change
+type migration struct {
+	version int
+	name    string
+	query   string
+}
+
+// migrate applies every migration in dir that has not been applied to db yet.
+//
+// Migrations are files named <version>_<description>.sql. They are applied in
+// version order, each inside its own transaction, and recorded in the
+// schema_migrations table.
+func migrate(ctx context.Context, db *sql.DB, fsys fs.FS, dir string, logger *zap.Logger) error {
+	sugar := logger.Sugar()
+
+	migrations, err := loadMigrations(fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL
+		)`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var current int
+
+	err = db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current)
+	if err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		if err := applyMigration(ctx, db, m); err != nil {
+			return fmt.Errorf("apply migration %s: %w", m.name, err)
+		}
+
+		sugar.Infow("applied migration", "version", m.version, "name", m.name)
+	}
+
+	return nil
+}
+
+// applyMigration runs m and records it in a single transaction
+func applyMigration(ctx context.Context, db *sql.DB, m migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, m.query); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)",
+		m.version, m.name, time.Now().UTC(),
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// loadMigrations reads every migration in dir, sorted by version
+func loadMigrations(fsys fs.FS, dir string) ([]migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]migration, 0, len(entries))
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || path.Ext(name) != ".sql" {
+			continue
+		}
+
+		prefix, _, ok := strings.Cut(name, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: missing version prefix", name)
+		}
+
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", name, err)
+		}
+
+		query, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		migrations = append(migrations, migration{
+			version: version,
+			name:    strings.TrimSuffix(name, ".sql"),
+			query:   string(query),
+		})
+	}
+
+	slices.SortFunc(migrations, func(a, b migration) int {
+		return a.version - b.version
+	})
+
+	return migrations, nil
+}
@@ -0,0 +1,24 @@
+CREATE TABLE snippets (
+    id          TEXT PRIMARY KEY,
+    title       TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    content     TEXT NOT NULL DEFAULT '',
+    language    TEXT NOT NULL DEFAULT '',
+    is_favorite BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at  TIMESTAMPTZ NOT NULL,
+    updated_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE tags (
+    id   BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE snippet_tags (
+    snippet_id TEXT NOT NULL REFERENCES snippets (id) ON DELETE CASCADE,
+    tag_id     BIGINT NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    position   INTEGER NOT NULL,
+    PRIMARY KEY (snippet_id, tag_id)
+);
+
+CREATE INDEX snippet_tags_tag_id ON snippet_tags (tag_id);
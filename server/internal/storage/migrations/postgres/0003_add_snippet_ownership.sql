@@ -0,0 +1,5 @@
+-- Snippets created before ownership have no owner and stay visible to everyone
+ALTER TABLE snippets ADD COLUMN owner_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE snippets ADD COLUMN visibility TEXT NOT NULL DEFAULT 'team';
+
+CREATE INDEX snippets_owner_id ON snippets (owner_id);
@@ -0,0 +1,11 @@
+CREATE TABLE access_tokens (
+    id         TEXT PRIMARY KEY,
+    user_id    TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name       TEXT NOT NULL,
+    -- Space-separated list of granted scopes
+    scopes     TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX access_tokens_user_id ON access_tokens (user_id);
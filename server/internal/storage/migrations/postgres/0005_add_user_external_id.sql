@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN external_id TEXT NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX users_external_id ON users (external_id) WHERE external_id <> '';
@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,11 @@
+-- Tags are shared by every workspace in the tags table, so their metadata is
+-- kept per workspace here. Personal tags are described by each user, so their
+-- owner_id is set; it's empty for workspace tags.
+CREATE TABLE tag_metadata (
+    workspace_id TEXT NOT NULL DEFAULT '',
+    owner_id     TEXT NOT NULL DEFAULT '',
+    name         TEXT NOT NULL,
+    description  TEXT NOT NULL DEFAULT '',
+    color        TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (workspace_id, owner_id, name)
+);
@@ -0,0 +1,21 @@
+CREATE TABLE workspaces (
+    id         TEXT PRIMARY KEY,
+    name       TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE memberships (
+    workspace_id TEXT NOT NULL REFERENCES workspaces (id) ON DELETE CASCADE,
+    user_id      TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role         TEXT NOT NULL,
+    created_at   TIMESTAMP NOT NULL,
+    PRIMARY KEY (workspace_id, user_id)
+);
+
+CREATE INDEX memberships_user_id ON memberships (user_id);
+
+-- Personal snippets have an empty workspace, so the column can't reference
+-- workspaces; DeleteWorkspace removes a workspace's snippets itself
+ALTER TABLE snippets ADD COLUMN workspace_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX snippets_workspace_id ON snippets (workspace_id);
@@ -0,0 +1,16 @@
+-- Revisions are immutable copies of a snippet's fields, so their tags are
+-- stored as a JSON array rather than in snippet_tags
+CREATE TABLE snippet_revisions (
+    snippet_id  TEXT NOT NULL REFERENCES snippets (id) ON DELETE CASCADE,
+    number      INTEGER NOT NULL,
+    author_id   TEXT NOT NULL,
+    visibility  TEXT NOT NULL,
+    title       TEXT NOT NULL,
+    description TEXT NOT NULL,
+    content     TEXT NOT NULL,
+    language    TEXT NOT NULL,
+    tags        TEXT NOT NULL,
+    is_favorite BOOLEAN NOT NULL,
+    created_at  TIMESTAMP NOT NULL,
+    PRIMARY KEY (snippet_id, number)
+);
@@ -0,0 +1,23 @@
+-- Personal collections have an empty workspace, so the column can't reference
+-- workspaces; DeleteWorkspace removes a workspace's collections itself
+CREATE TABLE collections (
+    id           TEXT PRIMARY KEY,
+    owner_id     TEXT NOT NULL,
+    workspace_id TEXT NOT NULL DEFAULT '',
+    name         TEXT NOT NULL,
+    description  TEXT NOT NULL DEFAULT '',
+    created_at   TIMESTAMP NOT NULL,
+    updated_at   TIMESTAMP NOT NULL
+);
+
+CREATE INDEX collections_workspace_id ON collections (workspace_id);
+
+-- Deleting a snippet removes it from every collection it's in
+CREATE TABLE collection_snippets (
+    collection_id TEXT NOT NULL REFERENCES collections (id) ON DELETE CASCADE,
+    snippet_id    TEXT NOT NULL REFERENCES snippets (id) ON DELETE CASCADE,
+    position      INTEGER NOT NULL,
+    PRIMARY KEY (collection_id, snippet_id)
+);
+
+CREATE INDEX collection_snippets_snippet_id ON collection_snippets (snippet_id);
@@ -0,0 +1,5 @@
+-- Pages of a workspace's snippets are sorted and cut in SQL, in the order of
+-- sortColumns
+CREATE INDEX snippets_workspace_created_at ON snippets (workspace_id, created_at, id);
+CREATE INDEX snippets_workspace_updated_at ON snippets (workspace_id, updated_at, created_at, id);
+CREATE INDEX snippets_workspace_title ON snippets (workspace_id, LOWER(title), created_at, id);
@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Limits on the number of snippets in a page
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 200
+)
+
+// ErrInvalidCursor is returned by ListSnippets for a cursor it didn't issue,
+// or one issued for a different sort
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// SortField is what the snippets returned by ListSnippets are sorted by
+type SortField string
+
+const (
+	SortUpdatedAt SortField = "updatedAt"
+	SortCreatedAt SortField = "createdAt"
+	SortTitle     SortField = "title"
+	// SortScore sorts snippets by their relevance to the filter's query and
+	// pattern
+	SortScore SortField = "score"
+)
+
+// Valid reports whether f is a known sort field
+func (f SortField) Valid() bool {
+	switch f {
+	case SortUpdatedAt, SortCreatedAt, SortTitle, SortScore:
+		return true
+	default:
+		return false
+	}
+}
+
+// SortOrder is the direction snippets are sorted in
+type SortOrder string
+
+const (
+	OrderAsc  SortOrder = "asc"
+	OrderDesc SortOrder = "desc"
+)
+
+// Valid reports whether o is a known sort order
+func (o SortOrder) Valid() bool {
+	return o == OrderAsc || o == OrderDesc
+}
+
+// Page selects which of the snippets selected by a SnippetFilter are returned
+// by ListSnippets, and in which order.
+//
+// Snippets are sorted by Sort, then by creation time and ID so that the
+// order is the same on every page. Without a Sort, they're sorted by score if
+// the filter has a query or pattern and by creation time otherwise. Order
+// defaults to descending for scores and ascending for everything else.
+type Page struct {
+	// Limit is the most snippets returned, from 1 to MaxPageLimit. Zero means
+	// DefaultPageLimit.
+	Limit int
+	// Cursor is the NextCursor of the previous page, or empty for the first
+	Cursor string
+	Sort   SortField
+	Order  SortOrder
+}
+
+// SnippetPage is a page of the snippets selected by a SnippetFilter
+type SnippetPage struct {
+	Snippets []*SnippetHit `json:"snippets"`
+	// NextCursor fetches the next page, and is empty on the last one
+	NextCursor string `json:"nextCursor,omitempty"`
+	// Total is the number of snippets selected on every page
+	Total int `json:"total"`
+}
+
+// sortKey holds the values a snippet is sorted by. Cursors hold the key of
+// the last snippet of their page, so that the next page starts after it even
+// if snippets were added or removed in between.
+type sortKey struct {
+	Sort      SortField `json:"s"`
+	Order     SortOrder `json:"o"`
+	Score     float64   `json:"sc,omitempty"`
+	Title     string    `json:"ti,omitempty"`
+	UpdatedAt time.Time `json:"u,omitzero"`
+	CreatedAt time.Time `json:"c"`
+	ID        string    `json:"id"`
+}
+
+// withDefaults returns page with its zero fields set to their defaults for
+// filter
+func (page Page) withDefaults(filter SnippetFilter) Page {
+	if page.Limit == 0 {
+		page.Limit = DefaultPageLimit
+	}
+
+	if page.Sort == "" {
+		page.Sort = SortCreatedAt
+		if filter.Query != nil || filter.Pattern != nil {
+			page.Sort = SortScore
+		}
+	}
+
+	if page.Order == "" {
+		page.Order = OrderAsc
+		if page.Sort == SortScore {
+			page.Order = OrderDesc
+		}
+	}
+
+	return page
+}
+
+// keyOf returns the key hit is sorted by on page, whose fields must be set
+func (page Page) keyOf(hit *SnippetHit) sortKey {
+	key := sortKey{Sort: page.Sort, Order: page.Order, CreatedAt: hit.CreatedAt, ID: hit.ID}
+
+	switch page.Sort {
+	case SortScore:
+		key.Score = hit.score
+	case SortTitle:
+		key.Title = strings.ToLower(hit.Title)
+	case SortUpdatedAt:
+		key.UpdatedAt = hit.UpdatedAt
+	}
+
+	return key
+}
+
+// after returns the key held by the cursor of page, whose fields must be set.
+// ErrInvalidCursor is returned if the cursor was issued for another sort.
+func (page Page) after() (sortKey, error) {
+	key, err := decodeCursor(page.Cursor)
+	if err != nil || key.Sort != page.Sort || key.Order != page.Order {
+		return sortKey{}, ErrInvalidCursor
+	}
+
+	return key, nil
+}
+
+// paginate sorts hits as page asks and returns the page of them after its
+// cursor
+func paginate(hits []*SnippetHit, filter SnippetFilter, page Page) (*SnippetPage, error) {
+	page = page.withDefaults(filter)
+	keyOf := page.keyOf
+
+	compare := func(a, b sortKey) int {
+		c := cmp.Or(
+			cmp.Compare(a.Score, b.Score),
+			cmp.Compare(a.Title, b.Title),
+			a.UpdatedAt.Compare(b.UpdatedAt),
+			a.CreatedAt.Compare(b.CreatedAt),
+			cmp.Compare(a.ID, b.ID),
+		)
+
+		if page.Order == OrderDesc {
+			return -c
+		}
+
+		return c
+	}
+
+	slices.SortFunc(hits, func(a, b *SnippetHit) int {
+		return compare(keyOf(a), keyOf(b))
+	})
+
+	start := 0
+
+	if page.Cursor != "" {
+		after, err := page.after()
+		if err != nil {
+			return nil, err
+		}
+
+		start, _ = slices.BinarySearchFunc(hits, after, func(hit *SnippetHit, key sortKey) int {
+			// Land after the cursor's snippet, whether or not it still exists
+			if compare(keyOf(hit), key) <= 0 {
+				return -1
+			}
+
+			return 1
+		})
+	}
+
+	end := min(start+page.Limit, len(hits))
+	result := &SnippetPage{Snippets: hits[start:end], Total: len(hits)}
+
+	if end < len(hits) {
+		result.NextCursor = encodeCursor(keyOf(hits[end-1]))
+	}
+
+	return result, nil
+}
+
+// encodeCursor returns the opaque cursor holding key
+func encodeCursor(key sortKey) string {
+	data, _ := json.Marshal(key)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor returns the key held by cursor
+func decodeCursor(cursor string) (sortKey, error) {
+	var key sortKey
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return key, err
+	}
+
+	err = json.Unmarshal(data, &key)
+
+	return key, err
+}
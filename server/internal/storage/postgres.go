@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	// Register the "pgx" driver
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+//go:embed migrations/postgres/*.sql
+var postgresMigrations embed.FS
+
+// OpenPostgres connects to the PostgreSQL database at dsn and migrates it to
+// the latest schema. dsn may be a URL or a key/value connection string.
+func OpenPostgres(ctx context.Context, dsn string, logger *zap.Logger) (*SQLStore, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open postgres database: %w", err)
+	}
+
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("connect to postgres database: %w", err)
+	}
+
+	logger = logger.Named("store")
+
+	if err := migrate(ctx, db, postgresMigrations, "migrations/postgres", logger); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("migrate postgres database: %w", err)
+	}
+
+	logger.Sugar().Debug("postgres store initialized")
+
+	return &SQLStore{db: db, logger: logger}, nil
+}
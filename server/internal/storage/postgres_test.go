@@ -0,0 +1,80 @@
+package storage_test
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/villaleo/cstash/internal/auth"
+	"github.com/villaleo/cstash/internal/storage"
+	"github.com/villaleo/cstash/internal/storage/storetest"
+	"go.uber.org/zap"
+)
+
+// TestPostgresStore runs the store suite against the PostgreSQL database at
+// CSTASH_TEST_DSN, and is skipped if it isn't set. Each test migrates a schema
+// of its own, which is dropped when it ends.
+func TestPostgresStore(t *testing.T) {
+	dsn := os.Getenv("CSTASH_TEST_DSN")
+	if dsn == "" {
+		t.Skip("CSTASH_TEST_DSN is not set")
+	}
+
+	storetest.Run(t, func(t *testing.T) storage.Store {
+		ctx := context.Background()
+
+		db, err := sql.Open("pgx", dsn)
+		if err != nil {
+			t.Fatalf("open postgres database: %v", err)
+		}
+		t.Cleanup(func() { _ = db.Close() })
+
+		// Secure IDs are alphanumeric, so the schema never needs quoting
+		schema := "cstash_test_" + strings.ToLower(auth.NewSecureID())
+
+		if _, err := db.ExecContext(ctx, "CREATE SCHEMA "+schema); err != nil {
+			t.Fatalf("create schema: %v", err)
+		}
+
+		t.Cleanup(func() {
+			if _, err := db.ExecContext(ctx, "DROP SCHEMA "+schema+" CASCADE"); err != nil {
+				t.Errorf("drop schema: %v", err)
+			}
+		})
+
+		store, err := storage.OpenPostgres(ctx, withSearchPath(t, dsn, schema), zap.NewNop())
+		if err != nil {
+			t.Fatalf("OpenPostgres() = %v", err)
+		}
+
+		// Cleanups run last registered first, so the store is closed before
+		// its schema is dropped
+		t.Cleanup(func() { _ = store.Close() })
+
+		return store
+	})
+}
+
+// withSearchPath returns dsn with its search path set to schema. dsn may be a
+// URL or a key/value connection string, as OpenPostgres accepts.
+func withSearchPath(t *testing.T, dsn, schema string) string {
+	t.Helper()
+
+	if !strings.Contains(dsn, "://") {
+		return dsn + " search_path=" + schema
+	}
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("parse CSTASH_TEST_DSN: %v", err)
+	}
+
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
@@ -0,0 +1,513 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/villaleo/cstash/internal/models"
+	"github.com/villaleo/cstash/internal/search"
+	"go.uber.org/zap"
+)
+
+// Ensure SQLStore implements Store
+var _ Store = (*SQLStore)(nil)
+
+// SQLStore represents a persistent storage solution for snippets backed by a
+// SQL database. Queries are written with $N placeholders so that they can be
+// shared by every supported driver.
+type SQLStore struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+const selectSnippetColumns = `
+	SELECT id, owner_id, workspace_id, visibility, title, description, content, language, is_favorite, created_at, updated_at, version
+	FROM snippets`
+
+// Close closes the underlying database
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// CreateSnippet adds a new snippet to the store
+func (s *SQLStore) CreateSnippet(ctx context.Context, snippet *models.Snippet) error {
+	if membership := workspaceMembership(ctx); membership != nil && !membership.Role.CanEdit() {
+		s.logger.Sugar().Debugw("caller may not create snippets in workspace", "workspace.id", membership.WorkspaceID)
+		return ErrForbidden
+	}
+
+	snippet.WorkspaceID = workspaceID(ctx)
+	snippet.Version = 1
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		// Checking for the ID before inserting would race with a concurrent
+		// insert, so a conflict is told by nothing being inserted instead
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO snippets (id, owner_id, workspace_id, visibility, title, description, content, language, is_favorite, created_at, updated_at, version)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			ON CONFLICT (id) DO NOTHING`,
+			snippet.ID, snippet.OwnerID, snippet.WorkspaceID, snippet.Visibility, snippet.Title, snippet.Description, snippet.Content,
+			snippet.Language, snippet.IsFavorite, snippet.CreatedAt.UTC(), snippet.UpdatedAt.UTC(), snippet.Version,
+		)
+		if err != nil {
+			return err
+		}
+
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrSnippetExists
+		}
+
+		if err := setTags(ctx, tx, snippet.ID, snippet.Tags); err != nil {
+			return err
+		}
+
+		return insertRevision(ctx, tx, models.NewRevision(snippet, 1, callerID(ctx)))
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.Sugar().Debugw("snippet saved", "snippet.id", snippet.ID)
+
+	return nil
+}
+
+// GetSnippet retrieves a snippet by ID
+func (s *SQLStore) GetSnippet(ctx context.Context, id string) (*models.Snippet, error) {
+	sugar := s.logger.Sugar()
+
+	snippet, err := getVisibleSnippet(ctx, s.db, id)
+	if err != nil {
+		if errors.Is(err, ErrSnippetNotFound) {
+			sugar.Debugw("snippet not found", "snippet.id", id)
+		}
+
+		return nil, err
+	}
+
+	sugar.Debugw("snippet retreived", "snippet.id", snippet.ID)
+
+	return snippet, nil
+}
+
+// UpdateSnippet updates an existing snippet
+func (s *SQLStore) UpdateSnippet(ctx context.Context, id string, change func(snippet *models.Snippet) error) (*models.Snippet, error) {
+	s.logger.Sugar().Debugw("updating snippet", "snippet.id", id)
+
+	return s.updateSnippet(ctx, id, func(_ *sql.Tx, snippet *models.Snippet) error {
+		return change(snippet)
+	})
+}
+
+// updateSnippet changes the snippet with id by calling change on it inside a
+// transaction, then saves it and records a revision of the result
+func (s *SQLStore) updateSnippet(ctx context.Context, id string, change func(tx *sql.Tx, snippet *models.Snippet) error) (*models.Snippet, error) {
+	var (
+		snippet *models.Snippet
+		sugar   = s.logger.Sugar()
+	)
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		before, err := getEditableSnippet(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := checkVersion(ctx, before); err != nil {
+			return err
+		}
+
+		updated := *before
+		if err := change(tx, &updated); err != nil {
+			return err
+		}
+
+		updated.UpdatedAt = time.Now()
+		updated.Version++
+		snippet = &updated
+
+		// The version is checked again in case a concurrent change committed
+		// after the snippet was read
+		result, err := tx.ExecContext(ctx, `
+			UPDATE snippets
+			SET visibility = $1, title = $2, description = $3, content = $4, language = $5, is_favorite = $6, updated_at = $7,
+				version = $8
+			WHERE id = $9 AND version = $10`,
+			snippet.Visibility, snippet.Title, snippet.Description, snippet.Content, snippet.Language,
+			snippet.IsFavorite, snippet.UpdatedAt.UTC(), snippet.Version, id, before.Version,
+		)
+		if err != nil {
+			return err
+		}
+
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrVersionMismatch
+		}
+
+		if !slices.Equal(before.Tags, snippet.Tags) {
+			if err := setTags(ctx, tx, id, snippet.Tags); err != nil {
+				return err
+			}
+		}
+
+		return recordRevisions(ctx, tx, before, snippet)
+	})
+	if err != nil {
+		if errors.Is(err, ErrSnippetNotFound) {
+			sugar.Debugw("snippet not found", "snippet.id", id)
+		}
+
+		return nil, err
+	}
+
+	sugar.Debugw("snippet updated", "snippet.id", snippet.ID)
+
+	return snippet, nil
+}
+
+// DeleteSnippet removes a snippet from the store. Its revisions and its places
+// in collections are deleted along with it by the database.
+func (s *SQLStore) DeleteSnippet(ctx context.Context, id string) error {
+	sugar := s.logger.Sugar()
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		snippet, err := getEditableSnippet(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := checkVersion(ctx, snippet); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM snippets WHERE id = $1", id); err != nil {
+			return err
+		}
+
+		return pruneTags(ctx, tx)
+	})
+	if err != nil {
+		if errors.Is(err, ErrSnippetNotFound) {
+			sugar.Debugw("snippet not found", "snippet.id", id)
+		}
+
+		return err
+	}
+
+	sugar.Debugw("deleted snippet", "snippet.id", id)
+
+	return nil
+}
+
+// ListSnippets returns a page of the snippets visible to the caller that are
+// selected by filter
+func (s *SQLStore) ListSnippets(ctx context.Context, filter SnippetFilter, page Page) (*SnippetPage, error) {
+	sugar := s.logger.Sugar()
+
+	if pagedInSQL(filter, page) {
+		results, err := listSnippetPage(ctx, s.db, filter, page)
+		if err != nil {
+			return nil, err
+		}
+
+		sugar.Debugw("fetched snippets", "count", len(results.Snippets), "total", results.Total, "tags", filter.Tags)
+
+		return results, nil
+	}
+
+	snippets, err := listSnippets(ctx, s.db, workspaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	// Rows are filtered in Go, so the query's words are looked up in an index
+	// built just for this request
+	index := search.NewIndex()
+	if filter.Query != nil {
+		for _, snippet := range snippets {
+			index.Add(snippet.ID, snippetDocument(snippet))
+		}
+	}
+
+	hits, err := filterSnippets(ctx, snippets, filter, index)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := paginate(hits, filter, page)
+	if err != nil {
+		return nil, err
+	}
+
+	sugar.Debugw("fetched snippets", "count", len(results.Snippets), "total", results.Total, "tags", filter.Tags)
+
+	return results, nil
+}
+
+// CountLanguages counts the snippets the caller may view in their workspace
+// by language
+func (s *SQLStore) CountLanguages(ctx context.Context) (map[string]int, error) {
+	snippets, err := listSnippets(ctx, s.db, workspaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	counts := countLanguages(ctx, snippets)
+	s.logger.Sugar().Debugw("counted languages", "count", len(counts))
+
+	return counts, nil
+}
+
+// withTx runs fn inside a transaction, committing if fn succeeds and rolling
+// back otherwise
+func (s *SQLStore) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// getSnippet fetches a single snippet and its tags
+func getSnippet(ctx context.Context, q querier, id string) (*models.Snippet, error) {
+	snippet, err := scanSnippet(q.QueryRowContext(ctx, selectSnippetColumns+" WHERE id = $1", id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrSnippetNotFound
+		}
+
+		return nil, err
+	}
+
+	tags, err := loadTags(ctx, q, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if t, ok := tags[id]; ok {
+		snippet.Tags = t
+	}
+
+	return snippet, nil
+}
+
+// getVisibleSnippet fetches a single snippet visible to the caller
+func getVisibleSnippet(ctx context.Context, q querier, id string) (*models.Snippet, error) {
+	snippet, err := getSnippet(ctx, q, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canView(ctx, snippet) {
+		return nil, ErrSnippetNotFound
+	}
+
+	return snippet, nil
+}
+
+// getEditableSnippet fetches a single snippet editable by the caller
+func getEditableSnippet(ctx context.Context, q querier, id string) (*models.Snippet, error) {
+	snippet, err := getVisibleSnippet(ctx, q, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canEdit(ctx, snippet) {
+		return nil, ErrForbidden
+	}
+
+	return snippet, nil
+}
+
+// listSnippets fetches every snippet in the workspace with workspaceID and its
+// tags
+func listSnippets(ctx context.Context, q querier, workspaceID string) ([]*models.Snippet, error) {
+	rows, err := q.QueryContext(ctx, selectSnippetColumns+" WHERE workspace_id = $1 ORDER BY created_at, id", workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+
+	for rows.Next() {
+		snippet, err := scanSnippet(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, snippet)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tags, err := loadWorkspaceTags(ctx, q, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, snippet := range snippets {
+		if t, ok := tags[snippet.ID]; ok {
+			snippet.Tags = t
+		}
+	}
+
+	return snippets, nil
+}
+
+// scanSnippet scans a row selected with selectSnippetColumns. The snippet's
+// tags are initialized to an empty slice.
+func scanSnippet(row rowScanner) (*models.Snippet, error) {
+	snippet := &models.Snippet{Tags: []string{}}
+
+	err := row.Scan(
+		&snippet.ID, &snippet.OwnerID, &snippet.WorkspaceID, &snippet.Visibility, &snippet.Title, &snippet.Description,
+		&snippet.Content, &snippet.Language, &snippet.IsFavorite, &snippet.CreatedAt, &snippet.UpdatedAt, &snippet.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return snippet, nil
+}
+
+// loadTags fetches the tags of the snippets with ids, in order, keyed by
+// snippet ID
+func loadTags(ctx context.Context, q querier, ids ...string) (map[string][]string, error) {
+	if len(ids) == 0 {
+		return map[string][]string{}, nil
+	}
+
+	var (
+		placeholders = make([]string, len(ids))
+		args         = make([]any, len(ids))
+	)
+
+	for i, id := range ids {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = id
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT st.snippet_id, t.name
+		FROM snippet_tags st
+		JOIN tags t ON t.id = st.tag_id
+		WHERE st.snippet_id IN (`+strings.Join(placeholders, ", ")+`)
+		ORDER BY st.snippet_id, st.position`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanTags(rows)
+}
+
+// loadWorkspaceTags fetches the tags of every snippet in the workspace with
+// workspaceID, in order, keyed by snippet ID
+func loadWorkspaceTags(ctx context.Context, q querier, workspaceID string) (map[string][]string, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT st.snippet_id, t.name
+		FROM snippet_tags st
+		JOIN tags t ON t.id = st.tag_id
+		JOIN snippets s ON s.id = st.snippet_id
+		WHERE s.workspace_id = $1
+		ORDER BY st.snippet_id, st.position`,
+		workspaceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanTags(rows)
+}
+
+// scanTags scans and closes rows of snippet IDs and tag names, returning the
+// tags keyed by snippet ID
+func scanTags(rows *sql.Rows) (map[string][]string, error) {
+	defer rows.Close()
+
+	results := make(map[string][]string)
+
+	for rows.Next() {
+		var snippetID, name string
+		if err := rows.Scan(&snippetID, &name); err != nil {
+			return nil, err
+		}
+
+		results[snippetID] = append(results[snippetID], name)
+	}
+
+	return results, rows.Err()
+}
+
+// setTags replaces the tags of the snippet with id. Duplicate tags are only
+// stored once and tags left without any snippet are removed.
+func setTags(ctx context.Context, tx *sql.Tx, id string, tags []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM snippet_tags WHERE snippet_id = $1", id); err != nil {
+		return err
+	}
+
+	seen := make(map[string]struct{}, len(tags))
+
+	for position, tag := range tags {
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+
+		_, err := tx.ExecContext(ctx, "INSERT INTO tags (name) VALUES ($1) ON CONFLICT (name) DO NOTHING", tag)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO snippet_tags (snippet_id, tag_id, position)
+			SELECT $1, id, $2 FROM tags WHERE name = $3`,
+			id, position, tag,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return pruneTags(ctx, tx)
+}
+
+// pruneTags removes every tag that is no longer referenced by a snippet
+func pruneTags(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+		DELETE FROM tags
+		WHERE NOT EXISTS (SELECT 1 FROM snippet_tags WHERE tag_id = tags.id)`)
+
+	return err
+}
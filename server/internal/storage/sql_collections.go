@@ -0,0 +1,316 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/villaleo/cstash/internal/models"
+)
+
+// Ensure SQLStore implements CollectionStore
+var _ CollectionStore = (*SQLStore)(nil)
+
+const selectCollectionColumns = `
+	SELECT id, owner_id, workspace_id, name, description, created_at, updated_at
+	FROM collections`
+
+// CreateCollection adds a new collection to the store
+func (s *SQLStore) CreateCollection(ctx context.Context, collection *models.Collection) error {
+	if !canEditCollection(ctx) {
+		s.logger.Sugar().Debugw("caller may not create collections in workspace", "workspace.id", workspaceID(ctx))
+		return ErrForbidden
+	}
+
+	collection.WorkspaceID = workspaceID(ctx)
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		if err := checkCollectionSnippets(ctx, tx, addedSnippets(nil, collection)); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO collections (id, owner_id, workspace_id, name, description, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			collection.ID, collection.OwnerID, collection.WorkspaceID, collection.Name, collection.Description,
+			collection.CreatedAt.UTC(), collection.UpdatedAt.UTC(),
+		)
+		if err != nil {
+			return err
+		}
+
+		return setCollectionSnippets(ctx, tx, collection)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.Sugar().Debugw("collection saved", "collection.id", collection.ID)
+
+	return nil
+}
+
+// GetCollection retrieves a collection by ID
+func (s *SQLStore) GetCollection(ctx context.Context, id string) (*models.Collection, error) {
+	return getVisibleCollection(ctx, s.db, id)
+}
+
+// ListCollections returns every collection the caller may view in their
+// workspace, oldest first
+func (s *SQLStore) ListCollections(ctx context.Context) ([]*models.Collection, error) {
+	rows, err := s.db.QueryContext(ctx, selectCollectionColumns+" WHERE workspace_id = $1 ORDER BY created_at, id", workspaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []*models.Collection{}
+
+	for rows.Next() {
+		collection, err := scanCollection(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		if canViewCollection(ctx, collection) {
+			results = append(results, collection)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	snippetIDs, err := loadCollectionSnippets(ctx, s.db, "")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, collection := range results {
+		if ids, ok := snippetIDs[collection.ID]; ok {
+			collection.SnippetIDs = ids
+		}
+	}
+
+	s.logger.Sugar().Debugw("fetched collections", "count", len(results))
+
+	return results, nil
+}
+
+// UpdateCollection changes the collection with id by calling change on a copy
+// of it
+func (s *SQLStore) UpdateCollection(ctx context.Context, id string, change func(collection *models.Collection) error) (*models.Collection, error) {
+	var updated *models.Collection
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		collection, err := getEditableCollection(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		updated = cloneCollection(collection)
+		if err := change(updated); err != nil {
+			return err
+		}
+
+		if err := checkCollectionSnippets(ctx, tx, addedSnippets(collection, updated)); err != nil {
+			return err
+		}
+
+		updated.UpdatedAt = time.Now()
+
+		_, err = tx.ExecContext(ctx,
+			"UPDATE collections SET name = $1, description = $2, updated_at = $3 WHERE id = $4",
+			updated.Name, updated.Description, updated.UpdatedAt.UTC(), id,
+		)
+		if err != nil {
+			return err
+		}
+
+		return setCollectionSnippets(ctx, tx, updated)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Sugar().Debugw("collection updated", "collection.id", id)
+
+	return updated, nil
+}
+
+// DeleteCollection removes a collection from the store
+func (s *SQLStore) DeleteCollection(ctx context.Context, id string) error {
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := getEditableCollection(ctx, tx, id); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, "DELETE FROM collections WHERE id = $1", id)
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.Sugar().Debugw("deleted collection", "collection.id", id)
+
+	return nil
+}
+
+// ListCollectionSnippets returns the snippets in the collection with id that
+// the caller may view, in order
+func (s *SQLStore) ListCollectionSnippets(ctx context.Context, id string) ([]*models.Snippet, error) {
+	collection, err := getVisibleCollection(ctx, s.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	snippets, err := listSnippets(ctx, s.db, workspaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*models.Snippet, len(snippets))
+	for _, snippet := range snippets {
+		byID[snippet.ID] = snippet
+	}
+
+	results := []*models.Snippet{}
+
+	for _, snippetID := range collection.SnippetIDs {
+		if snippet, ok := byID[snippetID]; ok && canView(ctx, snippet) {
+			results = append(results, snippet)
+		}
+	}
+
+	s.logger.Sugar().Debugw("fetched collection snippets", "collection.id", id, "count", len(results))
+
+	return results, nil
+}
+
+// getVisibleCollection fetches a single collection visible to the caller
+func getVisibleCollection(ctx context.Context, q querier, id string) (*models.Collection, error) {
+	collection, err := scanCollection(q.QueryRowContext(ctx, selectCollectionColumns+" WHERE id = $1", id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCollectionNotFound
+		}
+
+		return nil, err
+	}
+
+	if !canViewCollection(ctx, collection) {
+		return nil, ErrCollectionNotFound
+	}
+
+	snippetIDs, err := loadCollectionSnippets(ctx, q, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if ids, ok := snippetIDs[id]; ok {
+		collection.SnippetIDs = ids
+	}
+
+	return collection, nil
+}
+
+// getEditableCollection fetches a single collection editable by the caller
+func getEditableCollection(ctx context.Context, q querier, id string) (*models.Collection, error) {
+	collection, err := getVisibleCollection(ctx, q, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canEditCollection(ctx) {
+		return nil, ErrForbidden
+	}
+
+	return collection, nil
+}
+
+// scanCollection scans a row selected with selectCollectionColumns. The
+// collection's snippet IDs are initialized to an empty slice.
+func scanCollection(row rowScanner) (*models.Collection, error) {
+	collection := &models.Collection{SnippetIDs: []string{}}
+
+	err := row.Scan(
+		&collection.ID, &collection.OwnerID, &collection.WorkspaceID, &collection.Name, &collection.Description,
+		&collection.CreatedAt, &collection.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+// loadCollectionSnippets fetches the IDs of the snippets in the collection
+// with id, in order, keyed by collection ID. If id is empty, the snippets of
+// every collection are fetched.
+func loadCollectionSnippets(ctx context.Context, q querier, id string) (map[string][]string, error) {
+	var (
+		rows *sql.Rows
+		err  error
+	)
+
+	query := "SELECT collection_id, snippet_id FROM collection_snippets"
+
+	if id == "" {
+		rows, err = q.QueryContext(ctx, query+" ORDER BY collection_id, position")
+	} else {
+		rows, err = q.QueryContext(ctx, query+" WHERE collection_id = $1 ORDER BY position", id)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make(map[string][]string)
+
+	for rows.Next() {
+		var collectionID, snippetID string
+		if err := rows.Scan(&collectionID, &snippetID); err != nil {
+			return nil, err
+		}
+
+		results[collectionID] = append(results[collectionID], snippetID)
+	}
+
+	return results, rows.Err()
+}
+
+// setCollectionSnippets replaces the snippets of collection with its
+// SnippetIDs, in order
+func setCollectionSnippets(ctx context.Context, tx *sql.Tx, collection *models.Collection) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM collection_snippets WHERE collection_id = $1", collection.ID); err != nil {
+		return err
+	}
+
+	for position, snippetID := range collection.SnippetIDs {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO collection_snippets (collection_id, snippet_id, position) VALUES ($1, $2, $3)",
+			collection.ID, snippetID, position,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkCollectionSnippets returns ErrSnippetNotFound unless the caller may
+// view every snippet with ids
+func checkCollectionSnippets(ctx context.Context, q querier, ids []string) error {
+	for _, id := range ids {
+		if _, err := getVisibleSnippet(ctx, q, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,233 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/villaleo/cstash/internal/models"
+)
+
+// sortColumns are the columns snippets are sorted by in SQL for each sort
+// other than by score, in the order paginate compares them
+var sortColumns = map[SortField][]string{
+	SortCreatedAt: {"created_at", "id"},
+	SortUpdatedAt: {"updated_at", "created_at", "id"},
+	SortTitle:     {"LOWER(title)", "created_at", "id"},
+}
+
+// sortValues returns the values of key for the columns of its sort
+func sortValues(key sortKey) []any {
+	switch key.Sort {
+	case SortUpdatedAt:
+		return []any{key.UpdatedAt.UTC(), key.CreatedAt.UTC(), key.ID}
+	case SortTitle:
+		return []any{key.Title, key.CreatedAt.UTC(), key.ID}
+	default:
+		return []any{key.CreatedAt.UTC(), key.ID}
+	}
+}
+
+// snippetConditions is the WHERE clause of a query for snippets, built up
+// along with its arguments
+type snippetConditions struct {
+	conditions []string
+	args       []any
+}
+
+// arg adds value to the arguments of the query and returns its placeholder
+func (c *snippetConditions) arg(value any) string {
+	c.args = append(c.args, value)
+
+	return fmt.Sprintf("$%d", len(c.args))
+}
+
+// add adds condition, which may use placeholders returned by arg
+func (c *snippetConditions) add(condition string) {
+	c.conditions = append(c.conditions, condition)
+}
+
+// String returns the WHERE clause, or an empty string if there are no
+// conditions
+func (c *snippetConditions) String() string {
+	if len(c.conditions) == 0 {
+		return ""
+	}
+
+	return " WHERE " + strings.Join(c.conditions, " AND ")
+}
+
+// visibleSnippets returns the conditions selecting the snippets the caller
+// making the call with ctx may view, as canView decides
+func visibleSnippets(ctx context.Context) *snippetConditions {
+	c := &snippetConditions{}
+	c.add("workspace_id = " + c.arg(workspaceID(ctx)))
+
+	// Every member of a workspace may view its snippets
+	if workspaceID(ctx) == "" {
+		caller := c.arg(callerID(ctx))
+		c.add(fmt.Sprintf(
+			"(visibility = 'public' OR (%[1]s <> '' AND (owner_id IN ('', %[1]s) OR visibility = 'team')))",
+			caller,
+		))
+	}
+
+	return c
+}
+
+// addTags adds the conditions selecting the snippets with the tags of filter
+// as its TagMode asks and none of its NotTags
+func (c *snippetConditions) addTags(filter SnippetFilter) {
+	if len(filter.Tags) > 0 {
+		var (
+			names = lowerTags(filter.Tags)
+			query = "snippets.id IN (SELECT st.snippet_id FROM snippet_tags st JOIN tags t ON t.id = st.tag_id " + c.tagJoin(names)
+		)
+
+		// A snippet has every tag if each of them matches one of its own
+		if filter.TagMode == TagModeAll && len(names) > 1 {
+			query += " GROUP BY st.snippet_id HAVING COUNT(DISTINCT f.name) = " + c.arg(len(names))
+		}
+
+		c.add(query + ")")
+	}
+
+	if len(filter.NotTags) > 0 {
+		c.add("NOT EXISTS (SELECT 1 FROM snippet_tags st JOIN tags t ON t.id = st.tag_id " +
+			c.tagJoin(lowerTags(filter.NotTags)) + " WHERE st.snippet_id = snippets.id)")
+	}
+}
+
+// tagJoin returns a join of the tags aliased t with the names, which must be
+// lower case, on the tags that are called one of names or are below one. Each
+// row holds the name it matched as f.name.
+func (c *snippetConditions) tagJoin(names []string) string {
+	rows := make([]string, len(names))
+	for i, name := range names {
+		rows[i] = fmt.Sprintf("SELECT CAST(%s AS TEXT) AS name, CAST(%s AS TEXT) AS below",
+			c.arg(name), c.arg(likeEscaper.Replace(name)+models.TagSeparator+"%"),
+		)
+	}
+
+	return fmt.Sprintf(`JOIN (%s) f ON LOWER(t.name) = f.name OR LOWER(t.name) LIKE f.below ESCAPE '\'`,
+		strings.Join(rows, " UNION ALL "),
+	)
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern with a backslash
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// lowerTags returns the distinct names of tags in lower case, as
+// models.TagMatches compares them
+func lowerTags(tags []string) []string {
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		names = append(names, strings.ToLower(tag))
+	}
+
+	slices.Sort(names)
+
+	return slices.Compact(names)
+}
+
+// pagedInSQL reports whether the page of the snippets selected by filter can
+// be filtered, sorted and cut in SQL, which is when it has neither a query
+// nor a pattern to match in Go
+func pagedInSQL(filter SnippetFilter, page Page) bool {
+	_, ok := sortColumns[page.withDefaults(filter).Sort]
+
+	return ok && filter.Query == nil && filter.Pattern == nil
+}
+
+// listSnippetPage returns the page of the snippets the caller may view that
+// are selected by filter, sorted, limited and started after the cursor of
+// page in SQL. It may only be called if pagedInSQL is true for filter and
+// page.
+func listSnippetPage(ctx context.Context, q querier, filter SnippetFilter, page Page) (*SnippetPage, error) {
+	page = page.withDefaults(filter)
+
+	var (
+		where   = visibleSnippets(ctx)
+		columns = sortColumns[page.Sort]
+		results = &SnippetPage{Snippets: []*SnippetHit{}}
+	)
+
+	where.addTags(filter)
+
+	err := q.QueryRowContext(ctx, "SELECT COUNT(*) FROM snippets"+where.String(), where.args...).Scan(&results.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	compare, direction := ">", "ASC"
+	if page.Order == OrderDesc {
+		compare, direction = "<", "DESC"
+	}
+
+	if page.Cursor != "" {
+		after, err := page.after()
+		if err != nil {
+			return nil, err
+		}
+
+		placeholders := make([]string, len(columns))
+		for i, value := range sortValues(after) {
+			placeholders[i] = where.arg(value)
+		}
+
+		where.add(fmt.Sprintf("(%s) %s (%s)", strings.Join(columns, ", "), compare, strings.Join(placeholders, ", ")))
+	}
+
+	orderBy := make([]string, len(columns))
+	for i, column := range columns {
+		orderBy[i] = column + " " + direction
+	}
+
+	// One more snippet than the page holds is fetched to tell whether there's
+	// a next page
+	limit := where.arg(page.Limit + 1)
+	query := fmt.Sprintf("%s%s ORDER BY %s LIMIT %s", selectSnippetColumns, where.String(), strings.Join(orderBy, ", "), limit)
+
+	rows, err := q.QueryContext(ctx, query, where.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		snippet, err := scanSnippet(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		results.Snippets = append(results.Snippets, &SnippetHit{Snippet: snippet})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(results.Snippets) > page.Limit {
+		results.Snippets = results.Snippets[:page.Limit]
+		results.NextCursor = encodeCursor(page.keyOf(results.Snippets[page.Limit-1]))
+	}
+
+	ids := make([]string, len(results.Snippets))
+	for i, hit := range results.Snippets {
+		ids[i] = hit.ID
+	}
+
+	tags, err := loadTags(ctx, q, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hit := range results.Snippets {
+		if t, ok := tags[hit.ID]; ok {
+			hit.Tags = t
+		}
+	}
+
+	return results, nil
+}
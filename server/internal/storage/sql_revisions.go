@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/villaleo/cstash/internal/models"
+)
+
+const selectRevisionColumns = `
+	SELECT snippet_id, number, author_id, visibility, title, description, content, language, tags, is_favorite, created_at
+	FROM snippet_revisions`
+
+// ListRevisions returns every revision of the snippet with id, newest first
+func (s *SQLStore) ListRevisions(ctx context.Context, id string) ([]*models.Revision, error) {
+	if _, err := getVisibleSnippet(ctx, s.db, id); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, selectRevisionColumns+" WHERE snippet_id = $1 ORDER BY number DESC", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []*models.Revision{}
+
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, revision)
+	}
+
+	return results, rows.Err()
+}
+
+// GetRevision retrieves revision number of the snippet with id
+func (s *SQLStore) GetRevision(ctx context.Context, id string, number int) (*models.Revision, error) {
+	if _, err := getVisibleSnippet(ctx, s.db, id); err != nil {
+		return nil, err
+	}
+
+	return getRevision(ctx, s.db, id, number)
+}
+
+// RestoreRevision sets the fields of the snippet with id back to those of its
+// revision number
+func (s *SQLStore) RestoreRevision(ctx context.Context, id string, number int) (*models.Snippet, error) {
+	s.logger.Sugar().Debugw("restoring snippet", "snippet.id", id, "revision", number)
+
+	return s.updateSnippet(ctx, id, func(tx *sql.Tx, snippet *models.Snippet) error {
+		revision, err := getRevision(ctx, tx, id, number)
+		if err != nil {
+			return err
+		}
+
+		revision.Restore(snippet)
+
+		return nil
+	})
+}
+
+// getRevision fetches revision number of the snippet with id
+func getRevision(ctx context.Context, q querier, id string, number int) (*models.Revision, error) {
+	revision, err := scanRevision(q.QueryRowContext(ctx,
+		selectRevisionColumns+" WHERE snippet_id = $1 AND number = $2", id, number,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRevisionNotFound
+	}
+
+	return revision, err
+}
+
+// recordRevisions records the revisions of the caller changing before into
+// after
+func recordRevisions(ctx context.Context, tx *sql.Tx, before, after *models.Snippet) error {
+	var count int
+
+	err := tx.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM snippet_revisions WHERE snippet_id = $1", before.ID,
+	).Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	for _, revision := range newRevisions(ctx, before, after, count) {
+		if err := insertRevision(ctx, tx, revision); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insertRevision saves revision
+func insertRevision(ctx context.Context, tx *sql.Tx, revision *models.Revision) error {
+	tags, err := json.Marshal(revision.Tags)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO snippet_revisions (snippet_id, number, author_id, visibility, title, description, content, language, tags, is_favorite, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		revision.SnippetID, revision.Number, revision.AuthorID, revision.Visibility, revision.Title, revision.Description,
+		revision.Content, revision.Language, string(tags), revision.IsFavorite, revision.CreatedAt.UTC(),
+	)
+
+	return err
+}
+
+// scanRevision scans a row selected with selectRevisionColumns
+func scanRevision(row rowScanner) (*models.Revision, error) {
+	var (
+		revision models.Revision
+		tags     string
+	)
+
+	err := row.Scan(
+		&revision.SnippetID, &revision.Number, &revision.AuthorID, &revision.Visibility, &revision.Title,
+		&revision.Description, &revision.Content, &revision.Language, &tags, &revision.IsFavorite, &revision.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(tags), &revision.Tags); err != nil {
+		return nil, err
+	}
+
+	return &revision, nil
+}
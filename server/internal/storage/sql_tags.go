@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/villaleo/cstash/internal/models"
+)
+
+// ListTags fetches every tag of the snippets the caller may view in their
+// workspace, ordered by name
+func (s *SQLStore) ListTags(ctx context.Context) ([]models.Tag, error) {
+	snippets, err := listSnippets(ctx, s.db, workspaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := loadTagMetadata(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	results := describeTags(countTags(ctx, snippets), metadata)
+	s.logger.Sugar().Debugw("fetched tags", "count", len(results))
+
+	return results, nil
+}
+
+// TagTree fetches the hierarchy of the tags of the snippets the caller may
+// view in their workspace
+func (s *SQLStore) TagTree(ctx context.Context) ([]*models.TagNode, error) {
+	snippets, err := listSnippets(ctx, s.db, workspaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := loadTagMetadata(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	results := tagTree(ctx, snippets, metadata)
+	s.logger.Sugar().Debugw("fetched tag tree", "count", len(results))
+
+	return results, nil
+}
+
+// UpdateTag renames the tag called name and sets its description and color
+func (s *SQLStore) UpdateTag(ctx context.Context, name string, tag models.Tag) (*models.Tag, error) {
+	change, err := s.changeTags(ctx, func(p *tagPlanner) (*tagChange, error) {
+		return p.updateTag(name, tag)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Sugar().Debugw("tag updated", "tag", name, "name", tag.Name, "count", len(change.snippets))
+
+	return change.tag, nil
+}
+
+// MergeTags replaces the tags called sources with target
+func (s *SQLStore) MergeTags(ctx context.Context, sources []string, target string) (*models.Tag, error) {
+	change, err := s.changeTags(ctx, func(p *tagPlanner) (*tagChange, error) {
+		return p.mergeTags(sources, target)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Sugar().Debugw("tags merged", "sources", sources, "target", target, "count", len(change.snippets))
+
+	return change.tag, nil
+}
+
+// DeleteTag removes the tag called name from every snippet
+func (s *SQLStore) DeleteTag(ctx context.Context, name string) error {
+	change, err := s.changeTags(ctx, func(p *tagPlanner) (*tagChange, error) {
+		return p.deleteTag(name)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.Sugar().Debugw("tag deleted", "tag", name, "count", len(change.snippets))
+
+	return nil
+}
+
+// changeTags plans a change to tags with plan and saves it in a single
+// transaction
+func (s *SQLStore) changeTags(ctx context.Context, plan func(p *tagPlanner) (*tagChange, error)) (*tagChange, error) {
+	var change *tagChange
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		snippets, err := listSnippets(ctx, tx, workspaceID(ctx))
+		if err != nil {
+			return err
+		}
+
+		metadata, err := loadTagMetadata(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		change, err = plan(&tagPlanner{ctx: ctx, snippets: snippets, metadata: metadata})
+		if err != nil {
+			return err
+		}
+
+		for _, c := range change.snippets {
+			if err := saveRetaggedSnippet(ctx, tx, c.before, c.after); err != nil {
+				return err
+			}
+		}
+
+		for _, m := range change.metadata {
+			if err := saveTagMetadata(ctx, tx, m); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return change, nil
+}
+
+// saveRetaggedSnippet saves the tags, version and update time of a snippet
+// whose tags changed from those of before to those of after, and records a
+// revision of it
+func saveRetaggedSnippet(ctx context.Context, tx *sql.Tx, before, after *models.Snippet) error {
+	// The version is checked in case a concurrent change committed after the
+	// snippet was read
+	result, err := tx.ExecContext(ctx,
+		"UPDATE snippets SET updated_at = $1, version = $2 WHERE id = $3 AND version = $4",
+		after.UpdatedAt.UTC(), after.Version, after.ID, before.Version,
+	)
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrVersionMismatch
+	}
+
+	if err := setTags(ctx, tx, after.ID, after.Tags); err != nil {
+		return err
+	}
+
+	return recordRevisions(ctx, tx, before, after)
+}
+
+// loadTagMetadata fetches the metadata of the tags the caller sees, by name
+func loadTagMetadata(ctx context.Context, q querier) (map[string]tagMetadata, error) {
+	scope := newTagMetadata(ctx, "", "", "")
+
+	rows, err := q.QueryContext(ctx,
+		"SELECT name, description, color FROM tag_metadata WHERE workspace_id = $1 AND owner_id = $2",
+		scope.WorkspaceID, scope.OwnerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make(map[string]tagMetadata)
+
+	for rows.Next() {
+		metadata := scope
+		if err := rows.Scan(&metadata.Name, &metadata.Description, &metadata.Color); err != nil {
+			return nil, err
+		}
+
+		results[metadata.Name] = metadata
+	}
+
+	return results, rows.Err()
+}
+
+// saveTagMetadata stores metadata, or deletes it if it's empty
+func saveTagMetadata(ctx context.Context, tx *sql.Tx, metadata tagMetadata) error {
+	if metadata.empty() {
+		_, err := tx.ExecContext(ctx,
+			"DELETE FROM tag_metadata WHERE workspace_id = $1 AND owner_id = $2 AND name = $3",
+			metadata.WorkspaceID, metadata.OwnerID, metadata.Name,
+		)
+
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO tag_metadata (workspace_id, owner_id, name, description, color)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (workspace_id, owner_id, name) DO UPDATE SET description = excluded.description, color = excluded.color`,
+		metadata.WorkspaceID, metadata.OwnerID, metadata.Name, metadata.Description, metadata.Color,
+	)
+
+	return err
+}
@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/villaleo/cstash/internal/models"
+)
+
+// Ensure SQLStore implements UserStore and TokenStore
+var (
+	_ UserStore  = (*SQLStore)(nil)
+	_ TokenStore = (*SQLStore)(nil)
+)
+
+const (
+	selectUserColumns  = "SELECT id, username, password_hash, external_id, created_at FROM users"
+	selectTokenColumns = "SELECT id, user_id, name, scopes, token_hash, created_at FROM access_tokens"
+)
+
+// CreateUser adds a new user to the store
+func (s *SQLStore) CreateUser(ctx context.Context, user *models.User) error {
+	// Checking for the username before inserting would race with a concurrent
+	// registration, so a conflict on it or the external ID is told by nothing
+	// being inserted instead
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO users (id, username, password_hash, external_id, created_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT DO NOTHING`,
+		user.ID, user.Username, user.PasswordHash, user.ExternalID, user.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		s.logger.Sugar().Debugw("username or external ID already taken", "user.username", user.Username)
+		return ErrUserExists
+	}
+
+	s.logger.Sugar().Debugw("user saved", "user.id", user.ID)
+
+	return nil
+}
+
+// GetUser retrieves a user by ID
+func (s *SQLStore) GetUser(ctx context.Context, id string) (*models.User, error) {
+	return scanUser(s.db.QueryRowContext(ctx, selectUserColumns+" WHERE id = $1", id))
+}
+
+// GetUserByUsername retrieves a user by username
+func (s *SQLStore) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	return scanUser(s.db.QueryRowContext(ctx, selectUserColumns+" WHERE username = $1", username))
+}
+
+// GetUserByExternalID retrieves a user by their single sign-on identifier
+func (s *SQLStore) GetUserByExternalID(ctx context.Context, externalID string) (*models.User, error) {
+	if externalID == "" {
+		return nil, ErrUserNotFound
+	}
+
+	return scanUser(s.db.QueryRowContext(ctx, selectUserColumns+" WHERE external_id = $1", externalID))
+}
+
+// CreateSession adds a new session to the store
+func (s *SQLStore) CreateSession(ctx context.Context, session *models.Session) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO sessions (token_hash, user_id, created_at, expires_at) VALUES ($1, $2, $3, $4)",
+		session.TokenHash, session.UserID, session.CreatedAt.UTC(), session.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+
+	s.logger.Sugar().Debugw("session saved", "user.id", session.UserID)
+
+	return nil
+}
+
+// GetSession retrieves an unexpired session by token hash
+func (s *SQLStore) GetSession(ctx context.Context, tokenHash string) (*models.Session, error) {
+	session := &models.Session{}
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT token_hash, user_id, created_at, expires_at
+		FROM sessions
+		WHERE token_hash = $1 AND expires_at > $2`,
+		tokenHash, time.Now().UTC(),
+	).Scan(&session.TokenHash, &session.UserID, &session.CreatedAt, &session.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrSessionNotFound
+		}
+
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// DeleteSession removes a session from the store
+func (s *SQLStore) DeleteSession(ctx context.Context, tokenHash string) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM sessions WHERE token_hash = $1", tokenHash)
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// CreateAccessToken adds a new access token to the store
+func (s *SQLStore) CreateAccessToken(ctx context.Context, token *models.AccessToken) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO access_tokens (id, user_id, name, scopes, token_hash, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		token.ID, token.UserID, token.Name, strings.Join(token.Scopes, " "), token.TokenHash, token.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+
+	s.logger.Sugar().Debugw("access token saved", "token.id", token.ID, "user.id", token.UserID)
+
+	return nil
+}
+
+// GetAccessToken retrieves an access token by hash
+func (s *SQLStore) GetAccessToken(ctx context.Context, tokenHash string) (*models.AccessToken, error) {
+	return scanAccessToken(s.db.QueryRowContext(ctx, selectTokenColumns+" WHERE token_hash = $1", tokenHash))
+}
+
+// ListAccessTokens returns every access token owned by the user with userID,
+// oldest first
+func (s *SQLStore) ListAccessTokens(ctx context.Context, userID string) ([]*models.AccessToken, error) {
+	rows, err := s.db.QueryContext(ctx, selectTokenColumns+" WHERE user_id = $1 ORDER BY created_at, id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []*models.AccessToken{}
+
+	for rows.Next() {
+		token, err := scanAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, token)
+	}
+
+	return results, rows.Err()
+}
+
+// DeleteAccessToken revokes the access token with id owned by the user with
+// userID
+func (s *SQLStore) DeleteAccessToken(ctx context.Context, userID, id string) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM access_tokens WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrAccessTokenNotFound
+	}
+
+	s.logger.Sugar().Debugw("access token revoked", "token.id", id, "user.id", userID)
+
+	return nil
+}
+
+// scanUser scans a row selected with selectUserColumns
+func scanUser(row rowScanner) (*models.User, error) {
+	user := &models.User{}
+
+	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.ExternalID, &user.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// scanAccessToken scans a row selected with selectTokenColumns
+func scanAccessToken(row rowScanner) (*models.AccessToken, error) {
+	var (
+		token  = &models.AccessToken{}
+		scopes string
+	)
+
+	err := row.Scan(&token.ID, &token.UserID, &token.Name, &scopes, &token.TokenHash, &token.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAccessTokenNotFound
+		}
+
+		return nil, err
+	}
+
+	token.Scopes = strings.Fields(scopes)
+
+	return token, nil
+}
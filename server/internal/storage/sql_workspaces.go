@@ -0,0 +1,297 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/villaleo/cstash/internal/models"
+)
+
+// Ensure SQLStore implements WorkspaceStore
+var _ WorkspaceStore = (*SQLStore)(nil)
+
+const (
+	selectWorkspaceColumns  = "SELECT id, name, created_at FROM workspaces"
+	selectMembershipColumns = "SELECT workspace_id, user_id, role, created_at FROM memberships"
+)
+
+// CreateWorkspace adds a new workspace to the store, owned by the user with
+// ownerID
+func (s *SQLStore) CreateWorkspace(ctx context.Context, workspace *models.Workspace, ownerID string) error {
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO workspaces (id, name, created_at) VALUES ($1, $2, $3)",
+			workspace.ID, workspace.Name, workspace.CreatedAt.UTC(),
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO memberships (workspace_id, user_id, role, created_at) VALUES ($1, $2, $3, $4)",
+			workspace.ID, ownerID, models.RoleOwner, workspace.CreatedAt.UTC(),
+		)
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.Sugar().Debugw("workspace saved", "workspace.id", workspace.ID)
+
+	return nil
+}
+
+// GetWorkspace retrieves a workspace by ID
+func (s *SQLStore) GetWorkspace(ctx context.Context, id string) (*models.Workspace, error) {
+	return getWorkspace(ctx, s.db, id)
+}
+
+// ListWorkspaces returns every workspace the user with userID is a member of,
+// oldest first
+func (s *SQLStore) ListWorkspaces(ctx context.Context, userID string) ([]*models.Workspace, error) {
+	rows, err := s.db.QueryContext(ctx, selectWorkspaceColumns+`
+		WHERE id IN (SELECT workspace_id FROM memberships WHERE user_id = $1)
+		ORDER BY created_at, id`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []*models.Workspace{}
+
+	for rows.Next() {
+		workspace, err := scanWorkspace(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, workspace)
+	}
+
+	return results, rows.Err()
+}
+
+// DeleteWorkspace removes a workspace from the store, along with its
+// memberships, snippets, tags and collections
+func (s *SQLStore) DeleteWorkspace(ctx context.Context, id string) error {
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, "DELETE FROM workspaces WHERE id = $1", id)
+		if err != nil {
+			return err
+		}
+
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrWorkspaceNotFound
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM snippets WHERE workspace_id = $1", id); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM tag_metadata WHERE workspace_id = $1", id); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM collections WHERE workspace_id = $1", id); err != nil {
+			return err
+		}
+
+		return pruneTags(ctx, tx)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.Sugar().Debugw("deleted workspace", "workspace.id", id)
+
+	return nil
+}
+
+// GetMembership retrieves the membership of the user with userID in the
+// workspace with workspaceID
+func (s *SQLStore) GetMembership(ctx context.Context, workspaceID, userID string) (*models.Membership, error) {
+	return getMembership(ctx, s.db, workspaceID, userID)
+}
+
+// ListMemberships returns every membership of the workspace with workspaceID,
+// oldest first
+func (s *SQLStore) ListMemberships(ctx context.Context, workspaceID string) ([]*models.Membership, error) {
+	if _, err := s.GetWorkspace(ctx, workspaceID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		selectMembershipColumns+" WHERE workspace_id = $1 ORDER BY created_at, user_id", workspaceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []*models.Membership{}
+
+	for rows.Next() {
+		membership, err := scanMembership(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, membership)
+	}
+
+	return results, rows.Err()
+}
+
+// PutMembership adds a member to a workspace or changes their role
+func (s *SQLStore) PutMembership(ctx context.Context, membership *models.Membership) error {
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := getWorkspace(ctx, tx, membership.WorkspaceID); err != nil {
+			return err
+		}
+
+		existing, err := getMembership(ctx, tx, membership.WorkspaceID, membership.UserID)
+		switch {
+		case errors.Is(err, ErrMembershipNotFound):
+			if membership.CreatedAt.IsZero() {
+				membership.CreatedAt = time.Now()
+			}
+
+			_, err = tx.ExecContext(ctx,
+				"INSERT INTO memberships (workspace_id, user_id, role, created_at) VALUES ($1, $2, $3, $4)",
+				membership.WorkspaceID, membership.UserID, membership.Role, membership.CreatedAt.UTC(),
+			)
+
+			return err
+		case err != nil:
+			return err
+		}
+
+		if membership.Role != models.RoleOwner {
+			if err := checkNotLastOwner(ctx, tx, existing); err != nil {
+				return err
+			}
+		}
+
+		// Members keep the time they joined when their role changes
+		membership.CreatedAt = existing.CreatedAt
+
+		_, err = tx.ExecContext(ctx,
+			"UPDATE memberships SET role = $1 WHERE workspace_id = $2 AND user_id = $3",
+			membership.Role, membership.WorkspaceID, membership.UserID,
+		)
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.Sugar().Debugw("membership saved", "workspace.id", membership.WorkspaceID, "user.id", membership.UserID)
+
+	return nil
+}
+
+// DeleteMembership removes the user with userID from the workspace with
+// workspaceID
+func (s *SQLStore) DeleteMembership(ctx context.Context, workspaceID, userID string) error {
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		membership, err := getMembership(ctx, tx, workspaceID, userID)
+		if err != nil {
+			return err
+		}
+
+		if err := checkNotLastOwner(ctx, tx, membership); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx,
+			"DELETE FROM memberships WHERE workspace_id = $1 AND user_id = $2", workspaceID, userID,
+		)
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.Sugar().Debugw("deleted membership", "workspace.id", workspaceID, "user.id", userID)
+
+	return nil
+}
+
+// getWorkspace fetches a single workspace
+func getWorkspace(ctx context.Context, q querier, id string) (*models.Workspace, error) {
+	return scanWorkspace(q.QueryRowContext(ctx, selectWorkspaceColumns+" WHERE id = $1", id))
+}
+
+// getMembership fetches a single membership
+func getMembership(ctx context.Context, q querier, workspaceID, userID string) (*models.Membership, error) {
+	return scanMembership(q.QueryRowContext(ctx,
+		selectMembershipColumns+" WHERE workspace_id = $1 AND user_id = $2", workspaceID, userID,
+	))
+}
+
+// checkNotLastOwner returns ErrLastOwner if membership is the only owner of
+// its workspace
+func checkNotLastOwner(ctx context.Context, q querier, membership *models.Membership) error {
+	if membership.Role != models.RoleOwner {
+		return nil
+	}
+
+	var others int
+
+	err := q.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM memberships
+		WHERE workspace_id = $1 AND role = $2 AND user_id <> $3`,
+		membership.WorkspaceID, models.RoleOwner, membership.UserID,
+	).Scan(&others)
+	if err != nil {
+		return err
+	}
+
+	if others == 0 {
+		return ErrLastOwner
+	}
+
+	return nil
+}
+
+// scanWorkspace scans a row selected with selectWorkspaceColumns
+func scanWorkspace(row rowScanner) (*models.Workspace, error) {
+	workspace := &models.Workspace{}
+
+	err := row.Scan(&workspace.ID, &workspace.Name, &workspace.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWorkspaceNotFound
+		}
+
+		return nil, err
+	}
+
+	return workspace, nil
+}
+
+// scanMembership scans a row selected with selectMembershipColumns
+func scanMembership(row rowScanner) (*models.Membership, error) {
+	membership := &models.Membership{}
+
+	err := row.Scan(&membership.WorkspaceID, &membership.UserID, &membership.Role, &membership.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrMembershipNotFound
+		}
+
+		return nil, err
+	}
+
+	return membership, nil
+}
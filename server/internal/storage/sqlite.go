@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"net/url"
+
+	"go.uber.org/zap"
+
+	// Register the pure-Go "sqlite" driver
+	_ "modernc.org/sqlite"
+)
+
+//go:embed migrations/sqlite/*.sql
+var sqliteMigrations embed.FS
+
+// OpenSQLite opens the SQLite database at path, creating it if it doesn't
+// exist, and migrates it to the latest schema
+func OpenSQLite(ctx context.Context, path string, logger *zap.Logger) (*SQLStore, error) {
+	pragmas := url.Values{}
+	pragmas.Add("_pragma", "foreign_keys(1)")
+	pragmas.Add("_pragma", "busy_timeout(5000)")
+	pragmas.Add("_pragma", "journal_mode(WAL)")
+
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?%s", path, pragmas.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite database: %w", err)
+	}
+
+	// SQLite only supports a single writer, so serialize access through one
+	// connection rather than failing with SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	logger = logger.Named("store")
+
+	if err := migrate(ctx, db, sqliteMigrations, "migrations/sqlite", logger); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("migrate sqlite database: %w", err)
+	}
+
+	logger.Sugar().Debugw("sqlite store initialized", "path", path)
+
+	return &SQLStore{db: db, logger: logger}, nil
+}
@@ -1,297 +1,347 @@
 package storage
 
 import (
+	"context"
 	"errors"
-	"maps"
-	"strings"
-	"sync"
-	"time"
-
 	"slices"
 
+	"github.com/villaleo/cstash/internal/auth"
 	"github.com/villaleo/cstash/internal/models"
-	"go.uber.org/zap"
 )
 
 var (
 	ErrSnippetNotFound = errors.New("snippet not found")
+	ErrSnippetExists   = errors.New("snippet already exists")
+	ErrForbidden       = errors.New("not allowed to modify this snippet")
+	ErrUserNotFound    = errors.New("user not found")
+	ErrUserExists      = errors.New("username already taken")
+	ErrSessionNotFound = errors.New("session not found")
+
+	ErrAccessTokenNotFound = errors.New("access token not found")
+	ErrWorkspaceNotFound   = errors.New("workspace not found")
+	ErrMembershipNotFound  = errors.New("membership not found")
+	ErrLastOwner           = errors.New("a workspace must keep at least one owner")
+	ErrRevisionNotFound    = errors.New("revision not found")
+	ErrVersionMismatch     = errors.New("snippet was changed since the given version")
+	ErrTagNotFound         = errors.New("tag not found")
+	ErrTagExists           = errors.New("tag already exists")
+	ErrTagMergeBelow       = errors.New("a tag can't be merged into one below it")
+	ErrCollectionNotFound  = errors.New("collection not found")
 )
 
-// MemoryStore represents an in-memory storage solution for snippets
-type MemoryStore struct {
-	snippets   map[string]*models.Snippet
-	snippetsMu sync.RWMutex
-	tags       map[string]int
-	tagsMu     sync.RWMutex
-	logger     *zap.Logger
-}
-
-// NewMemoryStore creates a new in-memory store
-func NewMemoryStore(logger *zap.Logger) *MemoryStore {
-	logger.Sugar().Debug("memory store initialized")
-
-	return &MemoryStore{
-		snippets: make(map[string]*models.Snippet),
-		tags:     make(map[string]int),
-		logger:   logger.Named("store"),
-	}
+// Store is implemented by every snippet storage backend. Implementations must
+// be safe for concurrent use and report missing snippets with
+// ErrSnippetNotFound.
+//
+// Every call is made on behalf of the caller identified by
+// auth.IdentityFromContext. Snippets the caller may not view are reported as
+// missing, and changes to snippets the caller may view but not edit fail with
+// ErrForbidden.
+//
+// Calls are also scoped to the workspace set with WithWorkspace, if any. Only
+// the snippets and tags of that workspace are seen, and the caller's role in it
+// decides what they may change. Without a workspace, only personal snippets
+// and their tags are seen.
+//
+// Changes to tags are made to every snippet they apply to at once, and
+// recorded as a new revision of each.
+//
+// Every change to a snippet increments its Version. Changes made with a
+// context from WithIfMatch fail with ErrVersionMismatch unless the snippet is
+// at one of the given versions.
+type Store interface {
+	// CreateSnippet saves a new snippet in the caller's workspace.
+	// ErrSnippetExists is returned if a snippet with the same ID is already
+	// stored.
+	CreateSnippet(ctx context.Context, snippet *models.Snippet) error
+
+	// GetSnippet retrieves a snippet by ID
+	GetSnippet(ctx context.Context, id string) (*models.Snippet, error)
+
+	// UpdateSnippet calls change on a copy of the snippet with id and saves
+	// the result, returning the updated snippet. An error returned by change
+	// is returned as is, and nothing is saved.
+	UpdateSnippet(ctx context.Context, id string, change func(snippet *models.Snippet) error) (*models.Snippet, error)
+
+	// DeleteSnippet removes a snippet by ID
+	DeleteSnippet(ctx context.Context, id string) error
+
+	// ListSnippets returns the page of the snippets selected by filter that
+	// page asks for. ErrInvalidCursor is returned if the page's cursor wasn't
+	// issued for its sort. The error of ctx is returned if it's done before
+	// the search finishes.
+	ListSnippets(ctx context.Context, filter SnippetFilter, page Page) (*SnippetPage, error)
+
+	// ListTags returns every tag of the snippets in the caller's workspace that
+	// they may view, ordered by name, along with how many of those snippets
+	// have it
+	ListTags(ctx context.Context) ([]models.Tag, error)
+
+	// TagTree returns the tags ListTags does arranged in their hierarchy,
+	// along with the tags above them, each level ordered by name
+	TagTree(ctx context.Context) ([]*models.TagNode, error)
+
+	// UpdateTag renames the tag called name to tag.Name on every snippet in
+	// the caller's workspace that they may edit, and sets its description and
+	// color. The tags below it are moved along with it. ErrTagNotFound is
+	// returned if none of those snippets has the tag or one below it, and
+	// ErrTagExists if one of them already has a tag it would be renamed to.
+	UpdateTag(ctx context.Context, name string, tag models.Tag) (*models.Tag, error)
+
+	// MergeTags replaces each of the tags called sources with target on every
+	// snippet in the caller's workspace that they may edit. The tags below a
+	// source are moved below target. Target keeps its description and color,
+	// or takes those of the first source with any. ErrTagNotFound is returned
+	// if none of those snippets has any of sources or a tag below one, and
+	// ErrTagMergeBelow if target is below one of sources.
+	MergeTags(ctx context.Context, sources []string, target string) (*models.Tag, error)
+
+	// DeleteTag removes the tag called name and the tags below it, along with
+	// their descriptions and colors, from every snippet in the caller's
+	// workspace that they may edit. ErrTagNotFound is returned if none of
+	// those snippets has the tag or one below it.
+	DeleteTag(ctx context.Context, name string) error
+
+	// CountLanguages returns how many of the snippets in the caller's
+	// workspace that they may view are written in each language, keyed by the
+	// language as stored. Snippets without a language aren't counted.
+	CountLanguages(ctx context.Context) (map[string]int, error)
+
+	// ListRevisions returns every revision of the snippet with id, newest
+	// first. A revision is recorded each time a snippet is created or changed.
+	ListRevisions(ctx context.Context, id string) ([]*models.Revision, error)
+
+	// GetRevision retrieves revision number of the snippet with id.
+	// ErrRevisionNotFound is returned if the snippet has no such revision.
+	GetRevision(ctx context.Context, id string, number int) (*models.Revision, error)
+
+	// RestoreRevision sets the fields of the snippet with id back to those of
+	// its revision number, recording a new revision, and returns the updated
+	// snippet
+	RestoreRevision(ctx context.Context, id string, number int) (*models.Snippet, error)
 }
 
-// CreateSnippet adds a new snippet to the store
-func (s *MemoryStore) CreateSnippet(snippet *models.Snippet) error {
-	s.snippetsMu.Lock()
-	defer s.snippetsMu.Unlock()
-
-	s.CreateTags(snippet.Tags...)
+// UserStore is implemented by storage backends that keep user accounts and
+// their sessions. Sessions are looked up by the hash of their token.
+type UserStore interface {
+	// CreateUser saves a new user. ErrUserExists is returned if the username,
+	// or the external ID if it's set, is already taken.
+	CreateUser(ctx context.Context, user *models.User) error
 
-	s.logger.Sugar().Debugw("snippet saved", "snippet.id", snippet.ID)
-	s.snippets[snippet.ID] = snippet
-
-	return nil
-}
+	// GetUser retrieves a user by ID
+	GetUser(ctx context.Context, id string) (*models.User, error)
 
-// GetSnippet retrieves a snippet by ID
-func (s *MemoryStore) GetSnippet(id string) (*models.Snippet, error) {
-	sugar := s.logger.Sugar()
+	// GetUserByUsername retrieves a user by username
+	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
 
-	s.snippetsMu.RLock()
-	defer s.snippetsMu.RUnlock()
+	// GetUserByExternalID retrieves a user by their single sign-on identifier
+	GetUserByExternalID(ctx context.Context, externalID string) (*models.User, error)
 
-	snippet, ok := s.snippets[id]
-	if !ok {
-		sugar.Debugw("snippet not found", "snippet.id", id)
-		return nil, ErrSnippetNotFound
-	}
+	// CreateSession saves a new session
+	CreateSession(ctx context.Context, session *models.Session) error
 
-	sugar.Debugw("snippet retreived", "snippet.id", snippet.ID)
+	// GetSession retrieves an unexpired session by token hash.
+	// ErrSessionNotFound is returned if the session doesn't exist or has
+	// expired.
+	GetSession(ctx context.Context, tokenHash string) (*models.Session, error)
 
-	return snippet, nil
+	// DeleteSession removes a session by token hash
+	DeleteSession(ctx context.Context, tokenHash string) error
 }
 
-// UpdateSnippet updates an existing snippet
-func (s *MemoryStore) UpdateSnippet(id string, updates map[string]any) (*models.Snippet, error) {
-	sugar := s.logger.Sugar()
+// TokenStore is implemented by storage backends that keep personal access
+// tokens. Tokens are looked up by the hash of their secret.
+type TokenStore interface {
+	// CreateAccessToken saves a new access token
+	CreateAccessToken(ctx context.Context, token *models.AccessToken) error
 
-	s.snippetsMu.Lock()
-	defer s.snippetsMu.Unlock()
-
-	snippet, ok := s.snippets[id]
-	if !ok {
-		sugar.Debugw("snippet not found", "snippet.id", id)
-		return nil, ErrSnippetNotFound
-	}
+	// GetAccessToken retrieves an access token by hash
+	GetAccessToken(ctx context.Context, tokenHash string) (*models.AccessToken, error)
 
-	sugar.Debugw("updating snippet", "snippet.id", id, "updates", updates)
+	// ListAccessTokens returns every access token owned by the user with userID
+	ListAccessTokens(ctx context.Context, userID string) ([]*models.AccessToken, error)
 
-	if title, ok := updates["title"].(string); ok {
-		snippet.Title = title
-	}
+	// DeleteAccessToken revokes the access token with id owned by the user with
+	// userID
+	DeleteAccessToken(ctx context.Context, userID, id string) error
+}
 
-	if description, ok := updates["description"].(string); ok {
-		snippet.Description = description
-	}
+// WorkspaceStore is implemented by storage backends that keep workspaces and
+// their memberships. Checking that the caller may make a change is left to
+// the caller.
+type WorkspaceStore interface {
+	// CreateWorkspace saves a new workspace and makes the user with ownerID
+	// its owner
+	CreateWorkspace(ctx context.Context, workspace *models.Workspace, ownerID string) error
+
+	// GetWorkspace retrieves a workspace by ID
+	GetWorkspace(ctx context.Context, id string) (*models.Workspace, error)
+
+	// ListWorkspaces returns every workspace the user with userID is a member
+	// of
+	ListWorkspaces(ctx context.Context, userID string) ([]*models.Workspace, error)
+
+	// DeleteWorkspace removes a workspace along with its memberships and
+	// snippets
+	DeleteWorkspace(ctx context.Context, id string) error
+
+	// GetMembership retrieves the membership of the user with userID in the
+	// workspace with workspaceID
+	GetMembership(ctx context.Context, workspaceID, userID string) (*models.Membership, error)
+
+	// ListMemberships returns every membership of the workspace with
+	// workspaceID
+	ListMemberships(ctx context.Context, workspaceID string) ([]*models.Membership, error)
+
+	// PutMembership adds a member to a workspace or changes their role.
+	// ErrLastOwner is returned if the change would leave the workspace without
+	// an owner.
+	PutMembership(ctx context.Context, membership *models.Membership) error
+
+	// DeleteMembership removes the user with userID from the workspace with
+	// workspaceID. ErrLastOwner is returned if they are its only owner.
+	DeleteMembership(ctx context.Context, workspaceID, userID string) error
+}
 
-	if content, ok := updates["content"].(string); ok {
-		snippet.Content = content
-	}
+// CollectionStore is implemented by storage backends that keep collections of
+// snippets. Like Store calls, every call is made on behalf of the caller and
+// scoped to their workspace: collections the caller may not view are
+// reported as missing with ErrCollectionNotFound, and changes they may not
+// make fail with ErrForbidden. Personal collections are only visible to their
+// owner.
+//
+// Deleting a snippet removes it from every collection it's in.
+type CollectionStore interface {
+	// CreateCollection saves a new collection in the caller's workspace.
+	// ErrSnippetNotFound is returned if it holds a snippet the caller may not
+	// view there.
+	CreateCollection(ctx context.Context, collection *models.Collection) error
+
+	// GetCollection retrieves a collection by ID
+	GetCollection(ctx context.Context, id string) (*models.Collection, error)
+
+	// ListCollections returns every collection in the caller's workspace that
+	// they may view, oldest first
+	ListCollections(ctx context.Context) ([]*models.Collection, error)
+
+	// UpdateCollection calls change on a copy of the collection with id and
+	// saves the result, returning the updated collection. An error returned by
+	// change is returned as is, and nothing is saved. ErrSnippetNotFound is
+	// returned if change adds a snippet the caller may not view in their
+	// workspace.
+	UpdateCollection(ctx context.Context, id string, change func(collection *models.Collection) error) (*models.Collection, error)
+
+	// DeleteCollection removes a collection by ID. Its snippets are kept.
+	DeleteCollection(ctx context.Context, id string) error
+
+	// ListCollectionSnippets returns the snippets in the collection with id
+	// that the caller may view, in the collection's order
+	ListCollectionSnippets(ctx context.Context, id string) ([]*models.Snippet, error)
+}
 
-	if language, ok := updates["language"].(string); ok {
-		snippet.Language = language
-	}
+// countLanguages counts the snippets the caller making the call with ctx may
+// view by language
+func countLanguages(ctx context.Context, snippets []*models.Snippet) map[string]int {
+	counts := make(map[string]int)
 
-	// Check for the interface{} first, then type cast to []string
-	if tagsInterface, ok := updates["tags"]; ok {
-		if tagsSlice, ok := tagsInterface.([]any); ok {
-			tags := make([]string, len(tagsSlice))
-			for i, t := range tagsSlice {
-				if str, ok := t.(string); ok {
-					tags[i] = str
-				}
-			}
-
-			s.updateTags( /* old tags */ snippet.Tags /* new tags */, tags)
-			snippet.Tags = tags
+	for _, snippet := range snippets {
+		if snippet.Language != "" && canView(ctx, snippet) {
+			counts[snippet.Language]++
 		}
 	}
 
-	if isFavorite, ok := updates["isFavorite"].(bool); ok {
-		snippet.IsFavorite = isFavorite
-	}
-
-	snippet.UpdatedAt = time.Now()
-	sugar.Debugw("snippet updated", "snippet.id", snippet.ID, "updates", updates)
-
-	return snippet, nil
+	return counts
 }
 
-// DeleteSnippet removes a snippet from the store
-func (s *MemoryStore) DeleteSnippet(id string) error {
-	sugar := s.logger.Sugar()
-
-	s.snippetsMu.Lock()
-	defer s.snippetsMu.Unlock()
+// newRevisions returns the revisions to record for the caller changing before
+// into after, where count revisions of the snippet are already recorded.
+// Snippets saved before revisions were recorded first get a revision of how
+// they were before the change, credited to their owner.
+func newRevisions(ctx context.Context, before, after *models.Snippet, count int) []*models.Revision {
+	var revisions []*models.Revision
 
-	snippet, ok := s.snippets[id]
-	if !ok {
-		sugar.Debugw("snippet not found", "snippet.id", id)
-		return ErrSnippetNotFound
+	if count == 0 {
+		revisions = append(revisions, models.NewRevision(before, 1, before.OwnerID))
+		count++
 	}
 
-	s.DeleteTags(snippet.Tags...)
-
-	sugar.Debugw("deleted snippet", "snippet.id", id)
-	delete(s.snippets, id)
-
-	return nil
+	return append(revisions, models.NewRevision(after, count+1, callerID(ctx)))
 }
 
-// ListSnippets returns all snippets, optionally filtered by tags or a query
-func (s *MemoryStore) ListSnippets(tags []string, query string) []*models.Snippet {
-	s.snippetsMu.RLock()
-	defer s.snippetsMu.RUnlock()
+// callerID returns the ID of the user making the call with ctx, or an empty
+// string if the caller is anonymous
+func callerID(ctx context.Context) string {
+	id, _ := auth.IdentityFromContext(ctx)
 
-	var (
-		results = make([]*models.Snippet, 0, len(s.snippets))
-		sugar   = s.logger.Sugar()
-	)
-
-	// Sanitize the search query
-	query = strings.ToLower(strings.TrimSpace(query))
-
-	if tags == nil && query == "" {
-		return slices.Collect(maps.Values(s.snippets))
-	}
-
-	for _, snippet := range s.snippets {
-		if hasAnyTag(snippet, tags) {
-			results = append(results, snippet)
-			continue
-		}
-
-		if anyFieldContainsQuery(snippet, query) {
-			results = append(results, snippet)
-			continue
-		}
-	}
-
-	sugar.Debugw("fetched snippets", "count", len(results), "tags", tags, "query", query)
-
-	return results
+	return id.UserID
 }
 
-// CreateTags adds new tags to the store, incrementing its reference counter by 1
-func (s *MemoryStore) CreateTags(tags ...string) {
-	s.tagsMu.Lock()
-	defer s.tagsMu.Unlock()
+// workspaceKey is the context key under which the caller's workspace
+// membership is stored
+type workspaceKey struct{}
 
-	for _, tag := range tags {
-		if _, ok := s.tags[tag]; !ok {
-			s.tags[tag] += 1
-			s.logger.Sugar().Debugw("tag saved", "tag", tag)
-		}
-	}
+// WithWorkspace returns a copy of ctx that scopes Store calls to the workspace
+// of membership, made with the role it grants
+func WithWorkspace(ctx context.Context, membership *models.Membership) context.Context {
+	return context.WithValue(ctx, workspaceKey{}, membership)
 }
 
-// ListTags fetches all tags in the store with a valid reference count
-func (s *MemoryStore) ListTags() []string {
-	s.tagsMu.RLock()
-	defer s.tagsMu.RUnlock()
+// workspaceMembership returns the membership stored in ctx with
+// WithWorkspace, or nil if calls aren't scoped to a workspace
+func workspaceMembership(ctx context.Context) *models.Membership {
+	membership, _ := ctx.Value(workspaceKey{}).(*models.Membership)
 
-	var (
-		sugar   = s.logger.Sugar()
-		results = []string{}
-	)
+	return membership
+}
 
-	for key, value := range s.tags {
-		if value >= 1 {
-			results = append(results, key)
-		}
+// workspaceID returns the ID of the workspace calls made with ctx are scoped
+// to, or an empty string for personal snippets
+func workspaceID(ctx context.Context) string {
+	if membership := workspaceMembership(ctx); membership != nil {
+		return membership.WorkspaceID
 	}
 
-	sugar.Debugw("fetched tags", "count", len(results))
-
-	return results
+	return ""
 }
 
-// DeleteTags deletes tags with a non-zero reference count. If a tag is
-// references by at least one or more snippets, it won't be deleted
-func (s *MemoryStore) DeleteTags(tag ...string) {
-	s.tagsMu.Lock()
-	defer s.tagsMu.Unlock()
-
-	for tag, count := range s.tags {
-		// Decrement a tag's reference counter if it has a non-zero reference count
-		if count >= 1 {
-			s.tags[tag]--
+// ifMatchKey is the context key under which the versions a change is
+// conditional on are stored
+type ifMatchKey struct{}
 
-			return
-		}
-
-		delete(s.tags, tag)
-	}
+// WithIfMatch returns a copy of ctx that makes changes to a snippet
+// conditional on it being at one of versions. No change is made if versions
+// is empty.
+func WithIfMatch(ctx context.Context, versions []int64) context.Context {
+	return context.WithValue(ctx, ifMatchKey{}, versions)
 }
 
-// updateTags updates tags depending on the differences between old and changes.
-//
-// If a value is in changes and not in old, then the tag will be added to the
-// store. Otherwise, if a value is not in changes and in old, then the tag will
-// be removed.
-func (s *MemoryStore) updateTags(old, changes []string) {
-	s.tagsMu.Lock()
-	defer s.tagsMu.Unlock()
-
-	var (
-		oldSet            = make(map[string]struct{})
-		changeSet         = make(map[string]struct{})
-		markedForDeletion = []string{}
-	)
-
-	// Create a set from the old set of tags
-	for _, tag := range old {
-		oldSet[tag] = struct{}{}
-	}
-
-	// Create a set from the new tag changes
-	for _, change := range changes {
-		changeSet[change] = struct{}{}
+// checkVersion returns ErrVersionMismatch if the change made with ctx is
+// conditional on snippet being at another version
+func checkVersion(ctx context.Context, snippet *models.Snippet) error {
+	versions, ok := ctx.Value(ifMatchKey{}).([]int64)
+	if ok && !slices.Contains(versions, snippet.Version) {
+		return ErrVersionMismatch
 	}
 
-	for tag := range oldSet {
-		// If a tag is in old but not in changes, then it will be removed
-		if _, ok := changeSet[tag]; !ok {
-			markedForDeletion = append(markedForDeletion, tag)
-		} else {
-			s.CreateTags(tag)
-		}
-	}
-
-	s.DeleteTags(markedForDeletion...)
+	return nil
 }
 
-// hasAnyTag reports whether snippet contains any tags
-func hasAnyTag(snippet *models.Snippet, tags []string) bool {
-	for _, searchTag := range tags {
-		if slices.Contains(snippet.Tags, searchTag) {
-			return true
-		}
+// canView reports whether the caller making the call with ctx may view snippet
+func canView(ctx context.Context, snippet *models.Snippet) bool {
+	if snippet.WorkspaceID != workspaceID(ctx) {
+		return false
 	}
 
-	return false
+	// Every member of a workspace may view its snippets
+	return snippet.WorkspaceID != "" || snippet.VisibleTo(callerID(ctx))
 }
 
-// anyFieldContainsQuery reports whether any field in snippet contains query
-func anyFieldContainsQuery(snippet *models.Snippet, query string) bool {
-	if query == "" {
-		return false
+// canEdit reports whether the caller making the call with ctx may change
+// snippet, which they may view
+func canEdit(ctx context.Context, snippet *models.Snippet) bool {
+	if membership := workspaceMembership(ctx); membership != nil {
+		return membership.Role.CanEdit()
 	}
 
-	return containsIgnoreCase(snippet.Title, query) ||
-		containsIgnoreCase(snippet.Content, query) ||
-		containsIgnoreCase(snippet.Description, query) ||
-		containsIgnoreCase(snippet.Language, query)
-}
-
-// containsIgnoreCase reports whether text contains substr, ignoring casing
-func containsIgnoreCase(text, substr string) bool {
-	return strings.Contains(strings.ToLower(text), strings.ToLower(substr))
+	return snippet.EditableBy(callerID(ctx))
 }
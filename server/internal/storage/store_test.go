@@ -0,0 +1,260 @@
+package storage_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/villaleo/cstash/internal/auth"
+	"github.com/villaleo/cstash/internal/models"
+	"github.com/villaleo/cstash/internal/storage"
+	"github.com/villaleo/cstash/internal/storage/storetest"
+	"go.uber.org/zap"
+)
+
+func TestMemoryStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storage.Store {
+		return storage.NewMemoryStore(zap.NewNop())
+	})
+}
+
+func TestDurableMemoryStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storage.Store {
+		store, err := storage.OpenMemoryStore(t.TempDir(), zap.NewNop())
+		if err != nil {
+			t.Fatalf("OpenMemoryStore() = %v", err)
+		}
+
+		t.Cleanup(func() { _ = store.Close() })
+
+		return store
+	})
+}
+
+// durableState is the state of a durable memory store that must survive it
+// being reopened, encoded as JSON so that it compares equal however its times
+// were decoded
+type durableState struct {
+	Snippets  map[string]string
+	Revisions map[string]string
+	Tags      string
+}
+
+// stateOf returns the state of the snippets with ids in store, which must all
+// exist
+func stateOf(t *testing.T, ctx context.Context, store *storage.MemoryStore, ids ...string) durableState {
+	t.Helper()
+
+	state := durableState{Snippets: map[string]string{}, Revisions: map[string]string{}}
+
+	for _, id := range ids {
+		snippet, err := store.GetSnippet(ctx, id)
+		if err != nil {
+			t.Fatalf("GetSnippet(%q) = %v", id, err)
+		}
+
+		revisions, err := store.ListRevisions(ctx, id)
+		if err != nil {
+			t.Fatalf("ListRevisions(%q) = %v", id, err)
+		}
+
+		state.Snippets[id] = encode(t, snippet)
+		state.Revisions[id] = encode(t, revisions)
+	}
+
+	tags, err := store.ListTags(ctx)
+	if err != nil {
+		t.Fatalf("ListTags() = %v", err)
+	}
+
+	state.Tags = encode(t, tags)
+
+	return state
+}
+
+// encode returns v encoded as JSON
+func encode(t *testing.T, v any) string {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("encode %T: %v", v, err)
+	}
+
+	return string(data)
+}
+
+func TestDurableMemoryStoreReopens(t *testing.T) {
+	var (
+		ctx = auth.WithIdentity(context.Background(), auth.Identity{UserID: "alice"})
+		dir = t.TempDir()
+	)
+
+	// The store is never closed, as if the server had crashed, since closing
+	// it would take a snapshot and leave no log to replay
+	store, err := storage.OpenMemoryStore(dir, zap.NewNop())
+	if err != nil {
+		t.Fatalf("OpenMemoryStore() = %v", err)
+	}
+
+	var (
+		kept    = models.NewSnippet("Kept", "fmt.Println()", "go")
+		changed = models.NewSnippet("Changed", "SELECT 1", "sql")
+		deleted = models.NewSnippet("Deleted", "echo", "bash")
+	)
+
+	kept.AddTags("lang/go")
+	changed.AddTags("lang/go", "db")
+	deleted.AddTags("db")
+
+	for _, snippet := range []*models.Snippet{kept, changed, deleted} {
+		snippet.OwnerID = "alice"
+
+		if err := store.CreateSnippet(ctx, snippet); err != nil {
+			t.Fatalf("CreateSnippet(%q) = %v", snippet.Title, err)
+		}
+	}
+
+	if _, err := store.UpdateTag(ctx, "db", models.Tag{Name: "db", Description: "Databases", Color: "#336791"}); err != nil {
+		t.Fatalf("UpdateTag(db) = %v", err)
+	}
+
+	var (
+		now = time.Now()
+		// Sessions by when they're created and whether they're signed out
+		snapshotted = &models.Session{TokenHash: "snapshotted", UserID: "alice", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+		logged      = &models.Session{TokenHash: "logged", UserID: "alice", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+		signedOut   = &models.Session{TokenHash: "signed-out", UserID: "alice", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	)
+
+	for _, session := range []*models.Session{snapshotted, signedOut} {
+		if err := store.CreateSession(ctx, session); err != nil {
+			t.Fatalf("CreateSession(%q) = %v", session.TokenHash, err)
+		}
+	}
+
+	if err := store.Snapshot(); err != nil {
+		t.Fatalf("Snapshot() = %v", err)
+	}
+
+	// Everything from here on is only in the log
+	_, err = store.UpdateSnippet(ctx, changed.ID, func(snippet *models.Snippet) error {
+		snippet.Title = "Changed twice"
+		snippet.AddTags("db/postgres")
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateSnippet() = %v", err)
+	}
+
+	if err := store.DeleteSnippet(ctx, deleted.ID); err != nil {
+		t.Fatalf("DeleteSnippet() = %v", err)
+	}
+
+	if _, err := store.UpdateTag(ctx, "lang/go", models.Tag{Name: "golang", Color: "#00add8"}); err != nil {
+		t.Fatalf("UpdateTag(lang/go) = %v", err)
+	}
+
+	if err := store.CreateSession(ctx, logged); err != nil {
+		t.Fatalf("CreateSession(%q) = %v", logged.TokenHash, err)
+	}
+
+	if err := store.DeleteSession(ctx, signedOut.TokenHash); err != nil {
+		t.Fatalf("DeleteSession() = %v", err)
+	}
+
+	want := stateOf(t, ctx, store, kept.ID, changed.ID)
+
+	walPath := filepath.Join(dir, "wal.log")
+
+	info, err := os.Stat(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A crash in the middle of an append leaves a partial entry behind
+	wal, err := os.OpenFile(walPath, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := wal.WriteString(`{"op":"put","id":"torn","snippet":{"id":"torn","ti`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := wal.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := storage.OpenMemoryStore(dir, zap.NewNop())
+	if err != nil {
+		t.Fatalf("OpenMemoryStore() after a torn write = %v", err)
+	}
+
+	t.Cleanup(func() { _ = reopened.Close() })
+
+	got := stateOf(t, ctx, reopened, kept.ID, changed.ID)
+
+	for id, snippet := range want.Snippets {
+		if got.Snippets[id] != snippet {
+			t.Errorf("reopened snippet = %s, want %s", got.Snippets[id], snippet)
+		}
+
+		if got.Revisions[id] != want.Revisions[id] {
+			t.Errorf("reopened revisions = %s, want %s", got.Revisions[id], want.Revisions[id])
+		}
+	}
+
+	if got.Tags != want.Tags {
+		t.Errorf("reopened tags = %s, want %s", got.Tags, want.Tags)
+	}
+
+	for _, id := range []string{deleted.ID, "torn"} {
+		if _, err := reopened.GetSnippet(ctx, id); !errors.Is(err, storage.ErrSnippetNotFound) {
+			t.Errorf("GetSnippet(%q) = %v, want %v", id, err, storage.ErrSnippetNotFound)
+		}
+	}
+
+	for _, session := range []*models.Session{snapshotted, logged} {
+		got, err := reopened.GetSession(ctx, session.TokenHash)
+		if err != nil {
+			t.Errorf("GetSession(%q) = %v", session.TokenHash, err)
+			continue
+		}
+
+		if got.UserID != session.UserID || !got.ExpiresAt.Equal(session.ExpiresAt) {
+			t.Errorf("GetSession(%q) = %+v, want %+v", session.TokenHash, got, session)
+		}
+	}
+
+	if _, err := reopened.GetSession(ctx, signedOut.TokenHash); !errors.Is(err, storage.ErrSessionNotFound) {
+		t.Errorf("GetSession(%q) = %v, want %v", signedOut.TokenHash, err, storage.ErrSessionNotFound)
+	}
+
+	after, err := os.Stat(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if after.Size() != info.Size() {
+		t.Errorf("write-ahead log is %d bytes after reopening, want the torn entry truncated to %d", after.Size(), info.Size())
+	}
+}
+
+func TestSQLiteStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storage.Store {
+		store, err := storage.OpenSQLite(context.Background(), filepath.Join(t.TempDir(), "cstash.db"), zap.NewNop())
+		if err != nil {
+			t.Fatalf("OpenSQLite() = %v", err)
+		}
+
+		t.Cleanup(func() { _ = store.Close() })
+
+		return store
+	})
+}
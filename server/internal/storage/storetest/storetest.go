@@ -0,0 +1,486 @@
+// Package storetest provides a suite of tests that every storage.Store
+// implementation must pass
+package storetest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/villaleo/cstash/internal/auth"
+	"github.com/villaleo/cstash/internal/models"
+	"github.com/villaleo/cstash/internal/search"
+	"github.com/villaleo/cstash/internal/storage"
+)
+
+// OpenFunc opens an empty store for a single test. The store must be closed
+// when t ends, if it needs to be.
+type OpenFunc func(t *testing.T) storage.Store
+
+// Run runs the suite against the stores opened with open
+func Run(t *testing.T, open OpenFunc) {
+	t.Run("CRUD", func(t *testing.T) { testCRUD(t, open) })
+	t.Run("ConcurrentCreates", func(t *testing.T) { testConcurrentCreates(t, open) })
+	t.Run("ConcurrentRegistrations", func(t *testing.T) { testConcurrentRegistrations(t, open) })
+	t.Run("Versions", func(t *testing.T) { testVersions(t, open) })
+	t.Run("Visibility", func(t *testing.T) { testVisibility(t, open) })
+	t.Run("Filters", func(t *testing.T) { testFilters(t, open) })
+	t.Run("Pages", func(t *testing.T) { testPages(t, open) })
+}
+
+// Callers the suite makes calls on behalf of
+var (
+	alice = auth.WithIdentity(context.Background(), auth.Identity{UserID: "alice"})
+	bob   = auth.WithIdentity(context.Background(), auth.Identity{UserID: "bob"})
+	// anonymous isn't signed in
+	anonymous = context.Background()
+)
+
+// fixture is a snippet the filter and page tests list
+type fixture struct {
+	title, content string
+	tags           []string
+	// created and updated are offsets from the same base time, so that the
+	// snippets sort the same way in every store
+	created, updated time.Duration
+}
+
+var fixtures = []fixture{
+	{title: "Delta", content: "select * from users", tags: []string{"lang/go", "db"}, created: 1 * time.Second, updated: 3 * time.Second},
+	{title: "alpha", content: "fn main() {}", tags: []string{"lang/rust"}, created: 2 * time.Second, updated: 5 * time.Second},
+	{title: "Charlie", content: "SELECT 1", tags: []string{"lang", "db/sql"}, created: 3 * time.Second, updated: 1 * time.Second},
+	{title: "bravo", content: "plain text", tags: []string{"language"}, created: 4 * time.Second, updated: 4 * time.Second},
+	{title: "Echo", content: "func main() {}", tags: []string{}, created: 5 * time.Second, updated: 2 * time.Second},
+}
+
+// newSnippet returns a new snippet owned by alice
+func newSnippet(title string, tags ...string) *models.Snippet {
+	snippet := models.NewSnippet(title, "content", "go")
+	snippet.OwnerID = "alice"
+	snippet.AddTags(tags...)
+
+	return snippet
+}
+
+// createFixtures saves fixtures in store
+func createFixtures(t *testing.T, store storage.Store) {
+	t.Helper()
+
+	base := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, f := range fixtures {
+		snippet := newSnippet(f.title, f.tags...)
+		snippet.Content = f.content
+		snippet.CreatedAt = base.Add(f.created)
+		snippet.UpdatedAt = base.Add(f.updated)
+
+		if err := store.CreateSnippet(alice, snippet); err != nil {
+			t.Fatalf("CreateSnippet(%q) = %v", f.title, err)
+		}
+	}
+}
+
+// titles returns the titles of hits in order
+func titles(hits []*storage.SnippetHit) []string {
+	results := []string{}
+	for _, hit := range hits {
+		results = append(results, hit.Title)
+	}
+
+	return results
+}
+
+func testCRUD(t *testing.T, open OpenFunc) {
+	store := open(t)
+	snippet := newSnippet("first", "go", "db")
+
+	if err := store.CreateSnippet(alice, snippet); err != nil {
+		t.Fatalf("CreateSnippet() = %v", err)
+	}
+
+	if err := store.CreateSnippet(alice, snippet); !errors.Is(err, storage.ErrSnippetExists) {
+		t.Errorf("CreateSnippet() again = %v, want %v", err, storage.ErrSnippetExists)
+	}
+
+	got, err := store.GetSnippet(alice, snippet.ID)
+	if err != nil {
+		t.Fatalf("GetSnippet() = %v", err)
+	}
+
+	if got.Title != "first" || !slices.Equal(got.Tags, []string{"go", "db"}) || got.Version != 1 {
+		t.Errorf("GetSnippet() = %q %q v%d, want \"first\" [go db] v1", got.Title, got.Tags, got.Version)
+	}
+
+	updated, err := store.UpdateSnippet(alice, snippet.ID, func(snippet *models.Snippet) error {
+		snippet.Title = "second"
+		snippet.Tags = []string{"db"}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateSnippet() = %v", err)
+	}
+
+	if updated.Title != "second" || updated.Version != 2 {
+		t.Errorf("UpdateSnippet() = %q v%d, want \"second\" v2", updated.Title, updated.Version)
+	}
+
+	errChange := errors.New("change failed")
+
+	_, err = store.UpdateSnippet(alice, snippet.ID, func(snippet *models.Snippet) error {
+		snippet.Title = "lost"
+		return errChange
+	})
+	if !errors.Is(err, errChange) {
+		t.Errorf("UpdateSnippet() with a failing change = %v, want %v", err, errChange)
+	}
+
+	got, err = store.GetSnippet(alice, snippet.ID)
+	if err != nil {
+		t.Fatalf("GetSnippet() = %v", err)
+	}
+
+	if got.Title != "second" || !slices.Equal(got.Tags, []string{"db"}) || got.Version != 2 {
+		t.Errorf("GetSnippet() after updates = %q %q v%d, want \"second\" [db] v2", got.Title, got.Tags, got.Version)
+	}
+
+	if err := store.DeleteSnippet(alice, snippet.ID); err != nil {
+		t.Fatalf("DeleteSnippet() = %v", err)
+	}
+
+	if _, err := store.GetSnippet(alice, snippet.ID); !errors.Is(err, storage.ErrSnippetNotFound) {
+		t.Errorf("GetSnippet() after delete = %v, want %v", err, storage.ErrSnippetNotFound)
+	}
+
+	if err := store.DeleteSnippet(alice, snippet.ID); !errors.Is(err, storage.ErrSnippetNotFound) {
+		t.Errorf("DeleteSnippet() again = %v, want %v", err, storage.ErrSnippetNotFound)
+	}
+
+	_, err = store.UpdateSnippet(alice, snippet.ID, func(*models.Snippet) error { return nil })
+	if !errors.Is(err, storage.ErrSnippetNotFound) {
+		t.Errorf("UpdateSnippet() after delete = %v, want %v", err, storage.ErrSnippetNotFound)
+	}
+}
+
+// concurrency is how many goroutines the concurrency tests race
+const concurrency = 8
+
+// race calls f from concurrency goroutines at once and returns their errors
+func race(f func() error) []error {
+	var (
+		errs  = make([]error, concurrency)
+		start = make(chan struct{})
+		wg    sync.WaitGroup
+	)
+
+	for i := range concurrency {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			<-start
+			errs[i] = f()
+		}()
+	}
+
+	close(start)
+	wg.Wait()
+
+	return errs
+}
+
+// checkOneCreated fails t unless exactly one of errs is nil and the rest are
+// errExists
+func checkOneCreated(t *testing.T, errs []error, errExists error) {
+	t.Helper()
+
+	created := 0
+
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, errExists):
+			t.Errorf("concurrent create = %v, want nil or %v", err, errExists)
+		}
+	}
+
+	if created != 1 {
+		t.Errorf("%d concurrent creates succeeded, want 1", created)
+	}
+}
+
+func testConcurrentCreates(t *testing.T, open OpenFunc) {
+	store := open(t)
+	id := newSnippet("racing").ID
+
+	errs := race(func() error {
+		snippet := newSnippet("racing", "go")
+		snippet.ID = id
+
+		return store.CreateSnippet(alice, snippet)
+	})
+
+	checkOneCreated(t, errs, storage.ErrSnippetExists)
+}
+
+func testConcurrentRegistrations(t *testing.T, open OpenFunc) {
+	store, ok := open(t).(storage.UserStore)
+	if !ok {
+		t.Skip("store doesn't keep users")
+	}
+
+	t.Run("with a username", func(t *testing.T) {
+		errs := race(func() error {
+			return store.CreateUser(context.Background(), models.NewUser("carol", "hash"))
+		})
+
+		checkOneCreated(t, errs, storage.ErrUserExists)
+	})
+
+	// Users signing in with single sign-on for the first time may race for the
+	// same external ID under different usernames
+	t.Run("with an external ID", func(t *testing.T) {
+		errs := race(func() error {
+			user := models.NewUser("dave-"+strings.ToLower(auth.NewSecureID()), "")
+			user.ExternalID = "https://issuer.test|dave"
+
+			return store.CreateUser(context.Background(), user)
+		})
+
+		checkOneCreated(t, errs, storage.ErrUserExists)
+	})
+}
+
+func testVersions(t *testing.T, open OpenFunc) {
+	store := open(t)
+	snippet := newSnippet("versioned")
+
+	if err := store.CreateSnippet(alice, snippet); err != nil {
+		t.Fatalf("CreateSnippet() = %v", err)
+	}
+
+	rename := func(snippet *models.Snippet) error {
+		snippet.Title += "!"
+		return nil
+	}
+
+	tests := []struct {
+		name     string
+		versions []int64
+		change   func(ctx context.Context) error
+		want     error
+	}{
+		{"update at the current version", []int64{1}, func(ctx context.Context) error {
+			_, err := store.UpdateSnippet(ctx, snippet.ID, rename)
+			return err
+		}, nil},
+		{"update at a stale version", []int64{1}, func(ctx context.Context) error {
+			_, err := store.UpdateSnippet(ctx, snippet.ID, rename)
+			return err
+		}, storage.ErrVersionMismatch},
+		{"update at any of several versions", []int64{1, 2}, func(ctx context.Context) error {
+			_, err := store.UpdateSnippet(ctx, snippet.ID, rename)
+			return err
+		}, nil},
+		{"delete at a stale version", []int64{2}, func(ctx context.Context) error {
+			return store.DeleteSnippet(ctx, snippet.ID)
+		}, storage.ErrVersionMismatch},
+		{"delete at the current version", []int64{3}, func(ctx context.Context) error {
+			return store.DeleteSnippet(ctx, snippet.ID)
+		}, nil},
+	}
+
+	for _, tt := range tests {
+		if err := tt.change(storage.WithIfMatch(alice, tt.versions)); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func testVisibility(t *testing.T, open OpenFunc) {
+	store := open(t)
+
+	private := newSnippet("private")
+	team := newSnippet("team")
+	team.Visibility = models.VisibilityTeam
+	public := newSnippet("public")
+	public.Visibility = models.VisibilityPublic
+
+	for _, snippet := range []*models.Snippet{private, team, public} {
+		if err := store.CreateSnippet(alice, snippet); err != nil {
+			t.Fatalf("CreateSnippet(%q) = %v", snippet.Title, err)
+		}
+	}
+
+	if _, err := store.GetSnippet(bob, private.ID); !errors.Is(err, storage.ErrSnippetNotFound) {
+		t.Errorf("GetSnippet() of another user's private snippet = %v, want %v", err, storage.ErrSnippetNotFound)
+	}
+
+	err := store.DeleteSnippet(bob, team.ID)
+	if !errors.Is(err, storage.ErrForbidden) {
+		t.Errorf("DeleteSnippet() of another user's team snippet = %v, want %v", err, storage.ErrForbidden)
+	}
+
+	page, err := store.ListSnippets(bob, storage.SnippetFilter{}, storage.Page{})
+	if err != nil {
+		t.Fatalf("ListSnippets() = %v", err)
+	}
+
+	if got := titles(page.Snippets); !slices.Equal(got, []string{"team", "public"}) || page.Total != 2 {
+		t.Errorf("ListSnippets() as another user = %q of %d, want [team public] of 2", got, page.Total)
+	}
+
+	if _, err := store.GetSnippet(anonymous, team.ID); !errors.Is(err, storage.ErrSnippetNotFound) {
+		t.Errorf("GetSnippet() of a team snippet when not signed in = %v, want %v", err, storage.ErrSnippetNotFound)
+	}
+
+	if _, err := store.GetSnippet(anonymous, public.ID); err != nil {
+		t.Errorf("GetSnippet() of a public snippet when not signed in = %v", err)
+	}
+
+	page, err = store.ListSnippets(anonymous, storage.SnippetFilter{}, storage.Page{})
+	if err != nil {
+		t.Fatalf("ListSnippets() = %v", err)
+	}
+
+	if got := titles(page.Snippets); !slices.Equal(got, []string{"public"}) || page.Total != 1 {
+		t.Errorf("ListSnippets() when not signed in = %q of %d, want [public] of 1", got, page.Total)
+	}
+}
+
+func testFilters(t *testing.T, open OpenFunc) {
+	store := open(t)
+	createFixtures(t, store)
+
+	query := func(q string) search.Node {
+		node, err := search.ParseQuery(q)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q) = %v", q, err)
+		}
+
+		return node
+	}
+
+	regex := func(pattern string) search.Pattern {
+		p, err := search.NewRegex(pattern)
+		if err != nil {
+			t.Fatalf("NewRegex(%q) = %v", pattern, err)
+		}
+
+		return p
+	}
+
+	tests := []struct {
+		name   string
+		filter storage.SnippetFilter
+		want   []string
+	}{
+		{"everything", storage.SnippetFilter{}, []string{"Delta", "alpha", "Charlie", "bravo", "Echo"}},
+		{"a tag and those below it", storage.SnippetFilter{Tags: []string{"lang"}}, []string{"Delta", "alpha", "Charlie"}},
+		{"a tag in another case", storage.SnippetFilter{Tags: []string{"LANG/go"}}, []string{"Delta"}},
+		{"any of tags", storage.SnippetFilter{Tags: []string{"lang/go", "language"}}, []string{"Delta", "bravo"}},
+		{"all of tags", storage.SnippetFilter{Tags: []string{"lang", "db"}, TagMode: storage.TagModeAll}, []string{"Delta", "Charlie"}},
+		{"all of a tag and one below it", storage.SnippetFilter{Tags: []string{"lang", "lang/rust"}, TagMode: storage.TagModeAll}, []string{"alpha"}},
+		{"none of tags", storage.SnippetFilter{NotTags: []string{"lang", "language"}}, []string{"Echo"}},
+		{"a tag but not one below it", storage.SnippetFilter{Tags: []string{"lang"}, NotTags: []string{"lang/go"}}, []string{"alpha", "Charlie"}},
+		{"a missing tag", storage.SnippetFilter{Tags: []string{"missing"}}, []string{}},
+		{"a query", storage.SnippetFilter{Query: query("main")}, []string{"alpha", "Echo"}},
+		{"a query and tags", storage.SnippetFilter{Tags: []string{"lang"}, Query: query("main")}, []string{"alpha"}},
+		{"a query for tags", storage.SnippetFilter{Query: query("tag:db -tag:lang/go")}, []string{"Charlie"}},
+		{"a pattern", storage.SnippetFilter{Pattern: regex(`(?i)select`)}, []string{"Delta", "Charlie"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := store.ListSnippets(alice, tt.filter, storage.Page{Sort: storage.SortCreatedAt})
+			if err != nil {
+				t.Fatalf("ListSnippets() = %v", err)
+			}
+
+			if got := titles(page.Snippets); !slices.Equal(got, tt.want) || page.Total != len(tt.want) {
+				t.Errorf("ListSnippets() = %q of %d, want %q", got, page.Total, tt.want)
+			}
+		})
+	}
+}
+
+func testPages(t *testing.T, open OpenFunc) {
+	store := open(t)
+	createFixtures(t, store)
+
+	tests := []struct {
+		name   string
+		filter storage.SnippetFilter
+		page   storage.Page
+		want   []string
+	}{
+		{"by creation", storage.SnippetFilter{}, storage.Page{}, []string{"Delta", "alpha", "Charlie", "bravo", "Echo"}},
+		{"by creation, newest first", storage.SnippetFilter{}, storage.Page{Order: storage.OrderDesc}, []string{"Echo", "bravo", "Charlie", "alpha", "Delta"}},
+		{"by update", storage.SnippetFilter{}, storage.Page{Sort: storage.SortUpdatedAt}, []string{"Charlie", "Echo", "Delta", "bravo", "alpha"}},
+		{"by title ignoring case", storage.SnippetFilter{}, storage.Page{Sort: storage.SortTitle}, []string{"alpha", "bravo", "Charlie", "Delta", "Echo"}},
+		{"by title, descending", storage.SnippetFilter{}, storage.Page{Sort: storage.SortTitle, Order: storage.OrderDesc}, []string{"Echo", "Delta", "Charlie", "bravo", "alpha"}},
+		{"filtered by tags", storage.SnippetFilter{Tags: []string{"lang"}}, storage.Page{Sort: storage.SortTitle}, []string{"alpha", "Charlie", "Delta"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				page  = tt.page
+				got   []string
+				pages int
+			)
+
+			page.Limit = 2
+
+			for {
+				result, err := store.ListSnippets(alice, tt.filter, page)
+				if err != nil {
+					t.Fatalf("ListSnippets() page %d = %v", pages+1, err)
+				}
+
+				if result.Total != len(tt.want) {
+					t.Errorf("ListSnippets() page %d total = %d, want %d", pages+1, result.Total, len(tt.want))
+				}
+
+				got = append(got, titles(result.Snippets)...)
+				pages++
+
+				if result.NextCursor == "" || pages > len(tt.want) {
+					break
+				}
+
+				page.Cursor = result.NextCursor
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ListSnippets() over %d pages = %q, want %q", pages, got, tt.want)
+			}
+
+			if want := (len(tt.want) + 1) / 2; pages != want {
+				t.Errorf("ListSnippets() took %d pages, want %d", pages, want)
+			}
+		})
+	}
+
+	t.Run("invalid cursors", func(t *testing.T) {
+		first, err := store.ListSnippets(alice, storage.SnippetFilter{}, storage.Page{Limit: 2})
+		if err != nil {
+			t.Fatalf("ListSnippets() = %v", err)
+		}
+
+		for _, page := range []storage.Page{
+			{Cursor: "not a cursor"},
+			{Cursor: first.NextCursor, Sort: storage.SortTitle},
+			{Cursor: first.NextCursor, Order: storage.OrderDesc},
+		} {
+			if _, err := store.ListSnippets(alice, storage.SnippetFilter{}, page); !errors.Is(err, storage.ErrInvalidCursor) {
+				t.Errorf("ListSnippets(%+v) = %v, want %v", page, err, storage.ErrInvalidCursor)
+			}
+		}
+	})
+}
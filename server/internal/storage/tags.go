@@ -0,0 +1,425 @@
+package storage
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/villaleo/cstash/internal/models"
+)
+
+// tagMetadata is the description and color of a tag. Workspace tags are
+// described once for the workspace; personal tags are described by each user
+// for themselves, so their OwnerID is set. Metadata with neither a
+// description nor a color isn't stored.
+type tagMetadata struct {
+	WorkspaceID string `json:"workspaceId"`
+	OwnerID     string `json:"ownerId,omitempty"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Color       string `json:"color,omitempty"`
+}
+
+// tagKey identifies the metadata of a tag
+type tagKey struct {
+	workspaceID, ownerID, name string
+}
+
+func (m *tagMetadata) key() tagKey {
+	return tagKey{workspaceID: m.WorkspaceID, ownerID: m.OwnerID, name: m.Name}
+}
+
+// empty reports whether m describes nothing, and so isn't stored
+func (m *tagMetadata) empty() bool {
+	return m.Description == "" && m.Color == ""
+}
+
+// newTagMetadata returns the metadata of the tag called name as seen by the
+// caller making the call with ctx
+func newTagMetadata(ctx context.Context, name, description, color string) tagMetadata {
+	metadata := tagMetadata{WorkspaceID: workspaceID(ctx), Name: name, Description: description, Color: color}
+	if metadata.WorkspaceID == "" {
+		metadata.OwnerID = callerID(ctx)
+	}
+
+	return metadata
+}
+
+// snippetChange is a snippet before and after a change
+type snippetChange struct {
+	before, after *models.Snippet
+}
+
+// tagChange is everything a change to tags saves: the snippets whose tags
+// change and the tag metadata to store, which is deleted if it's empty. Tag
+// is the resulting tag, if the change leaves one.
+type tagChange struct {
+	snippets []snippetChange
+	metadata []tagMetadata
+	tag      *models.Tag
+}
+
+// tagPlanner works out the changes of tag operations made by the caller
+// making calls with ctx. Snippets must hold every snippet in the caller's
+// workspace, and metadata the metadata of the tags they see, by name.
+type tagPlanner struct {
+	ctx      context.Context
+	snippets []*models.Snippet
+	metadata map[string]tagMetadata
+}
+
+// editable returns the snippets the caller may change the tags of. A
+// workspace member who may not edit its snippets may change no tags.
+func (p *tagPlanner) editable() ([]*models.Snippet, error) {
+	if membership := workspaceMembership(p.ctx); membership != nil && !membership.Role.CanEdit() {
+		return nil, ErrForbidden
+	}
+
+	editable := []*models.Snippet{}
+
+	for _, snippet := range p.snippets {
+		if canView(p.ctx, snippet) && canEdit(p.ctx, snippet) {
+			editable = append(editable, snippet)
+		}
+	}
+
+	return editable, nil
+}
+
+// updateTag renames the tag called name to tag.Name and sets its description
+// and color. The tags below it are moved below tag.Name, keeping their
+// descriptions and colors.
+func (p *tagPlanner) updateTag(name string, tag models.Tag) (*tagChange, error) {
+	editable, err := p.editable()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		tags   = tagNames(editable)
+		rename = make(map[string]string)
+	)
+
+	for existing := range tags {
+		if atOrBelow(existing, name) {
+			rename[existing] = tag.Name + existing[len(name):]
+		}
+	}
+
+	if len(rename) == 0 {
+		return nil, ErrTagNotFound
+	}
+
+	// The tags renamed to may only exist if they're being renamed too
+	for _, renamed := range append(slices.Collect(maps.Values(rename)), tag.Name) {
+		if _, moved := rename[renamed]; !moved && tags[renamed] {
+			return nil, ErrTagExists
+		}
+	}
+
+	change := &tagChange{snippets: retag(p.ctx, editable, rename)}
+
+	// Clear the metadata of the old names before setting that of the new ones,
+	// which may reuse them
+	if tag.Name != name {
+		for _, existing := range slices.Sorted(maps.Keys(rename)) {
+			change.metadata = append(change.metadata, newTagMetadata(p.ctx, existing, "", ""))
+		}
+
+		for _, existing := range slices.Sorted(maps.Keys(rename)) {
+			if metadata := p.metadata[existing]; existing != name && !metadata.empty() {
+				change.metadata = append(change.metadata, newTagMetadata(p.ctx, rename[existing], metadata.Description, metadata.Color))
+			}
+		}
+	}
+
+	change.metadata = append(change.metadata, newTagMetadata(p.ctx, tag.Name, tag.Description, tag.Color))
+	change.tag = p.describe(change, tag.Name)
+
+	return change, nil
+}
+
+// mergeTags replaces the tags called sources with target. The tags below a
+// source are moved below target, so merging "lang" into "code" turns
+// "lang/go" into "code/go", and a tag below more than one source moves with
+// the nearest. Each tag merged into keeps its description and color, or takes
+// those of the first tag merged into it with any. ErrTagMergeBelow is
+// returned if target is below one of sources.
+func (p *tagPlanner) mergeTags(sources []string, target string) (*tagChange, error) {
+	editable, err := p.editable()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, source := range sources {
+		if source != target && atOrBelow(target, source) {
+			return nil, ErrTagMergeBelow
+		}
+	}
+
+	rename := make(map[string]string)
+
+	for existing := range tagNames(editable) {
+		var nearest string
+
+		for _, source := range sources {
+			if source != target && atOrBelow(existing, source) && len(source) > len(nearest) {
+				nearest = source
+			}
+		}
+
+		if nearest != "" {
+			rename[existing] = target + existing[len(nearest):]
+		}
+	}
+
+	if len(rename) == 0 {
+		return nil, ErrTagNotFound
+	}
+
+	// Each tag merged into keeps its metadata unless it's being moved too, or
+	// takes that of the first tag merged into it with any
+	metadata := make(map[string]tagMetadata)
+
+	for _, renamed := range append(slices.Collect(maps.Values(rename)), target) {
+		if _, moved := rename[renamed]; !moved {
+			metadata[renamed] = p.metadata[renamed]
+		} else {
+			metadata[renamed] = tagMetadata{}
+		}
+	}
+
+	for _, source := range sources {
+		for _, existing := range slices.Sorted(maps.Keys(rename)) {
+			renamed := rename[existing]
+			if kept := metadata[renamed]; atOrBelow(existing, source) && kept.empty() {
+				metadata[renamed] = p.metadata[existing]
+			}
+		}
+	}
+
+	change := &tagChange{snippets: retag(p.ctx, editable, rename)}
+
+	// Clear the metadata of the old names before setting that of the new ones,
+	// which may reuse them
+	for _, existing := range slices.Sorted(maps.Keys(rename)) {
+		change.metadata = append(change.metadata, newTagMetadata(p.ctx, existing, "", ""))
+	}
+
+	for _, renamed := range slices.Sorted(maps.Keys(metadata)) {
+		if described := metadata[renamed]; renamed == target || !described.empty() {
+			change.metadata = append(change.metadata, newTagMetadata(p.ctx, renamed, described.Description, described.Color))
+		}
+	}
+
+	change.tag = p.describe(change, target)
+
+	return change, nil
+}
+
+// deleteTag removes the tag called name and the tags below it, along with
+// their descriptions and colors
+func (p *tagPlanner) deleteTag(name string) (*tagChange, error) {
+	editable, err := p.editable()
+	if err != nil {
+		return nil, err
+	}
+
+	rename := make(map[string]string)
+
+	for existing := range tagNames(editable) {
+		if atOrBelow(existing, name) {
+			rename[existing] = ""
+		}
+	}
+
+	if len(rename) == 0 {
+		return nil, ErrTagNotFound
+	}
+
+	change := &tagChange{snippets: retag(p.ctx, editable, rename)}
+
+	for _, existing := range slices.Sorted(maps.Keys(rename)) {
+		change.metadata = append(change.metadata, newTagMetadata(p.ctx, existing, "", ""))
+	}
+
+	return change, nil
+}
+
+// describe returns the tag called name as it is after change
+func (p *tagPlanner) describe(change *tagChange, name string) *models.Tag {
+	changed := make(map[string]*models.Snippet, len(change.snippets))
+	for _, c := range change.snippets {
+		changed[c.after.ID] = c.after
+	}
+
+	snippets := make([]*models.Snippet, len(p.snippets))
+	for i, snippet := range p.snippets {
+		if after, ok := changed[snippet.ID]; ok {
+			snippet = after
+		}
+
+		snippets[i] = snippet
+	}
+
+	tag := &models.Tag{Name: name, Count: countTags(p.ctx, snippets)[name]}
+
+	for _, metadata := range change.metadata {
+		if metadata.Name == name {
+			tag.Description, tag.Color = metadata.Description, metadata.Color
+		}
+	}
+
+	return tag
+}
+
+// retag returns the changes to the snippets that have any of the tags in
+// rename, each replaced by the tag it maps to. Tags mapped to an empty string
+// are removed, and a tag that's already on the snippet is kept where it first
+// appears. Each changed snippet is a new version.
+func retag(ctx context.Context, snippets []*models.Snippet, rename map[string]string) []snippetChange {
+	var (
+		changes = []snippetChange{}
+		now     = time.Now()
+	)
+
+	for _, snippet := range snippets {
+		tags := make([]string, 0, len(snippet.Tags))
+
+		for _, tag := range snippet.Tags {
+			if renamed, ok := rename[tag]; ok {
+				tag = renamed
+			}
+
+			if tag != "" && !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+
+		if slices.Equal(tags, snippet.Tags) {
+			continue
+		}
+
+		after := *snippet
+		after.Tags = tags
+		after.UpdatedAt = now
+		after.Version++
+		changes = append(changes, snippetChange{before: snippet, after: &after})
+	}
+
+	return changes
+}
+
+// tagNames returns the set of tags any of snippets has
+func tagNames(snippets []*models.Snippet) map[string]bool {
+	names := make(map[string]bool)
+
+	for _, snippet := range snippets {
+		for _, tag := range snippet.Tags {
+			names[tag] = true
+		}
+	}
+
+	return names
+}
+
+// atOrBelow reports whether the tag called tag is the one called name or one
+// below it
+func atOrBelow(tag, name string) bool {
+	return tag == name || strings.HasPrefix(tag, name+models.TagSeparator)
+}
+
+// countTags counts the snippets the caller making the call with ctx may view
+// by tag
+func countTags(ctx context.Context, snippets []*models.Snippet) map[string]int {
+	counts := make(map[string]int)
+
+	for _, snippet := range snippets {
+		if !canView(ctx, snippet) {
+			continue
+		}
+
+		for _, tag := range slices.Compact(slices.Sorted(slices.Values(snippet.Tags))) {
+			counts[tag]++
+		}
+	}
+
+	return counts
+}
+
+// describeTags returns the tags counted in counts with their metadata, by
+// name, ordered by name
+func describeTags(counts map[string]int, metadata map[string]tagMetadata) []models.Tag {
+	tags := make([]models.Tag, 0, len(counts))
+
+	for _, name := range slices.Sorted(maps.Keys(counts)) {
+		tags = append(tags, models.Tag{
+			Name:        name,
+			Description: metadata[name].Description,
+			Color:       metadata[name].Color,
+			Count:       counts[name],
+		})
+	}
+
+	return tags
+}
+
+// tagTree arranges the tags of the snippets the caller making the call with
+// ctx may view into their hierarchy, adding the tags above them that no
+// snippet has. Each level is ordered by name.
+func tagTree(ctx context.Context, snippets []*models.Snippet, metadata map[string]tagMetadata) []*models.TagNode {
+	var (
+		counts = countTags(ctx, snippets)
+		totals = make(map[string]int)
+	)
+
+	for _, snippet := range snippets {
+		if !canView(ctx, snippet) {
+			continue
+		}
+
+		// Count the snippet once under each tag it has or is below
+		below := make(map[string]bool)
+		for _, tag := range snippet.Tags {
+			below[tag] = true
+			for _, parent := range models.TagParents(tag) {
+				below[parent] = true
+			}
+		}
+
+		for name := range below {
+			totals[name]++
+		}
+	}
+
+	var (
+		roots = []*models.TagNode{}
+		nodes = make(map[string]*models.TagNode, len(totals))
+	)
+
+	// Sorting puts every tag after the tags above it
+	for _, name := range slices.Sorted(maps.Keys(totals)) {
+		node := &models.TagNode{
+			Tag: models.Tag{
+				Name:        name,
+				Description: metadata[name].Description,
+				Color:       metadata[name].Color,
+				Count:       counts[name],
+			},
+			Label:    name[strings.LastIndex(name, models.TagSeparator)+1:],
+			Total:    totals[name],
+			Children: []*models.TagNode{},
+		}
+		nodes[name] = node
+
+		if parents := models.TagParents(name); len(parents) > 0 {
+			nodes[parents[0]].Children = append(nodes[parents[0]].Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+
+	return roots
+}
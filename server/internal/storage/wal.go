@@ -0,0 +1,532 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/villaleo/cstash/internal/models"
+)
+
+const (
+	walFileName      = "wal.log"
+	snapshotFileName = "snapshot.json"
+)
+
+// walOp identifies the kind of change recorded by a walEntry
+type walOp string
+
+const (
+	walPut     walOp = "put"
+	walDelete  walOp = "delete"
+	walPutUser walOp = "putUser"
+	walPutTags walOp = "putTags"
+
+	walPutAccessToken    walOp = "putAccessToken"
+	walDeleteAccessToken walOp = "deleteAccessToken"
+
+	walPutSession    walOp = "putSession"
+	walDeleteSession walOp = "deleteSession"
+
+	walPutWorkspace     walOp = "putWorkspace"
+	walDeleteWorkspace  walOp = "deleteWorkspace"
+	walPutMembership    walOp = "putMembership"
+	walDeleteMembership walOp = "deleteMembership"
+
+	walPutCollection    walOp = "putCollection"
+	walDeleteCollection walOp = "deleteCollection"
+)
+
+// walEntry is a single change appended to the write-ahead log. Entries are
+// idempotent, so replaying one that is already part of a snapshot is harmless.
+// Snippets are logged along with the revisions recorded by the change. A
+// change to tags logs every snippet it changes and the tag metadata it stores
+// in a single entry, so that it's replayed whole or not at all. Deleting a
+// snippet removes it from every collection when replayed, so those changes
+// aren't logged.
+type walEntry struct {
+	Op        walOp              `json:"op"`
+	ID        string             `json:"id"`
+	Snippet   *models.Snippet    `json:"snippet,omitempty"`
+	Snippets  []*models.Snippet  `json:"snippets,omitempty"`
+	Revisions []*models.Revision `json:"revisions,omitempty"`
+	Tags      []tagMetadata      `json:"tags,omitempty"`
+	User      *userRecord        `json:"user,omitempty"`
+	Token     *tokenRecord       `json:"token,omitempty"`
+	Session   *sessionRecord     `json:"session,omitempty"`
+
+	Workspace  *models.Workspace  `json:"workspace,omitempty"`
+	Membership *models.Membership `json:"membership,omitempty"`
+	Collection *models.Collection `json:"collection,omitempty"`
+}
+
+// userRecord is the persisted form of a models.User, whose password hash is
+// hidden from JSON
+type userRecord struct {
+	ID           string    `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"passwordHash"`
+	ExternalID   string    `json:"externalId,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// tokenRecord is the persisted form of a models.AccessToken, whose hash is
+// hidden from JSON
+type tokenRecord struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	TokenHash string    `json:"tokenHash"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// sessionRecord is the persisted form of a models.Session, whose token hash is
+// hidden from JSON
+type sessionRecord struct {
+	TokenHash string    `json:"tokenHash"`
+	UserID    string    `json:"userId"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// snapshot is the compacted state of a MemoryStore
+type snapshot struct {
+	Snippets  []*models.Snippet  `json:"snippets"`
+	Revisions []*models.Revision `json:"revisions,omitempty"`
+	Tags      []tagMetadata      `json:"tags,omitempty"`
+	Users     []*userRecord      `json:"users,omitempty"`
+	Tokens    []*tokenRecord     `json:"tokens,omitempty"`
+	Sessions  []*sessionRecord   `json:"sessions,omitempty"`
+
+	Workspaces  []*models.Workspace  `json:"workspaces,omitempty"`
+	Memberships []*models.Membership `json:"memberships,omitempty"`
+	Collections []*models.Collection `json:"collections,omitempty"`
+}
+
+// walState is the state rebuilt from a snapshot and the log tail. Revisions
+// are keyed by snippet ID, access tokens and sessions by their hash, and
+// memberships by workspace ID then user ID.
+type walState struct {
+	snippets    map[string]*models.Snippet
+	revisions   map[string][]*models.Revision
+	tags        map[tagKey]tagMetadata
+	users       map[string]*models.User
+	tokens      map[string]*models.AccessToken
+	sessions    map[string]*models.Session
+	workspaces  map[string]*models.Workspace
+	memberships map[string]map[string]*models.Membership
+	collections map[string]*models.Collection
+}
+
+// putRevision adds revision to the state, replacing the revision with the same
+// number if it was already added
+func (s *walState) putRevision(revision *models.Revision) {
+	revisions := s.revisions[revision.SnippetID]
+
+	if revision.Number <= len(revisions) {
+		revisions[revision.Number-1] = revision
+	} else {
+		s.revisions[revision.SnippetID] = append(revisions, revision)
+	}
+}
+
+// putTag stores metadata in the state, or deletes it if it's empty
+func (s *walState) putTag(metadata tagMetadata) {
+	if metadata.empty() {
+		delete(s.tags, metadata.key())
+	} else {
+		s.tags[metadata.key()] = metadata
+	}
+}
+
+// putSession adds the session of record to the state, unless it has expired
+func (s *walState) putSession(record *sessionRecord) {
+	if session := record.session(); !session.Expired() {
+		s.sessions[session.TokenHash] = session
+	}
+}
+
+// putMembership adds membership to the state
+func (s *walState) putMembership(membership *models.Membership) {
+	if s.memberships[membership.WorkspaceID] == nil {
+		s.memberships[membership.WorkspaceID] = make(map[string]*models.Membership)
+	}
+
+	s.memberships[membership.WorkspaceID][membership.UserID] = membership
+}
+
+// deleteSnippet removes the snippet with id from the state, along with its
+// revisions, and removes it from every collection
+func (s *walState) deleteSnippet(id string) {
+	delete(s.snippets, id)
+	delete(s.revisions, id)
+
+	for _, collection := range s.collections {
+		collection.Remove(id)
+	}
+}
+
+// deleteWorkspace removes the workspace with id from the state, along with
+// its memberships, snippets, their revisions, its tag metadata and its
+// collections
+func (s *walState) deleteWorkspace(id string) {
+	delete(s.workspaces, id)
+	delete(s.memberships, id)
+
+	maps.DeleteFunc(s.collections, func(_ string, collection *models.Collection) bool {
+		return collection.WorkspaceID == id
+	})
+
+	maps.DeleteFunc(s.tags, func(key tagKey, _ tagMetadata) bool {
+		return key.workspaceID == id
+	})
+
+	maps.DeleteFunc(s.snippets, func(snippetID string, snippet *models.Snippet) bool {
+		if snippet.WorkspaceID != id {
+			return false
+		}
+
+		delete(s.revisions, snippetID)
+
+		return true
+	})
+}
+
+// newUserRecord converts user to its persisted form
+func newUserRecord(user *models.User) *userRecord {
+	return &userRecord{
+		ID:           user.ID,
+		Username:     user.Username,
+		PasswordHash: user.PasswordHash,
+		ExternalID:   user.ExternalID,
+		CreatedAt:    user.CreatedAt,
+	}
+}
+
+// user converts r back to a models.User
+func (r *userRecord) user() *models.User {
+	return &models.User{
+		ID:           r.ID,
+		Username:     r.Username,
+		PasswordHash: r.PasswordHash,
+		ExternalID:   r.ExternalID,
+		CreatedAt:    r.CreatedAt,
+	}
+}
+
+// newTokenRecord converts token to its persisted form
+func newTokenRecord(token *models.AccessToken) *tokenRecord {
+	return &tokenRecord{
+		ID:        token.ID,
+		UserID:    token.UserID,
+		Name:      token.Name,
+		Scopes:    token.Scopes,
+		TokenHash: token.TokenHash,
+		CreatedAt: token.CreatedAt,
+	}
+}
+
+// accessToken converts r back to a models.AccessToken
+func (r *tokenRecord) accessToken() *models.AccessToken {
+	return &models.AccessToken{
+		ID:        r.ID,
+		UserID:    r.UserID,
+		Name:      r.Name,
+		Scopes:    r.Scopes,
+		TokenHash: r.TokenHash,
+		CreatedAt: r.CreatedAt,
+	}
+}
+
+// newSessionRecord converts session to its persisted form
+func newSessionRecord(session *models.Session) *sessionRecord {
+	return &sessionRecord{
+		TokenHash: session.TokenHash,
+		UserID:    session.UserID,
+		CreatedAt: session.CreatedAt,
+		ExpiresAt: session.ExpiresAt,
+	}
+}
+
+// session converts r back to a models.Session
+func (r *sessionRecord) session() *models.Session {
+	return &models.Session{
+		TokenHash: r.TokenHash,
+		UserID:    r.UserID,
+		CreatedAt: r.CreatedAt,
+		ExpiresAt: r.ExpiresAt,
+	}
+}
+
+// writeAheadLog persists the changes made to a MemoryStore. Every change is
+// appended to wal.log and flushed to disk before it is applied in memory.
+// Compacting the log writes the whole state to snapshot.json and truncates
+// wal.log.
+type writeAheadLog struct {
+	dir  string
+	file *os.File
+	mu   sync.Mutex
+}
+
+// openWAL opens the write-ahead log in dir, creating dir if needed
+func openWAL(dir string) (*writeAheadLog, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &writeAheadLog{dir: dir, file: file}, nil
+}
+
+// append writes entry to the end of the log and flushes it to disk
+func (w *writeAheadLog) append(entry walEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err := w.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("append to write-ahead log: %w", err)
+	}
+
+	return w.file.Sync()
+}
+
+// load rebuilds the stored state from the latest snapshot followed by the
+// tail of the log. A partially written final entry, left behind by a crash
+// mid-append, is discarded and truncated from the log.
+func (w *writeAheadLog) load() (*walState, error) {
+	state := &walState{
+		snippets:  make(map[string]*models.Snippet),
+		revisions: make(map[string][]*models.Revision),
+		tags:      make(map[tagKey]tagMetadata),
+		users:     make(map[string]*models.User),
+		tokens:    make(map[string]*models.AccessToken),
+		sessions:  make(map[string]*models.Session),
+
+		workspaces:  make(map[string]*models.Workspace),
+		memberships: make(map[string]map[string]*models.Membership),
+		collections: make(map[string]*models.Collection),
+	}
+
+	data, err := os.ReadFile(filepath.Join(w.dir, snapshotFileName))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		var snap snapshot
+		if err := json.Unmarshal(data, &snap); err != nil {
+			return nil, fmt.Errorf("decode snapshot: %w", err)
+		}
+
+		for _, snippet := range snap.Snippets {
+			state.snippets[snippet.ID] = snippet
+		}
+
+		for _, revision := range snap.Revisions {
+			state.putRevision(revision)
+		}
+
+		for _, metadata := range snap.Tags {
+			state.putTag(metadata)
+		}
+
+		for _, record := range snap.Users {
+			state.users[record.ID] = record.user()
+		}
+
+		for _, record := range snap.Tokens {
+			state.tokens[record.TokenHash] = record.accessToken()
+		}
+
+		for _, record := range snap.Sessions {
+			state.putSession(record)
+		}
+
+		for _, workspace := range snap.Workspaces {
+			state.workspaces[workspace.ID] = workspace
+		}
+
+		for _, membership := range snap.Memberships {
+			state.putMembership(membership)
+		}
+
+		for _, collection := range snap.Collections {
+			state.collections[collection.ID] = collection
+		}
+	}
+
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var (
+		reader = bufio.NewReader(w.file)
+		valid  int64
+	)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var entry walEntry
+		if err := json.Unmarshal(bytes.TrimSpace(line), &entry); err != nil {
+			return nil, fmt.Errorf("decode write-ahead log entry at offset %d: %w", valid, err)
+		}
+
+		switch entry.Op {
+		case walPut:
+			state.snippets[entry.ID] = entry.Snippet
+			for _, revision := range entry.Revisions {
+				state.putRevision(revision)
+			}
+		case walPutTags:
+			for _, snippet := range entry.Snippets {
+				state.snippets[snippet.ID] = snippet
+			}
+
+			for _, revision := range entry.Revisions {
+				state.putRevision(revision)
+			}
+
+			for _, metadata := range entry.Tags {
+				state.putTag(metadata)
+			}
+		case walDelete:
+			state.deleteSnippet(entry.ID)
+		case walPutUser:
+			state.users[entry.ID] = entry.User.user()
+		case walPutAccessToken:
+			state.tokens[entry.Token.TokenHash] = entry.Token.accessToken()
+		case walDeleteAccessToken:
+			delete(state.tokens, entry.ID)
+		case walPutSession:
+			state.putSession(entry.Session)
+		case walDeleteSession:
+			delete(state.sessions, entry.ID)
+		case walPutWorkspace:
+			state.workspaces[entry.ID] = entry.Workspace
+			// A new workspace is logged along with its owner
+			if entry.Membership != nil {
+				state.putMembership(entry.Membership)
+			}
+		case walDeleteWorkspace:
+			state.deleteWorkspace(entry.ID)
+		case walPutMembership:
+			state.putMembership(entry.Membership)
+		case walDeleteMembership:
+			delete(state.memberships[entry.Membership.WorkspaceID], entry.Membership.UserID)
+		case walPutCollection:
+			state.collections[entry.ID] = entry.Collection
+		case walDeleteCollection:
+			delete(state.collections, entry.ID)
+		default:
+			return nil, fmt.Errorf("unknown write-ahead log operation %q", entry.Op)
+		}
+
+		valid += int64(len(line))
+	}
+
+	// Anything after the last complete entry is a torn write
+	if err := w.file.Truncate(valid); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// compact writes snap to a new snapshot file and truncates the log. The
+// snapshot is written to a temporary file and renamed into place, so a crash
+// never leaves a partial snapshot behind.
+func (w *writeAheadLog) compact(snap snapshot) error {
+	var (
+		path    = filepath.Join(w.dir, snapshotFileName)
+		tmpPath = path + ".tmp"
+	)
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := writeFileSync(tmpPath, data); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("replace snapshot: %w", err)
+	}
+
+	if err := syncDir(w.dir); err != nil {
+		return err
+	}
+
+	// Entries already in the snapshot are replayed harmlessly if a crash
+	// happens before the truncation is flushed
+	if err := w.file.Truncate(0); err != nil {
+		return fmt.Errorf("truncate write-ahead log: %w", err)
+	}
+
+	return w.file.Sync()
+}
+
+// close closes the log file
+func (w *writeAheadLog) close() error {
+	return w.file.Close()
+}
+
+// writeFileSync writes data to the file at path and flushes it to disk
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// syncDir flushes the directory entries of dir to disk
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}